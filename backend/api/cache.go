@@ -0,0 +1,337 @@
+package api
+
+import (
+	"context"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// namespaced gets a lister from cache, or, when the credentials may not list
+// the resource across the cluster, from informers watching only namespace.
+func namespaced[L any](ctx context.Context, cache *kube.Cache, namespace string, get func(*kube.Cache, context.Context) (L, error)) (L, error) {
+	lister, err := get(cache, ctx)
+	if err != nil && namespace != v1.NamespaceAll && apierrors.IsForbidden(err) {
+		return get(cache.Namespace(namespace), ctx)
+	}
+	return lister, err
+}
+
+// dynamicLister adapts Cache.Dynamic to namespaced.
+func dynamicLister(gvr schema.GroupVersionResource) func(*kube.Cache, context.Context) (toolscache.GenericLister, error) {
+	return func(cache *kube.Cache, ctx context.Context) (toolscache.GenericLister, error) {
+		return cache.Dynamic(ctx, gvr)
+	}
+}
+
+func listPods(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]corev1.Pod, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Pods)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listServices(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]corev1.Service, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Services)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Services(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listNodes(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]corev1.Node, error) {
+	lister, err := cache.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listNamespaces(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]corev1.Namespace, error) {
+	lister, err := cache.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listPVCs(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]corev1.PersistentVolumeClaim, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).PersistentVolumeClaims)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.PersistentVolumeClaims(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listPVs(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]corev1.PersistentVolume, error) {
+	lister, err := cache.PersistentVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listServiceAccounts(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]corev1.ServiceAccount, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).ServiceAccounts)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.ServiceAccounts(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listConfigMapMetadata(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]v1.PartialObjectMetadata, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).ConfigMapMetadata)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Namespace(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listSecretMetadata(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]v1.PartialObjectMetadata, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).SecretMetadata)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Namespace(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listEndpointSlices(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]discoveryv1.EndpointSlice, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).EndpointSlices)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.EndpointSlices(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listIngresses(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]networkingv1.Ingress, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Ingresses)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Ingresses(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listNetworkPolicies(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]networkingv1.NetworkPolicy, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).NetworkPolicies)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.NetworkPolicies(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listDeployments(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]appsv1.Deployment, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Deployments)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Deployments(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listStatefulSets(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]appsv1.StatefulSet, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).StatefulSets)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.StatefulSets(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listDaemonSets(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]appsv1.DaemonSet, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).DaemonSets)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.DaemonSets(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listReplicaSets(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]appsv1.ReplicaSet, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).ReplicaSets)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.ReplicaSets(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listJobs(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]batchv1.Job, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Jobs)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Jobs(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listCronJobs(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]batchv1.CronJob, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).CronJobs)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.CronJobs(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listPodDisruptionBudgets(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]policyv1.PodDisruptionBudget, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).PodDisruptionBudgets)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.PodDisruptionBudgets(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listRoles(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]rbacv1.Role, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Roles)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Roles(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listRoleBindings(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) ([]rbacv1.RoleBinding, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).RoleBindings)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.RoleBindings(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listClusterRoles(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]rbacv1.ClusterRole, error) {
+	lister, err := cache.ClusterRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listClusterRoleBindings(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]rbacv1.ClusterRoleBinding, error) {
+	lister, err := cache.ClusterRoleBindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listStorageClasses(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]storagev1.StorageClass, error) {
+	lister, err := cache.StorageClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+func listCSIDrivers(ctx context.Context, cache *kube.Cache, selector labels.Selector) ([]storagev1.CSIDriver, error) {
+	lister, err := cache.CSIDrivers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
@@ -0,0 +1,412 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const certManagerGroup = "cert-manager.io"
+
+// CertificateItem is one TLS Secret, or a Secret name referenced by an
+// Ingress or a cert-manager Certificate. Certificate is the parsed leaf
+// certificate, nil when the Secret is missing or could not be read.
+type CertificateItem struct {
+	Namespace   string                  `json:"namespace"`
+	Secret      string                  `json:"secret"`
+	Missing     bool                    `json:"missing"`
+	Certificate *CertificateInfo        `json:"certificate,omitempty"`
+	NotAfter    *time.Time              `json:"notAfter,omitempty"`
+	Ingresses   []CertificateIngress    `json:"ingresses,omitempty"`
+	CertManager *CertManagerCertificate `json:"certManager,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// CertificateIngress lists the hosts an Ingress serves with the Secret;
+// Uncovered are those the certificate's SANs do not match.
+type CertificateIngress struct {
+	Name      string   `json:"name"`
+	Hosts     []string `json:"hosts"`
+	Uncovered []string `json:"uncovered,omitempty"`
+}
+
+type CertManagerCertificate struct {
+	Name        string     `json:"name"`
+	Issuer      string     `json:"issuer"`
+	DNSNames    []string   `json:"dnsNames,omitempty"`
+	Ready       string     `json:"ready"`
+	Message     string     `json:"message,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	RenewalTime *time.Time `json:"renewalTime,omitempty"`
+}
+
+// CertificateList reports whether TLS Secrets were read; with
+// secretsMetadataOnly, expiry comes from cert-manager status only.
+type CertificateList struct {
+	Items       []CertificateItem `json:"items"`
+	SecretsRead bool              `json:"secretsRead"`
+	// Unavailable names the optional resources left out because the
+	// credentials may not list them.
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// certManagerCertificate is decoded locally from unstructured objects; only
+// the fields KUBI renders are declared.
+type certManagerCertificate struct {
+	v1.ObjectMeta `json:"metadata"`
+	Spec          struct {
+		SecretName string   `json:"secretName"`
+		DNSNames   []string `json:"dnsNames"`
+		IssuerRef  struct {
+			Name string `json:"name"`
+			Kind string `json:"kind"`
+		} `json:"issuerRef"`
+	} `json:"spec"`
+	Status struct {
+		NotAfter    *v1.Time `json:"notAfter"`
+		RenewalTime *v1.Time `json:"renewalTime"`
+		Conditions  []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func CertificatesHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		namespace := r.URL.Query().Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
+		}
+
+		list, err := collectCertificates(ctx, cache, namespace)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, list)
+	}
+}
+
+// collectCertificates merges TLS Secrets, Ingress spec.tls and cert-manager
+// Certificates by Secret. TLS Secrets are only parsed when the cache may
+// read Secret bodies, and only their public certificate is kept. cert-manager
+// is optional: Certificates the credentials may not list are left out.
+func collectCertificates(ctx context.Context, cache *kube.Cache, namespace string) (CertificateList, error) {
+	secrets, err := listSecretMetadata(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return CertificateList{}, err
+	}
+	existing := map[string]bool{}
+	for _, secret := range secrets {
+		existing[secret.Namespace+"/"+secret.Name] = true
+	}
+
+	items := map[string]*CertificateItem{}
+	item := func(namespace, name string) *CertificateItem {
+		key := namespace + "/" + name
+		if items[key] == nil {
+			items[key] = &CertificateItem{Namespace: namespace, Secret: name, Missing: !existing[key]}
+		}
+		return items[key]
+	}
+
+	secretsRead := true
+	tlsSecrets, err := listTLSSecrets(ctx, cache, namespace)
+	if errors.Is(err, kube.ErrSecretsMetadataOnly) {
+		secretsRead = false
+	} else if err != nil {
+		return CertificateList{}, err
+	}
+	for _, secret := range tlsSecrets {
+		if secret.Type != corev1.SecretTypeTLS {
+			continue
+		}
+		entry := item(secret.Namespace, secret.Name)
+		certificates, err := parseCertificates(secret.Data[corev1.TLSCertKey])
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		if len(certificates) > 0 {
+			entry.Certificate = &certificates[0]
+			entry.NotAfter = &certificates[0].NotAfter
+		} else if entry.Error == "" {
+			entry.Error = "tls.crt holds no certificate"
+		}
+	}
+
+	var unavailable []string
+	certManager, err := listCertManagerCertificates(ctx, cache, namespace)
+	if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+		unavailable = append(unavailable, "certificates."+certManagerGroup)
+	} else if err != nil {
+		return CertificateList{}, err
+	}
+	for _, cert := range certManager {
+		if cert.Spec.SecretName == "" {
+			continue
+		}
+		entry := item(cert.Namespace, cert.Spec.SecretName)
+		entry.CertManager = &CertManagerCertificate{
+			Name:     cert.Name,
+			Issuer:   cert.Spec.IssuerRef.Kind + "/" + cert.Spec.IssuerRef.Name,
+			DNSNames: cert.Spec.DNSNames,
+			Ready:    string(corev1.ConditionUnknown),
+		}
+		for _, condition := range cert.Status.Conditions {
+			if condition.Type == "Ready" {
+				entry.CertManager.Ready = condition.Status
+				entry.CertManager.Message = condition.Message
+			}
+		}
+		if cert.Status.NotAfter != nil {
+			entry.CertManager.NotAfter = &cert.Status.NotAfter.Time
+			if entry.NotAfter == nil {
+				entry.NotAfter = entry.CertManager.NotAfter
+			}
+		}
+		if cert.Status.RenewalTime != nil {
+			entry.CertManager.RenewalTime = &cert.Status.RenewalTime.Time
+		}
+	}
+
+	ingresses, err := listIngresses(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return CertificateList{}, err
+	}
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			// Without a secretName the controller's default certificate
+			// is used, which KUBI cannot see.
+			if tls.SecretName == "" {
+				continue
+			}
+			entry := item(ing.Namespace, tls.SecretName)
+			ingress := CertificateIngress{Name: ing.Name, Hosts: tls.Hosts}
+			if names := entry.dnsNames(); names != nil {
+				for _, host := range tls.Hosts {
+					if !hostCovered(host, names) {
+						ingress.Uncovered = append(ingress.Uncovered, host)
+					}
+				}
+			}
+			entry.Ingresses = append(entry.Ingresses, ingress)
+		}
+	}
+
+	out := make([]CertificateItem, 0, len(items))
+	for _, entry := range items {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Namespace == out[j].Namespace {
+			return out[i].Secret < out[j].Secret
+		}
+		return out[i].Namespace < out[j].Namespace
+	})
+	return CertificateList{Items: out, SecretsRead: secretsRead, Unavailable: unavailable}, nil
+}
+
+// dnsNames returns the names the certificate is valid for: its SANs, or the
+// names requested from cert-manager when the Secret was not read. It is nil
+// when neither is known.
+func (c *CertificateItem) dnsNames() []string {
+	if c.Certificate != nil {
+		return append([]string{}, c.Certificate.DNSNames...)
+	}
+	if c.CertManager != nil && !c.Missing {
+		return c.CertManager.DNSNames
+	}
+	return nil
+}
+
+// hostCovered matches host against SANs, where "*.example.com" covers
+// exactly one label.
+func hostCovered(host string, names []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(name, "*."); ok {
+			if label, rest, found := strings.Cut(host, "."); found && label != "" && label != "*" && rest == suffix {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func listTLSSecrets(ctx context.Context, cache *kube.Cache, namespace string) ([]corev1.Secret, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).TLSSecrets)
+	if err != nil {
+		return nil, err
+	}
+	items, err := lister.Secrets(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	return values(items), nil
+}
+
+// listCertManagerCertificates returns nothing, without error, when
+// cert-manager is not installed.
+func listCertManagerCertificates(ctx context.Context, cache *kube.Cache, namespace string) ([]certManagerCertificate, error) {
+	version, err := cache.ServedVersion(certManagerGroup, "certificates", "v1")
+	if err != nil || version == "" {
+		return nil, err
+	}
+	lister, err := namespaced(ctx, cache, namespace, dynamicLister(schema.GroupVersionResource{Group: certManagerGroup, Version: version, Resource: "certificates"}))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	if namespace == v1.NamespaceAll {
+		objects, err = lister.List(labels.Everything())
+	} else {
+		objects, err = lister.ByNamespace(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := []certManagerCertificate{}
+	for _, object := range objects {
+		item, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		var cert certManagerCertificate
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &cert); err == nil {
+			certificates = append(certificates, cert)
+		}
+	}
+	return certificates, nil
+}
+
+// certificateExpiringRule takes a "days" param: the window before notAfter
+// in which certificates are reported. Expired ones are left to
+// certificate-expired.
+type certificateExpiringRule struct {
+	days int
+}
+
+func (certificateExpiringRule) ID() string       { return "certificate-expiring" }
+func (certificateExpiringRule) Severity() string { return SeverityWarning }
+
+func (certificateExpiringRule) Description() string {
+	return "TLS certificates expire soon. Param days: the window, 30 by default."
+}
+
+func (r certificateExpiringRule) WithParams(params map[string]string) (Rule, error) {
+	for key, value := range params {
+		if key != "days" {
+			return nil, fmt.Errorf("unknown param %q", key)
+		}
+		days, err := strconv.Atoi(value)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid days %q: must be a positive number", value)
+		}
+		r.days = days
+	}
+	return r, nil
+}
+
+func (r certificateExpiringRule) Evaluate(s *ValidationSnapshot) []ValidationItem {
+	now := time.Now()
+	deadline := now.AddDate(0, 0, r.days)
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, cert := range s.Certificates {
+		if cert.NotAfter == nil || !cert.NotAfter.After(now) || cert.NotAfter.After(deadline) {
+			continue
+		}
+		objects = append(objects, fmt.Sprintf("%s/%s (expires %s)", cert.Namespace, cert.Secret, cert.NotAfter.UTC().Format(time.DateOnly)))
+		refs = append(refs, ObjectRef{Kind: "Secret", Namespace: cert.Namespace, Name: cert.Secret})
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "certificate-expiring",
+		Severity: SeverityWarning,
+		Title:    "Certificates expiring soon",
+		Details:  fmt.Sprintf("TLS certificates expire within %d days.", r.days),
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func validateCertificatesExpired(s *ValidationSnapshot) []ValidationItem {
+	now := time.Now()
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, cert := range s.Certificates {
+		if cert.NotAfter == nil || cert.NotAfter.After(now) {
+			continue
+		}
+		objects = append(objects, fmt.Sprintf("%s/%s (expired %s)", cert.Namespace, cert.Secret, cert.NotAfter.UTC().Format(time.DateOnly)))
+		refs = append(refs, ObjectRef{Kind: "Secret", Namespace: cert.Namespace, Name: cert.Secret})
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "certificate-expired",
+		Severity: SeverityCritical,
+		Title:    "Expired certificates",
+		Details:  "TLS certificates are past their notAfter date.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func validateCertificateHosts(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, cert := range s.Certificates {
+		for _, ingress := range cert.Ingresses {
+			switch {
+			case cert.Missing:
+				objects = append(objects, fmt.Sprintf("%s/%s -> %s (missing)", cert.Namespace, ingress.Name, cert.Secret))
+			case len(ingress.Uncovered) > 0:
+				objects = append(objects, fmt.Sprintf("%s/%s -> %s (%s)", cert.Namespace, ingress.Name, cert.Secret, strings.Join(ingress.Uncovered, ", ")))
+			default:
+				continue
+			}
+			refs = append(refs, ObjectRef{Kind: "Ingress", Namespace: cert.Namespace, Name: ingress.Name})
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "certificate-host-mismatch",
+		Severity: SeverityWarning,
+		Title:    "Ingress TLS hosts not covered",
+		Details:  "Ingress TLS hosts are not among the certificate's SANs, or the TLS Secret does not exist.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
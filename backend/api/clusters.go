@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+// clusterTimeout bounds each cluster of a fan-out, so one unreachable
+// cluster cannot hold up the others.
+const clusterTimeout = 15 * time.Second
+
+type ClustersResponse struct {
+	Current  string   `json:"current"`
+	Clusters []string `json:"clusters"`
+}
+
+// ClusterResult is one cluster's answer in a fan-out. Data is the payload
+// the single-cluster endpoint returns; Error is set instead when it failed.
+type ClusterResult struct {
+	Cluster string          `json:"cluster"`
+	Status  int             `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type FanOutResponse struct {
+	Clusters []ClusterResult `json:"clusters"`
+}
+
+func ClustersHandler(store *kube.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clusters, err := store.Clusters()
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		_, current, err := store.Contexts()
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		respondJSON(w, http.StatusOK, ClustersResponse{Current: current, Clusters: clusters})
+	}
+}
+
+// FanOutHandler runs handler, a single-cluster endpoint that reads the
+// cluster parameter, once per cluster concurrently. Clusters come from the
+// comma-separated clusters parameter, or else from the store. A failing,
+// slow or panicking cluster only affects its own result.
+func FanOutHandler(store *kube.Store, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var clusters []string
+		for _, cluster := range strings.Split(r.URL.Query().Get("clusters"), ",") {
+			if cluster = strings.TrimSpace(cluster); cluster != "" {
+				clusters = append(clusters, cluster)
+			}
+		}
+		if len(clusters) == 0 {
+			var err error
+			if clusters, err = store.Clusters(); err != nil {
+				respondClusterError(w, err)
+				return
+			}
+		}
+
+		results := make([]ClusterResult, len(clusters))
+		var wg sync.WaitGroup
+		for i, cluster := range clusters {
+			wg.Add(1)
+			go func(i int, cluster string) {
+				defer wg.Done()
+				results[i] = runForCluster(r, cluster, handler)
+			}(i, cluster)
+		}
+		wg.Wait()
+		respondJSON(w, http.StatusOK, FanOutResponse{Clusters: results})
+	}
+}
+
+func runForCluster(r *http.Request, cluster string, handler http.HandlerFunc) ClusterResult {
+	ctx, cancel := context.WithTimeout(r.Context(), clusterTimeout)
+	defer cancel()
+
+	req := r.Clone(ctx)
+	query := req.URL.Query()
+	query.Del("clusters")
+	query.Del("format")
+	query.Set("cluster", cluster)
+	req.URL.RawQuery = query.Encode()
+
+	recorder := &clusterRecorder{header: http.Header{}}
+	done := make(chan any, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		handler(recorder, req)
+	}()
+
+	result := ClusterResult{Cluster: cluster}
+	select {
+	case <-ctx.Done():
+		result.Status = http.StatusGatewayTimeout
+		result.Error = fmt.Sprintf("no answer within %s", clusterTimeout)
+		return result
+	case panicked := <-done:
+		if panicked != nil {
+			result.Status = http.StatusInternalServerError
+			result.Error = fmt.Sprint(panicked)
+			return result
+		}
+	}
+
+	result.Status = recorder.status
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	body := bytes.TrimSpace(recorder.body.Bytes())
+	var payload ErrorResponse
+	switch {
+	case !json.Valid(body):
+		result.Error = string(body)
+	case result.Status >= http.StatusBadRequest && json.Unmarshal(body, &payload) == nil && payload.Error != "":
+		result.Error = payload.Error
+	default:
+		result.Data = body
+	}
+	return result
+}
+
+type clusterRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *clusterRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *clusterRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *clusterRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(data)
+}
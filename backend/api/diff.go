@@ -0,0 +1,619 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Listing every resource of two clusters takes longer than a regular
+// request.
+const diffTimeout = time.Minute
+
+const (
+	diffAdded    = "added"
+	diffRemoved  = "removed"
+	diffModified = "modified"
+)
+
+// DiffOptions limit a comparison to Namespace, which is looked up as
+// NamespaceMap[Namespace] on the "to" side. NamespaceMap maps namespaces of
+// the "from" cluster to their counterparts; unmapped namespaces keep their
+// name.
+type DiffOptions struct {
+	Namespace    string
+	NamespaceMap map[string]string
+}
+
+type FieldChange struct {
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// ObjectDiff is an object added in, removed from or modified in the "to"
+// cluster. ToNamespace and ToName are set when a namespace mapping renamed
+// the object's namespace, or the Namespace object itself.
+type ObjectDiff struct {
+	Kind        string        `json:"kind"`
+	Namespace   string        `json:"namespace,omitempty"`
+	Name        string        `json:"name"`
+	ToNamespace string        `json:"toNamespace,omitempty"`
+	ToName      string        `json:"toName,omitempty"`
+	Change      string        `json:"change"`
+	Fields      []FieldChange `json:"fields,omitempty"`
+}
+
+// DerivedDiff is a service port, ingress route or RBAC grant present on
+// only one side. Object is named by its "to" namespace.
+type DerivedDiff struct {
+	Object string `json:"object"`
+	Change string `json:"change"`
+	Value  string `json:"value"`
+}
+
+type DiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Modified  int `json:"modified"`
+	Unchanged int `json:"unchanged"`
+}
+
+type DiffResponse struct {
+	From          string            `json:"from"`
+	To            string            `json:"to"`
+	NamespaceMap  map[string]string `json:"namespaceMap,omitempty"`
+	Summary       DiffSummary       `json:"summary"`
+	Objects       []ObjectDiff      `json:"objects"`
+	ServicePorts  []DerivedDiff     `json:"servicePorts"`
+	IngressRoutes []DerivedDiff     `json:"ingressRoutes"`
+	RBACBindings  []DerivedDiff     `json:"rbacBindings"`
+	Skipped       []string          `json:"skipped"`
+}
+
+// Changed reports whether the clusters differ.
+func (d DiffResponse) Changed() bool {
+	return d.Summary.Added+d.Summary.Removed+d.Summary.Modified > 0
+}
+
+func DiffHandler(store *kube.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from := query.Get("from")
+		to := query.Get("to")
+		if from == "" || to == "" {
+			respondError(w, http.StatusBadRequest, "from and to are required")
+			return
+		}
+		mapping, err := ParseNamespaceMap(query.Get("nsmap"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), diffTimeout)
+		defer cancel()
+
+		fromClient, err := store.ClientFor(from)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		toClient, err := store.ClientFor(to)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		diff, err := CompareClusters(ctx, fromClient, toClient, DiffOptions{Namespace: query.Get("ns"), NamespaceMap: mapping})
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		diff.From = from
+		diff.To = to
+		respondJSON(w, http.StatusOK, diff)
+	}
+}
+
+// ParseNamespaceMap parses "from=to" pairs separated by commas.
+func ParseNamespaceMap(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid namespace mapping %q: expected from=to", pair)
+		}
+		mapping[from] = to
+	}
+	return mapping, nil
+}
+
+func CompareClusters(ctx context.Context, from, to *kube.Client, opts DiffOptions) (DiffResponse, error) {
+	toNamespace := opts.Namespace
+	if mapped, ok := opts.NamespaceMap[opts.Namespace]; ok {
+		toNamespace = mapped
+	}
+	// Drift in ConfigMap data is the most common difference between two
+	// clusters; Secrets compare by key and value hash.
+	fromLists, err := kube.ListObjects(ctx, from, opts.Namespace, true)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("from: %w", err)
+	}
+	toLists, err := kube.ListObjects(ctx, to, toNamespace, true)
+	if err != nil {
+		return DiffResponse{}, fmt.Errorf("to: %w", err)
+	}
+	return diffObjectLists(fromLists, toLists, opts), nil
+}
+
+type diffKey struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// diffEntry keeps the original namespace and name of an object indexed under
+// mapped coordinates.
+type diffEntry struct {
+	namespace string
+	name      string
+	object    map[string]any
+}
+
+// diffIgnoredResources differ between any two clusters by nature: nodes and
+// dynamically provisioned volumes are named per cluster, metrics are live.
+var diffIgnoredResources = map[string]bool{
+	"nodes":             true,
+	"persistentvolumes": true,
+}
+
+// diffNoiseFields are set by the API server or controllers rather than by
+// whoever manages the object.
+var diffNoiseFields = [][]string{
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "generation"},
+	{"metadata", "selfLink"},
+	{"metadata", "namespace"},
+	{"metadata", "ownerReferences"},
+	{"status"},
+	{"spec", "clusterIP"},
+	{"spec", "clusterIPs"},
+	{"spec", "healthCheckNodePort"},
+	{"spec", "volumeName"},
+}
+
+var diffNoiseAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"kubernetes.io/service-account.uid",
+}
+
+func diffObjectLists(fromLists, toLists []kube.ResourceObjects, opts DiffOptions) DiffResponse {
+	response := DiffResponse{
+		NamespaceMap:  opts.NamespaceMap,
+		Objects:       []ObjectDiff{},
+		ServicePorts:  []DerivedDiff{},
+		IngressRoutes: []DerivedDiff{},
+		RBACBindings:  []DerivedDiff{},
+		Skipped:       []string{},
+	}
+	reverse := map[string]string{}
+	for from, to := range opts.NamespaceMap {
+		reverse[to] = from
+	}
+
+	fromIndex := indexDiffObjects(fromLists, opts.Namespace, opts.NamespaceMap, &response.Skipped, "from")
+	toNamespace := opts.Namespace
+	if mapped, ok := opts.NamespaceMap[opts.Namespace]; ok {
+		toNamespace = mapped
+	}
+	toIndex := indexDiffObjects(toLists, toNamespace, nil, &response.Skipped, "to")
+
+	keys := make([]diffKey, 0, len(fromIndex)+len(toIndex))
+	for key := range fromIndex {
+		keys = append(keys, key)
+	}
+	for key := range toIndex {
+		if _, ok := fromIndex[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	for _, key := range keys {
+		fromEntry, inFrom := fromIndex[key]
+		toEntry, inTo := toIndex[key]
+		item := ObjectDiff{Kind: key.kind, Namespace: key.namespace, Name: key.name}
+		if original, ok := reverse[key.namespace]; ok {
+			item.Namespace = original
+		}
+		if key.kind == "Namespace" {
+			if original, ok := reverse[key.name]; ok {
+				item.Name = original
+			}
+		}
+		if inFrom {
+			item.Namespace = fromEntry.namespace
+			item.Name = fromEntry.name
+		}
+		if item.Namespace != key.namespace {
+			item.ToNamespace = key.namespace
+		}
+		if item.Name != key.name {
+			item.ToName = key.name
+		}
+		switch {
+		case !inTo:
+			item.Change = diffRemoved
+			response.Summary.Removed++
+		case !inFrom:
+			item.Change = diffAdded
+			response.Summary.Added++
+		default:
+			item.Fields = diffFields(normalizeDiffObject(fromEntry.object, opts.NamespaceMap), normalizeDiffObject(toEntry.object, nil))
+			if len(item.Fields) == 0 {
+				response.Summary.Unchanged++
+				continue
+			}
+			item.Change = diffModified
+			response.Summary.Modified++
+		}
+		response.Objects = append(response.Objects, item)
+	}
+
+	response.ServicePorts = diffDerived(fromIndex, toIndex, "Service", opts.NamespaceMap, servicePortValues)
+	response.IngressRoutes = diffDerived(fromIndex, toIndex, "Ingress", opts.NamespaceMap, ingressRouteValues)
+	response.RBACBindings = append(
+		diffDerived(fromIndex, toIndex, "ClusterRoleBinding", opts.NamespaceMap, bindingValues),
+		diffDerived(fromIndex, toIndex, "RoleBinding", opts.NamespaceMap, bindingValues)...,
+	)
+	sort.Strings(response.Skipped)
+	return response
+}
+
+// indexDiffObjects keys objects by their coordinates in the "to" cluster.
+// Objects created by a controller or with a generated name are left out;
+// their owners are compared instead. Custom resources listed in several
+// versions are indexed once.
+func indexDiffObjects(lists []kube.ResourceObjects, namespace string, mapping map[string]string, skipped *[]string, side string) map[diffKey]diffEntry {
+	index := map[diffKey]diffEntry{}
+	for _, list := range lists {
+		if list.Group == "metrics.k8s.io" || (list.Group == "" && diffIgnoredResources[list.Resource]) {
+			continue
+		}
+		if list.Error != "" {
+			*skipped = append(*skipped, side+": "+list.Resource+": "+list.Error)
+			continue
+		}
+		if namespace != "" && !list.Namespaced && list.Kind != "Namespace" {
+			continue
+		}
+		for _, object := range list.Objects {
+			u := &unstructured.Unstructured{Object: object}
+			if u.GetGenerateName() != "" || v1.GetControllerOf(u) != nil {
+				continue
+			}
+			key := diffKey{group: list.Group, kind: list.Kind, namespace: mapNamespace(mapping, u.GetNamespace()), name: u.GetName()}
+			if list.Kind == "Namespace" {
+				if namespace != "" && u.GetName() != namespace {
+					continue
+				}
+				key.name = mapNamespace(mapping, u.GetName())
+			}
+			if _, ok := index[key]; !ok {
+				index[key] = diffEntry{namespace: u.GetNamespace(), name: u.GetName(), object: object}
+			}
+		}
+	}
+	return index
+}
+
+func mapNamespace(mapping map[string]string, namespace string) string {
+	if mapped, ok := mapping[namespace]; ok {
+		return mapped
+	}
+	return namespace
+}
+
+// normalizeDiffObject strips noise fields and, for the "from" side, maps
+// namespaces referenced by RBAC subjects.
+func normalizeDiffObject(object map[string]any, mapping map[string]string) map[string]any {
+	u := unstructured.Unstructured{Object: runtime.DeepCopyJSON(object)}
+	for _, field := range diffNoiseFields {
+		unstructured.RemoveNestedField(u.Object, field...)
+	}
+	if annotations := u.GetAnnotations(); annotations != nil {
+		for _, key := range diffNoiseAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(u.Object, "metadata", "annotations")
+		} else {
+			u.SetAnnotations(annotations)
+		}
+	}
+	// The cluster CA and service account tokens are issued per cluster.
+	if (u.GetKind() == "ConfigMap" && u.GetName() == "kube-root-ca.crt") ||
+		(u.GetKind() == "Secret" && u.Object["type"] == string(corev1.SecretTypeServiceAccountToken)) {
+		unstructured.RemoveNestedField(u.Object, "data")
+	}
+	if u.GetKind() == "Namespace" {
+		unstructured.RemoveNestedField(u.Object, "metadata", "name")
+		unstructured.RemoveNestedField(u.Object, "metadata", "labels", "kubernetes.io/metadata.name")
+	}
+	if ports, ok, _ := unstructured.NestedSlice(u.Object, "spec", "ports"); ok && u.GetKind() == "Service" {
+		for _, port := range ports {
+			if port, ok := port.(map[string]any); ok {
+				delete(port, "nodePort")
+			}
+		}
+		_ = unstructured.SetNestedSlice(u.Object, ports, "spec", "ports")
+	}
+	if subjects, ok, _ := unstructured.NestedSlice(u.Object, "subjects"); ok && len(mapping) > 0 {
+		for _, subject := range subjects {
+			if subject, ok := subject.(map[string]any); ok {
+				if ns, ok := subject["namespace"].(string); ok {
+					subject["namespace"] = mapNamespace(mapping, ns)
+				}
+			}
+		}
+		_ = unstructured.SetNestedSlice(u.Object, subjects, "subjects")
+	}
+	return u.Object
+}
+
+func diffFields(from, to map[string]any) []FieldChange {
+	changes := []FieldChange{}
+	diffValues("", from, to, &changes)
+	return changes
+}
+
+func diffValues(path string, from, to any, changes *[]FieldChange) {
+	switch f := from.(type) {
+	case map[string]any:
+		t, ok := to.(map[string]any)
+		if !ok {
+			*changes = append(*changes, FieldChange{Path: path, From: from, To: to})
+			return
+		}
+		keys := []string{}
+		for key := range f {
+			keys = append(keys, key)
+		}
+		for key := range t {
+			if _, ok := f[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fromValue, inFrom := f[key]
+			toValue, inTo := t[key]
+			child := fieldPath(path, key)
+			switch {
+			case !inFrom:
+				*changes = append(*changes, FieldChange{Path: child, To: toValue})
+			case !inTo:
+				*changes = append(*changes, FieldChange{Path: child, From: fromValue})
+			default:
+				diffValues(child, fromValue, toValue, changes)
+			}
+		}
+	case []any:
+		t, ok := to.([]any)
+		if !ok {
+			*changes = append(*changes, FieldChange{Path: path, From: from, To: to})
+			return
+		}
+		fromNamed, fromOK := namedItems(f)
+		toNamed, toOK := namedItems(t)
+		if fromOK && toOK {
+			diffValues(path, fromNamed, toNamed, changes)
+			return
+		}
+		for i := 0; i < len(f) || i < len(t); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(f):
+				*changes = append(*changes, FieldChange{Path: child, To: t[i]})
+			case i >= len(t):
+				*changes = append(*changes, FieldChange{Path: child, From: f[i]})
+			default:
+				diffValues(child, f[i], t[i], changes)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(from, to) {
+			*changes = append(*changes, FieldChange{Path: path, From: from, To: to})
+		}
+	}
+}
+
+// namedItems keys a list of objects by name, as containers, ports and env
+// vars are, so reordering or inserting one does not show up as a change to
+// every later element. Keys look like "[name=app]" to tell them from fields.
+func namedItems(items []any) (map[string]any, bool) {
+	named := map[string]any{}
+	for _, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		name, ok := object["name"].(string)
+		if !ok || name == "" {
+			return nil, false
+		}
+		if _, dup := named["[name="+name+"]"]; dup {
+			return nil, false
+		}
+		named["[name="+name+"]"] = item
+	}
+	return named, len(named) > 0
+}
+
+func fieldPath(path, key string) string {
+	switch {
+	case strings.HasPrefix(key, "[name="):
+		return path + key
+	case strings.ContainsAny(key, "./"):
+		return fmt.Sprintf("%s[%q]", path, key)
+	case path == "":
+		return key
+	}
+	return path + "." + key
+}
+
+// diffDerived compares the values derived from every object of kind on
+// both sides and reports those present on one side only.
+func diffDerived(fromIndex, toIndex map[diffKey]diffEntry, kind string, mapping map[string]string, derive func(map[string]any, map[string]string) []string) []DerivedDiff {
+	type side struct{ from, to []string }
+	objects := map[string]*side{}
+	for key, entry := range fromIndex {
+		if key.kind == kind {
+			name := diffObjectName(key)
+			if objects[name] == nil {
+				objects[name] = &side{}
+			}
+			objects[name].from = derive(entry.object, mapping)
+		}
+	}
+	for key, entry := range toIndex {
+		if key.kind == kind {
+			name := diffObjectName(key)
+			if objects[name] == nil {
+				objects[name] = &side{}
+			}
+			objects[name].to = derive(entry.object, nil)
+		}
+	}
+
+	diffs := []DerivedDiff{}
+	for name, values := range objects {
+		for _, value := range values.from {
+			if !containsString(values.to, value) {
+				diffs = append(diffs, DerivedDiff{Object: name, Change: diffRemoved, Value: value})
+			}
+		}
+		for _, value := range values.to {
+			if !containsString(values.from, value) {
+				diffs = append(diffs, DerivedDiff{Object: name, Change: diffAdded, Value: value})
+			}
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Object != diffs[j].Object {
+			return diffs[i].Object < diffs[j].Object
+		}
+		if diffs[i].Value != diffs[j].Value {
+			return diffs[i].Value < diffs[j].Value
+		}
+		return diffs[i].Change < diffs[j].Change
+	})
+	return diffs
+}
+
+func diffObjectName(key diffKey) string {
+	if key.namespace == "" {
+		return key.kind + "/" + key.name
+	}
+	return key.kind + " " + key.namespace + "/" + key.name
+}
+
+func servicePortValues(object map[string]any, _ map[string]string) []string {
+	var svc corev1.Service
+	if runtime.DefaultUnstructuredConverter.FromUnstructured(object, &svc) != nil {
+		return nil
+	}
+	values := []string{}
+	for _, port := range svc.Spec.Ports {
+		value := fmt.Sprintf("%d/%s -> %s", port.Port, port.Protocol, port.TargetPort.String())
+		if port.Name != "" {
+			value = port.Name + " " + value
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func ingressRouteValues(object map[string]any, _ map[string]string) []string {
+	var ingress networkingv1.Ingress
+	if runtime.DefaultUnstructuredConverter.FromUnstructured(object, &ingress) != nil {
+		return nil
+	}
+	values := []string{}
+	if backend := ingress.Spec.DefaultBackend; backend != nil {
+		values = append(values, "default -> "+ingressBackendString(*backend))
+	}
+	for _, rule := range ingress.Spec.Rules {
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			values = append(values, host+path.Path+" -> "+ingressBackendString(path.Backend))
+		}
+	}
+	return values
+}
+
+func ingressBackendString(backend networkingv1.IngressBackend) string {
+	if backend.Service == nil {
+		if backend.Resource != nil {
+			return backend.Resource.Kind + "/" + backend.Resource.Name
+		}
+		return ""
+	}
+	if backend.Service.Port.Name != "" {
+		return backend.Service.Name + ":" + backend.Service.Port.Name
+	}
+	return fmt.Sprintf("%s:%d", backend.Service.Name, backend.Service.Port.Number)
+}
+
+// bindingValues lists one grant per subject, in "to" namespaces.
+func bindingValues(object map[string]any, mapping map[string]string) []string {
+	var binding rbacv1.RoleBinding
+	if runtime.DefaultUnstructuredConverter.FromUnstructured(object, &binding) != nil {
+		return nil
+	}
+	values := []string{}
+	for _, subject := range binding.Subjects {
+		name := subject.Name
+		if subject.Namespace != "" {
+			name = mapNamespace(mapping, subject.Namespace) + "/" + name
+		}
+		values = append(values, subject.Kind+" "+name+" -> "+binding.RoleRef.Kind+"/"+binding.RoleRef.Name)
+	}
+	return values
+}
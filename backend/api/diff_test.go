@@ -0,0 +1,280 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+func testDiffObject(kind, namespace, name string, fields map[string]any) map[string]any {
+	metadata := map[string]any{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	object := map[string]any{"apiVersion": "v1", "kind": kind, "metadata": metadata}
+	for key, value := range fields {
+		if key == "metadata" {
+			for field, v := range value.(map[string]any) {
+				metadata[field] = v
+			}
+			continue
+		}
+		object[key] = value
+	}
+	return object
+}
+
+func testDiffList(group, resource, kind string, namespaced bool, objects ...map[string]any) kube.ResourceObjects {
+	return kube.ResourceObjects{
+		SnapshotResource: kube.SnapshotResource{Group: group, Version: "v1", Resource: resource, Kind: kind, Namespaced: namespaced},
+		Objects:          objects,
+	}
+}
+
+func changedPaths(changes []FieldChange) string {
+	paths := []string{}
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestParseNamespaceMap(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{value: "", want: map[string]string{}},
+		{value: "staging=prod", want: map[string]string{"staging": "prod"}},
+		{value: " staging=prod , dev=qa ,", want: map[string]string{"staging": "prod", "dev": "qa"}},
+		{value: "staging", wantErr: true},
+		{value: "=prod", wantErr: true},
+		{value: "staging=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseNamespaceMap(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNamespaceMap: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for from, to := range tt.want {
+				if got[from] != to {
+					t.Errorf("%s: got %q, want %q", from, got[from], to)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeDiffObjectStripsNoise(t *testing.T) {
+	tests := []struct {
+		name string
+		from map[string]any
+		to   map[string]any
+		want string
+	}{
+		{
+			name: "server-managed metadata",
+			from: testDiffObject("ConfigMap", "a", "cfg", map[string]any{"metadata": map[string]any{
+				"uid": "1", "resourceVersion": "10", "generation": int64(1), "creationTimestamp": "2026-01-01T00:00:00Z",
+				"managedFields": []any{map[string]any{"manager": "kubectl"}},
+			}}),
+			to: testDiffObject("ConfigMap", "a", "cfg", map[string]any{"metadata": map[string]any{
+				"uid": "2", "resourceVersion": "99", "generation": int64(4), "creationTimestamp": "2026-02-01T00:00:00Z",
+			}}),
+		},
+		{
+			name: "status",
+			from: testDiffObject("Pod", "a", "p", map[string]any{"status": map[string]any{"phase": "Running"}}),
+			to:   testDiffObject("Pod", "a", "p", map[string]any{"status": map[string]any{"phase": "Pending"}}),
+		},
+		{
+			name: "noise annotations leave no empty map",
+			from: testDiffObject("Deployment", "a", "web", map[string]any{"metadata": map[string]any{"annotations": map[string]any{
+				"deployment.kubernetes.io/revision":                "3",
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			}}}),
+			to: testDiffObject("Deployment", "a", "web", nil),
+		},
+		{
+			name: "other annotations are kept",
+			from: testDiffObject("Deployment", "a", "web", map[string]any{"metadata": map[string]any{"annotations": map[string]any{
+				"deployment.kubernetes.io/revision": "3", "team": "a",
+			}}}),
+			to: testDiffObject("Deployment", "a", "web", map[string]any{"metadata": map[string]any{"annotations": map[string]any{
+				"deployment.kubernetes.io/revision": "7", "team": "b",
+			}}}),
+			want: "metadata.annotations.team",
+		},
+		{
+			name: "service cluster IPs and node ports",
+			from: testDiffObject("Service", "a", "web", map[string]any{"spec": map[string]any{
+				"clusterIP": "10.0.0.1", "clusterIPs": []any{"10.0.0.1"},
+				"ports": []any{map[string]any{"name": "http", "port": int64(80), "nodePort": int64(30080)}},
+			}}),
+			to: testDiffObject("Service", "a", "web", map[string]any{"spec": map[string]any{
+				"clusterIP": "10.1.0.1", "clusterIPs": []any{"10.1.0.1"},
+				"ports": []any{map[string]any{"name": "http", "port": int64(80), "nodePort": int64(31999)}},
+			}}),
+		},
+		{
+			name: "service port changes are kept",
+			from: testDiffObject("Service", "a", "web", map[string]any{"spec": map[string]any{
+				"ports": []any{map[string]any{"name": "http", "port": int64(80)}},
+			}}),
+			to: testDiffObject("Service", "a", "web", map[string]any{"spec": map[string]any{
+				"ports": []any{map[string]any{"name": "http", "port": int64(8080)}},
+			}}),
+			want: "spec.ports[name=http].port",
+		},
+		{
+			name: "configmap data is compared",
+			from: testDiffObject("ConfigMap", "a", "cfg", map[string]any{"data": map[string]any{"mode": "debug"}}),
+			to:   testDiffObject("ConfigMap", "a", "cfg", map[string]any{"data": map[string]any{"mode": "release"}}),
+			want: "data.mode",
+		},
+		{
+			name: "cluster CA bundle",
+			from: testDiffObject("ConfigMap", "a", "kube-root-ca.crt", map[string]any{"data": map[string]any{"ca.crt": "one"}}),
+			to:   testDiffObject("ConfigMap", "a", "kube-root-ca.crt", map[string]any{"data": map[string]any{"ca.crt": "two"}}),
+		},
+		{
+			name: "service account tokens",
+			from: testDiffObject("Secret", "a", "token", map[string]any{"type": "kubernetes.io/service-account-token", "data": map[string]any{"token": "hmac-sha256:aa"}}),
+			to:   testDiffObject("Secret", "a", "token", map[string]any{"type": "kubernetes.io/service-account-token", "data": map[string]any{"token": "hmac-sha256:bb"}}),
+		},
+		{
+			name: "opaque secret hashes are compared",
+			from: testDiffObject("Secret", "a", "db", map[string]any{"type": "Opaque", "data": map[string]any{"password": "hmac-sha256:aa"}}),
+			to:   testDiffObject("Secret", "a", "db", map[string]any{"type": "Opaque", "data": map[string]any{"password": "hmac-sha256:bb", "user": "hmac-sha256:cc"}}),
+			want: "data.password,data.user",
+		},
+		{
+			name: "namespace name",
+			from: testDiffObject("Namespace", "", "staging", map[string]any{"metadata": map[string]any{"labels": map[string]any{"kubernetes.io/metadata.name": "staging"}}}),
+			to:   testDiffObject("Namespace", "", "prod", map[string]any{"metadata": map[string]any{"labels": map[string]any{"kubernetes.io/metadata.name": "prod"}}}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedPaths(diffFields(normalizeDiffObject(tt.from, nil), normalizeDiffObject(tt.to, nil)))
+			if got != tt.want {
+				t.Errorf("got changes %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDiffObjectLeavesInputAlone(t *testing.T) {
+	object := testDiffObject("Pod", "a", "p", map[string]any{"status": map[string]any{"phase": "Running"}})
+	normalizeDiffObject(object, nil)
+	if _, ok := object["status"]; !ok {
+		t.Error("normalizeDiffObject modified its input")
+	}
+}
+
+func TestDiffObjectListsNamespaceMapping(t *testing.T) {
+	binding := func(namespace, subjectNamespace string) map[string]any {
+		return testDiffObject("RoleBinding", namespace, "deployer", map[string]any{
+			"roleRef":  map[string]any{"kind": "Role", "name": "edit"},
+			"subjects": []any{map[string]any{"kind": "ServiceAccount", "name": "ci", "namespace": subjectNamespace}},
+		})
+	}
+	from := []kube.ResourceObjects{
+		testDiffList("", "namespaces", "Namespace", false, testDiffObject("Namespace", "", "staging", nil), testDiffObject("Namespace", "", "tools", nil)),
+		testDiffList("", "configmaps", "ConfigMap", true,
+			testDiffObject("ConfigMap", "staging", "same", map[string]any{"data": map[string]any{"k": "v"}}),
+			testDiffObject("ConfigMap", "staging", "changed", map[string]any{"data": map[string]any{"k": "v"}}),
+			testDiffObject("ConfigMap", "staging", "removed", nil),
+			testDiffObject("ConfigMap", "tools", "unmapped", nil),
+		),
+		testDiffList("rbac.authorization.k8s.io", "rolebindings", "RoleBinding", true, binding("staging", "staging")),
+		testDiffList("", "nodes", "Node", false, testDiffObject("Node", "", "node-a", nil)),
+	}
+	to := []kube.ResourceObjects{
+		testDiffList("", "namespaces", "Namespace", false, testDiffObject("Namespace", "", "prod", nil), testDiffObject("Namespace", "", "tools", nil)),
+		testDiffList("", "configmaps", "ConfigMap", true,
+			testDiffObject("ConfigMap", "prod", "same", map[string]any{"data": map[string]any{"k": "v"}}),
+			testDiffObject("ConfigMap", "prod", "changed", map[string]any{"data": map[string]any{"k": "w"}}),
+			testDiffObject("ConfigMap", "prod", "added", nil),
+			testDiffObject("ConfigMap", "tools", "unmapped", nil),
+		),
+		testDiffList("rbac.authorization.k8s.io", "rolebindings", "RoleBinding", true, binding("prod", "prod")),
+		testDiffList("", "nodes", "Node", false, testDiffObject("Node", "", "node-b", nil)),
+	}
+
+	response := diffObjectLists(from, to, DiffOptions{NamespaceMap: map[string]string{"staging": "prod"}})
+
+	want := []ObjectDiff{
+		{Kind: "ConfigMap", Namespace: "staging", Name: "added", ToNamespace: "prod", Change: diffAdded},
+		{Kind: "ConfigMap", Namespace: "staging", Name: "changed", ToNamespace: "prod", Change: diffModified},
+		{Kind: "ConfigMap", Namespace: "staging", Name: "removed", ToNamespace: "prod", Change: diffRemoved},
+	}
+	if len(response.Objects) != len(want) {
+		t.Fatalf("got %d objects %+v, want %d", len(response.Objects), response.Objects, len(want))
+	}
+	for i, w := range want {
+		got := response.Objects[i]
+		if got.Kind != w.Kind || got.Namespace != w.Namespace || got.Name != w.Name || got.ToNamespace != w.ToNamespace || got.Change != w.Change {
+			t.Errorf("object %d: got %+v, want %+v", i, got, w)
+		}
+	}
+	if got := changedPaths(response.Objects[1].Fields); got != "data.k" {
+		t.Errorf("got changes %q, want data.k", got)
+	}
+	if response.Summary.Unchanged != 5 {
+		t.Errorf("got %d unchanged, want 5 (namespaces, configmaps and the binding)", response.Summary.Unchanged)
+	}
+	if len(response.RBACBindings) != 0 {
+		t.Errorf("mapped binding subjects reported as drift: %+v", response.RBACBindings)
+	}
+}
+
+func TestDiffObjectListsNamespaceFilter(t *testing.T) {
+	from := []kube.ResourceObjects{
+		testDiffList("", "namespaces", "Namespace", false, testDiffObject("Namespace", "", "staging", nil), testDiffObject("Namespace", "", "tools", nil)),
+		testDiffList("", "configmaps", "ConfigMap", true, testDiffObject("ConfigMap", "staging", "cfg", nil)),
+		testDiffList("rbac.authorization.k8s.io", "clusterroles", "ClusterRole", false, testDiffObject("ClusterRole", "", "admin", nil)),
+	}
+	to := []kube.ResourceObjects{
+		testDiffList("", "namespaces", "Namespace", false, testDiffObject("Namespace", "", "prod", nil)),
+		testDiffList("", "configmaps", "ConfigMap", true, testDiffObject("ConfigMap", "prod", "cfg", nil)),
+	}
+
+	response := diffObjectLists(from, to, DiffOptions{Namespace: "staging", NamespaceMap: map[string]string{"staging": "prod"}})
+	if response.Changed() || response.Summary.Unchanged != 2 {
+		t.Errorf("got %+v with %d unchanged, want the namespace and its ConfigMap to match", response.Objects, response.Summary.Unchanged)
+	}
+}
+
+func TestDiffObjectListsSkipsControlledAndFailedLists(t *testing.T) {
+	controlled := testDiffObject("Pod", "a", "web-1", map[string]any{"metadata": map[string]any{
+		"ownerReferences": []any{map[string]any{"apiVersion": "apps/v1", "kind": "ReplicaSet", "name": "web", "uid": "1", "controller": true}},
+	}})
+	generated := testDiffObject("Pod", "a", "job-x1", map[string]any{"metadata": map[string]any{"generateName": "job-"}})
+	from := []kube.ResourceObjects{testDiffList("", "pods", "Pod", true, controlled, generated)}
+	failed := testDiffList("", "pods", "Pod", true)
+	failed.Error = "forbidden"
+	to := []kube.ResourceObjects{failed}
+
+	response := diffObjectLists(from, to, DiffOptions{})
+	if response.Changed() {
+		t.Errorf("got %+v, want controlled and generated pods left out", response.Objects)
+	}
+	if len(response.Skipped) != 1 || response.Skipped[0] != "to: pods: forbidden" {
+		t.Errorf("got skipped %v", response.Skipped)
+	}
+}
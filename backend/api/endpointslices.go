@@ -5,9 +5,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type EndpointSliceItem struct {
@@ -24,12 +24,12 @@ type EndpointSliceList struct {
 	Continue string              `json:"continue"`
 }
 
-func EndpointSlicesHandler(client kubernetes.Interface) http.HandlerFunc {
+func EndpointSlicesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -40,18 +40,30 @@ func EndpointSlicesHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).EndpointSlices)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]EndpointSliceItem, 0, len(list.Items))
-		for _, slice := range list.Items {
-			items = append(items, mapEndpointSlice(slice))
+		slices, err := lister.EndpointSlices(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(slices, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]EndpointSliceItem, 0, len(page))
+		for _, slice := range page {
+			items = append(items, mapEndpointSlice(*slice))
 		}
 
-		respondJSON(w, http.StatusOK, EndpointSliceList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, EndpointSliceList{Items: items, Continue: next})
 	}
 }
 
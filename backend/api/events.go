@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// EventItem is a Kubernetes Event, or with aggregation all events sharing
+// object, type, reason and message. Count includes the repetitions the
+// API server already folded into a series.
+type EventItem struct {
+	Object    ObjectRef `json:"object"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Source    string    `json:"source,omitempty"`
+	Count     int32     `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type EventList struct {
+	Items []EventItem `json:"items"`
+}
+
+// EventTimeline holds the events of an object and of the objects related to
+// it through controller references, ordered by when they were first seen.
+type EventTimeline struct {
+	Objects []ObjectRef `json:"objects"`
+	Events  []EventItem `json:"events"`
+}
+
+// EventsHandler lists events newest first. Filters: ns, kind, name, type
+// (Normal or Warning) and reason; aggregate=false keeps repeated events
+// apart.
+func EventsHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		query := r.URL.Query()
+		namespace := query.Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
+		}
+		eventType := query.Get("type")
+		switch {
+		case eventType == "":
+		case strings.EqualFold(eventType, corev1.EventTypeNormal):
+			eventType = corev1.EventTypeNormal
+		case strings.EqualFold(eventType, corev1.EventTypeWarning):
+			eventType = corev1.EventTypeWarning
+		default:
+			respondError(w, http.StatusBadRequest, "invalid type: must be Normal or Warning")
+			return
+		}
+		kind := query.Get("kind")
+		name := query.Get("name")
+		reason := query.Get("reason")
+
+		events, err := collectEvents(ctx, cache, namespace)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		items := []EventItem{}
+		for _, event := range events {
+			if kind != "" && !strings.EqualFold(event.Object.Kind, kind) {
+				continue
+			}
+			if name != "" && event.Object.Name != name {
+				continue
+			}
+			if eventType != "" && event.Type != eventType {
+				continue
+			}
+			if reason != "" && !strings.EqualFold(event.Reason, reason) {
+				continue
+			}
+			items = append(items, event)
+		}
+		if query.Get("aggregate") != "false" {
+			items = aggregateEvents(items)
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].LastSeen.After(items[j].LastSeen)
+		})
+
+		respondJSON(w, http.StatusOK, EventList{Items: items})
+	}
+}
+
+// EventTimelineHandler merges the events of an object with those of its
+// controllers and of what it controls, e.g. Deployment, ReplicaSets and
+// Pods. Objects that no longer exist still get their events.
+func EventTimelineHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		query := r.URL.Query()
+		namespace := query.Get("ns")
+		kind := query.Get("kind")
+		name := query.Get("name")
+		if namespace == "" || kind == "" || name == "" {
+			respondError(w, http.StatusBadRequest, "ns, kind and name are required")
+			return
+		}
+
+		objects, err := relatedObjects(ctx, cache, ObjectRef{Kind: canonicalKind(kind), Namespace: namespace, Name: name})
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		related := map[ObjectRef]bool{}
+		for _, object := range objects {
+			related[object] = true
+		}
+
+		events, err := collectEvents(ctx, cache, namespace)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		timeline := EventTimeline{Objects: objects, Events: []EventItem{}}
+		for _, event := range events {
+			if related[event.Object] {
+				timeline.Events = append(timeline.Events, event)
+			}
+		}
+		sort.SliceStable(timeline.Events, func(i, j int) bool {
+			if timeline.Events[i].FirstSeen.Equal(timeline.Events[j].FirstSeen) {
+				return timeline.Events[i].LastSeen.Before(timeline.Events[j].LastSeen)
+			}
+			return timeline.Events[i].FirstSeen.Before(timeline.Events[j].FirstSeen)
+		})
+
+		respondJSON(w, http.StatusOK, timeline)
+	}
+}
+
+// collectEvents reads both event APIs concurrently. They serve the same
+// objects, so events are deduplicated by UID. Either API alone is enough,
+// since RBAC may allow only one of them.
+func collectEvents(ctx context.Context, cache *kube.Cache, namespace string) ([]EventItem, error) {
+	var coreEvents []*corev1.Event
+	var v1Events []*eventsv1.Event
+	var coreErr, v1Err error
+	v1Read := false
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Events)
+		if err != nil {
+			coreErr = err
+			return
+		}
+		coreEvents, coreErr = lister.Events(namespace).List(labels.Everything())
+	}()
+	go func() {
+		defer wg.Done()
+		version, err := cache.ServedVersion("events.k8s.io", "events", "v1")
+		if err != nil || version == "" {
+			v1Err = err
+			return
+		}
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).EventsV1)
+		if err != nil {
+			v1Err = err
+			return
+		}
+		v1Events, v1Err = lister.Events(namespace).List(labels.Everything())
+		v1Read = v1Err == nil
+	}()
+	wg.Wait()
+	if coreErr != nil && !v1Read {
+		return nil, coreErr
+	}
+
+	seen := map[types.UID]bool{}
+	items := []EventItem{}
+	for _, event := range coreEvents {
+		if !seen[event.UID] {
+			seen[event.UID] = true
+			items = append(items, mapCoreEvent(event))
+		}
+	}
+	for _, event := range v1Events {
+		if !seen[event.UID] {
+			seen[event.UID] = true
+			items = append(items, mapEventV1(event))
+		}
+	}
+	return items, nil
+}
+
+func mapCoreEvent(event *corev1.Event) EventItem {
+	item := EventItem{
+		Object: ObjectRef{
+			Kind:      event.InvolvedObject.Kind,
+			Namespace: event.InvolvedObject.Namespace,
+			Name:      event.InvolvedObject.Name,
+		},
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Source:    event.ReportingController,
+		Count:     event.Count,
+		FirstSeen: firstTime(event.FirstTimestamp.Time, event.EventTime.Time, event.CreationTimestamp.Time),
+		LastSeen:  firstTime(event.LastTimestamp.Time, event.EventTime.Time),
+	}
+	if item.Source == "" {
+		item.Source = event.Source.Component
+	}
+	if event.Series != nil {
+		item.Count = event.Series.Count
+		item.LastSeen = firstTime(event.Series.LastObservedTime.Time, item.LastSeen)
+	}
+	return normalizeEvent(item)
+}
+
+func mapEventV1(event *eventsv1.Event) EventItem {
+	item := EventItem{
+		Object: ObjectRef{
+			Kind:      event.Regarding.Kind,
+			Namespace: event.Regarding.Namespace,
+			Name:      event.Regarding.Name,
+		},
+		Type:      event.Type,
+		Reason:    event.Reason,
+		Message:   event.Note,
+		Source:    event.ReportingController,
+		Count:     event.DeprecatedCount,
+		FirstSeen: firstTime(event.DeprecatedFirstTimestamp.Time, event.EventTime.Time, event.CreationTimestamp.Time),
+		LastSeen:  firstTime(event.DeprecatedLastTimestamp.Time, event.EventTime.Time),
+	}
+	if item.Source == "" {
+		item.Source = event.DeprecatedSource.Component
+	}
+	if event.Series != nil {
+		item.Count = event.Series.Count
+		item.LastSeen = firstTime(event.Series.LastObservedTime.Time, item.LastSeen)
+	}
+	return normalizeEvent(item)
+}
+
+// normalizeEvent fills what older reporters leave out: events count at
+// least once and were last seen no earlier than first seen.
+func normalizeEvent(item EventItem) EventItem {
+	if item.Count < 1 {
+		item.Count = 1
+	}
+	if item.LastSeen.Before(item.FirstSeen) {
+		item.LastSeen = item.FirstSeen
+	}
+	return item
+}
+
+func firstTime(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func aggregateEvents(events []EventItem) []EventItem {
+	type key struct {
+		object                     ObjectRef
+		eventType, reason, message string
+	}
+	index := map[key]int{}
+	out := []EventItem{}
+	for _, event := range events {
+		k := key{object: event.Object, eventType: event.Type, reason: event.Reason, message: event.Message}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, event)
+			continue
+		}
+		out[i].Count += event.Count
+		if event.FirstSeen.Before(out[i].FirstSeen) {
+			out[i].FirstSeen = event.FirstSeen
+		}
+		if event.LastSeen.After(out[i].LastSeen) {
+			out[i].LastSeen = event.LastSeen
+			out[i].Source = event.Source
+		}
+	}
+	return out
+}
+
+var workloadKinds = []string{"Pod", "ReplicaSet", "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob"}
+
+func canonicalKind(kind string) string {
+	for _, known := range workloadKinds {
+		if strings.EqualFold(kind, known) {
+			return known
+		}
+	}
+	return kind
+}
+
+// relatedObjects returns object, its controllers up the chain and the
+// objects it controls down the chain, following controller references of
+// Pods, ReplicaSets and Jobs.
+func relatedObjects(ctx context.Context, cache *kube.Cache, object ObjectRef) ([]ObjectRef, error) {
+	pods, err := listPods(ctx, cache, object.Namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := listReplicaSets(ctx, cache, object.Namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := listJobs(ctx, cache, object.Namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	controllers := map[ObjectRef]ObjectRef{}
+	children := map[ObjectRef][]ObjectRef{}
+	link := func(kind string, meta v1.ObjectMeta) {
+		owner := v1.GetControllerOfNoCopy(&meta)
+		if owner == nil {
+			return
+		}
+		child := ObjectRef{Kind: kind, Namespace: meta.Namespace, Name: meta.Name}
+		parent := ObjectRef{Kind: owner.Kind, Namespace: meta.Namespace, Name: owner.Name}
+		controllers[child] = parent
+		children[parent] = append(children[parent], child)
+	}
+	for _, pod := range pods {
+		link("Pod", pod.ObjectMeta)
+	}
+	for _, rs := range replicaSets {
+		link("ReplicaSet", rs.ObjectMeta)
+	}
+	for _, job := range jobs {
+		link("Job", job.ObjectMeta)
+	}
+
+	objects := []ObjectRef{object}
+	seen := map[ObjectRef]bool{object: true}
+	for current := object; ; {
+		parent, ok := controllers[current]
+		if !ok || seen[parent] {
+			break
+		}
+		seen[parent] = true
+		objects = append(objects, parent)
+		current = parent
+	}
+	for queue := []ObjectRef{object}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range children[queue[0]] {
+			if !seen[child] {
+				seen[child] = true
+				objects = append(objects, child)
+				queue = append(queue, child)
+			}
+		}
+	}
+	return objects, nil
+}
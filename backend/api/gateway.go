@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const gatewayGroup = "gateway.networking.k8s.io"
+
+type GatewayListenerMapping struct {
+	Namespace string `json:"namespace"`
+	Gateway   string `json:"gateway"`
+	Class     string `json:"class"`
+	Listener  string `json:"listener"`
+	Hostname  string `json:"hostname"`
+	Port      int32  `json:"port"`
+	Protocol  string `json:"protocol"`
+}
+
+type RouteIntent struct {
+	Namespace string   `json:"namespace"`
+	Route     string   `json:"route"`
+	Kind      string   `json:"kind"`
+	Gateways  []string `json:"gateways"`
+	Host      string   `json:"host"`
+	Path      string   `json:"path"`
+	Service   string   `json:"service"`
+	Port      string   `json:"port"`
+}
+
+// The Gateway API types are decoded locally from unstructured objects; only
+// the fields KUBI renders are declared.
+type gatewayClass struct {
+	v1.ObjectMeta `json:"metadata"`
+	Spec          struct {
+		ControllerName string `json:"controllerName"`
+	} `json:"spec"`
+}
+
+type gateway struct {
+	v1.ObjectMeta `json:"metadata"`
+	Spec          struct {
+		GatewayClassName string            `json:"gatewayClassName"`
+		Listeners        []gatewayListener `json:"listeners"`
+	} `json:"spec"`
+}
+
+type gatewayListener struct {
+	Name     string  `json:"name"`
+	Hostname *string `json:"hostname"`
+	Port     int32   `json:"port"`
+	Protocol string  `json:"protocol"`
+}
+
+type gatewayRoute struct {
+	Kind          string `json:"kind"`
+	v1.ObjectMeta `json:"metadata"`
+	Spec          struct {
+		ParentRefs []gatewayRef  `json:"parentRefs"`
+		Hostnames  []string      `json:"hostnames"`
+		Rules      []gatewayRule `json:"rules"`
+	} `json:"spec"`
+}
+
+// gatewayRef covers both parentRefs and backendRefs.
+type gatewayRef struct {
+	Group       *string `json:"group"`
+	Kind        *string `json:"kind"`
+	Namespace   *string `json:"namespace"`
+	Name        string  `json:"name"`
+	SectionName *string `json:"sectionName"`
+	Port        *int32  `json:"port"`
+}
+
+type gatewayRule struct {
+	Matches     []gatewayMatch `json:"matches"`
+	BackendRefs []gatewayRef   `json:"backendRefs"`
+}
+
+type gatewayMatch struct {
+	Path *struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"path"`
+	Method *struct {
+		Service string `json:"service"`
+		Method  string `json:"method"`
+	} `json:"method"`
+}
+
+type gatewaySources struct {
+	classes  []gatewayClass
+	gateways []gateway
+	routes   []gatewayRoute
+	// unavailable names the resources that could not be read.
+	unavailable []string
+}
+
+type gatewayResource struct {
+	resource string
+	versions []string
+}
+
+var gatewayRouteResources = []gatewayResource{
+	{resource: "httproutes", versions: []string{"v1", "v1beta1"}},
+	{resource: "grpcroutes", versions: []string{"v1", "v1alpha2"}},
+	{resource: "tcproutes", versions: []string{"v1alpha2"}},
+}
+
+// listGatewayAPI returns nothing, without error, when the Gateway API CRDs
+// are not installed. Resources the credentials may not list are left out
+// and named in unavailable.
+func listGatewayAPI(ctx context.Context, cache *kube.Cache, namespace string, selector labels.Selector) (gatewaySources, error) {
+	sources := gatewaySources{}
+
+	// GatewayClasses are cluster-scoped and shared by every Gateway, so the
+	// pod selector does not apply to them.
+	classes, err := sources.list(ctx, cache, gatewayResource{resource: "gatewayclasses", versions: []string{"v1", "v1beta1"}}, v1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return sources, err
+	}
+	for _, item := range classes {
+		var class gatewayClass
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &class); err == nil {
+			sources.classes = append(sources.classes, class)
+		}
+	}
+
+	gateways, err := sources.list(ctx, cache, gatewayResource{resource: "gateways", versions: []string{"v1", "v1beta1"}}, namespace, selector)
+	if err != nil {
+		return sources, err
+	}
+	for _, item := range gateways {
+		var gw gateway
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &gw); err == nil {
+			sources.gateways = append(sources.gateways, gw)
+		}
+	}
+
+	for _, resource := range gatewayRouteResources {
+		routes, err := sources.list(ctx, cache, resource, namespace, selector)
+		if err != nil {
+			return sources, err
+		}
+		for _, item := range routes {
+			var route gatewayRoute
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &route); err == nil {
+				sources.routes = append(sources.routes, route)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+func (s *gatewaySources) list(ctx context.Context, cache *kube.Cache, resource gatewayResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	objects, err := listGatewayObjects(ctx, cache, resource, namespace, selector)
+	if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+		s.unavailable = append(s.unavailable, resource.resource)
+		return nil, nil
+	}
+	return objects, err
+}
+
+func listGatewayObjects(ctx context.Context, cache *kube.Cache, resource gatewayResource, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	version, err := cache.ServedVersion(gatewayGroup, resource.resource, resource.versions...)
+	if err != nil || version == "" {
+		return nil, err
+	}
+	lister, err := namespaced(ctx, cache, namespace, dynamicLister(schema.GroupVersionResource{Group: gatewayGroup, Version: version, Resource: resource.resource}))
+	if err != nil {
+		return nil, err
+	}
+
+	var items []runtime.Object
+	if namespace == v1.NamespaceAll {
+		items, err = lister.List(selector)
+	} else {
+		items, err = lister.ByNamespace(namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if object, ok := item.(*unstructured.Unstructured); ok {
+			objects = append(objects, object)
+		}
+	}
+	sortObjects(objects)
+	return objects, nil
+}
+
+func mapGatewayListeners(gateways []gateway) []GatewayListenerMapping {
+	items := []GatewayListenerMapping{}
+	for _, gw := range gateways {
+		for _, listener := range gw.Spec.Listeners {
+			hostname := "*"
+			if listener.Hostname != nil && *listener.Hostname != "" {
+				hostname = *listener.Hostname
+			}
+			items = append(items, GatewayListenerMapping{
+				Namespace: gw.Namespace,
+				Gateway:   gw.Name,
+				Class:     gw.Spec.GatewayClassName,
+				Listener:  listener.Name,
+				Hostname:  hostname,
+				Port:      listener.Port,
+				Protocol:  listener.Protocol,
+			})
+		}
+	}
+	return items
+}
+
+func mapRouteIntents(routes []gatewayRoute) []RouteIntent {
+	items := []RouteIntent{}
+	for _, route := range routes {
+		gateways := routeGateways(route)
+		hosts := route.Spec.Hostnames
+		if len(hosts) == 0 {
+			hosts = []string{"*"}
+		}
+		for _, rule := range route.Spec.Rules {
+			paths := []string{}
+			for _, match := range rule.Matches {
+				paths = append(paths, routeMatchString(match))
+			}
+			if len(paths) == 0 {
+				paths = []string{"*"}
+			}
+			for _, backend := range rule.BackendRefs {
+				if !isServiceRef(backend) {
+					continue
+				}
+				// Cross-namespace backends (allowed by a ReferenceGrant) are
+				// qualified; same-namespace ones read like Ingress backends.
+				service := backend.Name
+				if namespace := refNamespace(backend, route.Namespace); namespace != route.Namespace {
+					service = namespace + "/" + backend.Name
+				}
+				port := ""
+				if backend.Port != nil {
+					port = itoa(int(*backend.Port))
+				}
+				for _, host := range hosts {
+					for _, path := range paths {
+						items = append(items, RouteIntent{
+							Namespace: route.Namespace,
+							Route:     route.Name,
+							Kind:      route.Kind,
+							Gateways:  gateways,
+							Host:      host,
+							Path:      path,
+							Service:   service,
+							Port:      port,
+						})
+					}
+				}
+			}
+		}
+	}
+	return items
+}
+
+// routeGateways returns the namespace/name of every Gateway the route
+// attaches to, with the listener section when one is named.
+func routeGateways(route gatewayRoute) []string {
+	parents := []string{}
+	for _, parent := range route.Spec.ParentRefs {
+		if !isGatewayRef(parent) {
+			continue
+		}
+		name := refNamespace(parent, route.Namespace) + "/" + parent.Name
+		if parent.SectionName != nil && *parent.SectionName != "" {
+			name += ":" + *parent.SectionName
+		}
+		parents = append(parents, name)
+	}
+	return parents
+}
+
+func routeMatchString(match gatewayMatch) string {
+	switch {
+	case match.Path != nil:
+		if match.Path.Type == "Exact" {
+			return "=" + match.Path.Value
+		}
+		if match.Path.Type == "RegularExpression" {
+			return "~" + match.Path.Value
+		}
+		return match.Path.Value
+	case match.Method != nil:
+		return strings.TrimSuffix(match.Method.Service+"/"+match.Method.Method, "/")
+	}
+	return "*"
+}
+
+func isGatewayRef(ref gatewayRef) bool {
+	return (ref.Group == nil || *ref.Group == gatewayGroup) && (ref.Kind == nil || *ref.Kind == "Gateway")
+}
+
+func isServiceRef(ref gatewayRef) bool {
+	return (ref.Group == nil || *ref.Group == "") && (ref.Kind == nil || *ref.Kind == "Service")
+}
+
+func refNamespace(ref gatewayRef, fallback string) string {
+	if ref.Namespace != nil && *ref.Namespace != "" {
+		return *ref.Namespace
+	}
+	return fallback
+}
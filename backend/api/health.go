@@ -4,18 +4,27 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
 )
 
+type CacheStatus struct {
+	Resource string `json:"resource"`
+	Synced   bool   `json:"synced"`
+	Error    string `json:"error,omitempty"`
+}
+
 type Health struct {
-	OK            bool      `json:"ok"`
-	Timestamp     time.Time `json:"timestamp"`
-	Version       string    `json:"version"`
-	UptimeSeconds int64     `json:"uptimeSeconds"`
-	Context       string    `json:"context"`
-	Namespace     string    `json:"namespace"`
+	OK            bool          `json:"ok"`
+	Timestamp     time.Time     `json:"timestamp"`
+	Version       string        `json:"version"`
+	UptimeSeconds int64         `json:"uptimeSeconds"`
+	Context       string        `json:"context"`
+	Namespace     string        `json:"namespace"`
+	Caches        []CacheStatus `json:"caches"`
 }
 
-func HealthHandler(version string, started time.Time, namespace, context string) http.HandlerFunc {
+func HealthHandler(version string, started time.Time, namespace, context string, caches []kube.ResourceStatus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		payload := Health{
 			OK:            true,
@@ -24,9 +33,22 @@ func HealthHandler(version string, started time.Time, namespace, context string)
 			UptimeSeconds: int64(time.Since(started).Seconds()),
 			Context:       context,
 			Namespace:     namespace,
+			Caches:        mapCacheStatus(caches),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(payload)
 	}
 }
+
+func mapCacheStatus(items []kube.ResourceStatus) []CacheStatus {
+	out := make([]CacheStatus, 0, len(items))
+	for _, item := range items {
+		out = append(out, CacheStatus{
+			Resource: item.Resource,
+			Synced:   item.Synced,
+			Error:    item.Error,
+		})
+	}
+	return out
+}
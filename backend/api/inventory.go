@@ -5,16 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
-	"strings"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 )
 
 type CRDItem struct {
@@ -47,7 +46,7 @@ type InventoryResponse struct {
 	Components []ComponentDetection `json:"components"`
 }
 
-func InventoryHandler(client kubernetes.Interface, extClient apiextclient.Interface) http.HandlerFunc {
+func InventoryHandler(cache *kube.Cache, extClient apiextclient.Interface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
@@ -60,13 +59,13 @@ func InventoryHandler(client kubernetes.Interface, extClient apiextclient.Interf
 
 		crds, err := extClient.ApiextensionsV1().CustomResourceDefinitions().List(ctx, opts)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		components, err := detectComponents(ctx, client)
+		components, err := detectComponents(ctx, cache)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
@@ -79,7 +78,7 @@ func InventoryHandler(client kubernetes.Interface, extClient apiextclient.Interf
 	}
 }
 
-func CRDObjectsHandler(restCfg *rest.Config) http.HandlerFunc {
+func CRDObjectsHandler(dynamicClient dynamic.Interface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
@@ -98,22 +97,17 @@ func CRDObjectsHandler(restCfg *rest.Config) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		dynamicClient, err := dynamic.NewForConfig(restCfg)
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
-		}
-
 		gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resourceName}
 		resource := dynamicClient.Resource(gvr)
 		var list *unstructured.UnstructuredList
+		var err error
 		if namespace == v1.NamespaceAll {
 			list, err = resource.List(ctx, v1.ListOptions{})
 		} else {
 			list, err = resource.Namespace(namespace).List(ctx, v1.ListOptions{})
 		}
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
@@ -177,7 +171,7 @@ type componentMatch struct {
 	labels    map[string]string
 }
 
-func detectComponents(ctx context.Context, client kubernetes.Interface) ([]ComponentDetection, error) {
+func detectComponents(ctx context.Context, cache *kube.Cache) ([]ComponentDetection, error) {
 	components := []componentSpec{
 		{
 			name: "ingress-nginx",
@@ -249,7 +243,7 @@ func detectComponents(ctx context.Context, client kubernetes.Interface) ([]Compo
 	for _, component := range components {
 		evidence := []string{}
 		for _, match := range component.matches {
-			found := queryMatch(ctx, client, match)
+			found := queryMatch(ctx, cache, match)
 			if found != "" {
 				evidence = append(evidence, found)
 			}
@@ -274,27 +268,19 @@ func detectComponents(ctx context.Context, client kubernetes.Interface) ([]Compo
 	return detections, nil
 }
 
-func queryMatch(ctx context.Context, client kubernetes.Interface, match componentMatch) string {
+func queryMatch(ctx context.Context, cache *kube.Cache, match componentMatch) string {
+	selector := labels.SelectorFromSet(match.labels)
 	switch match.kind {
 	case "Deployment":
-		items, err := client.AppsV1().Deployments(match.namespace).List(ctx, v1.ListOptions{LabelSelector: selectorString(match.labels)})
-		if err == nil && len(items.Items) > 0 {
-			return fmt.Sprintf("Deployment %s/%s", match.namespace, items.Items[0].Name)
+		items, err := listDeployments(ctx, cache, match.namespace, selector)
+		if err == nil && len(items) > 0 {
+			return fmt.Sprintf("Deployment %s/%s", match.namespace, items[0].Name)
 		}
 	case "DaemonSet":
-		items, err := client.AppsV1().DaemonSets(match.namespace).List(ctx, v1.ListOptions{LabelSelector: selectorString(match.labels)})
-		if err == nil && len(items.Items) > 0 {
-			return fmt.Sprintf("DaemonSet %s/%s", match.namespace, items.Items[0].Name)
+		items, err := listDaemonSets(ctx, cache, match.namespace, selector)
+		if err == nil && len(items) > 0 {
+			return fmt.Sprintf("DaemonSet %s/%s", match.namespace, items[0].Name)
 		}
 	}
 	return ""
 }
-
-func selectorString(labels map[string]string) string {
-	pairs := []string{}
-	for key, value := range labels {
-		pairs = append(pairs, key+"="+value)
-	}
-	sort.Strings(pairs)
-	return strings.Join(pairs, ",")
-}
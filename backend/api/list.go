@@ -3,9 +3,11 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func parseListOptions(r *http.Request) (v1.ListOptions, error) {
@@ -30,3 +32,62 @@ func parseListOptions(r *http.Request) (v1.ListOptions, error) {
 
 	return opts, nil
 }
+
+func parseListSelector(r *http.Request) (v1.ListOptions, labels.Selector, error) {
+	opts, err := parseListOptions(r)
+	if err != nil {
+		return opts, nil, err
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return opts, nil, fmt.Errorf("invalid labelSelector: %w", err)
+	}
+	return opts, selector, nil
+}
+
+// paginate emulates limit/continue over lister results. Items are ordered by
+// namespace/name and the continue token is the offset of the next page.
+func paginate[T v1.Object](items []T, opts v1.ListOptions) ([]T, string, error) {
+	sortObjects(items)
+
+	offset := 0
+	if opts.Continue != "" {
+		parsed, err := strconv.Atoi(opts.Continue)
+		if err != nil || parsed < 0 {
+			return nil, "", fmt.Errorf("invalid continue")
+		}
+		offset = parsed
+	}
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+
+	if opts.Limit <= 0 || int(opts.Limit) >= len(items) {
+		return items, "", nil
+	}
+	return items[:opts.Limit], strconv.Itoa(offset + int(opts.Limit)), nil
+}
+
+func sortObjects[T v1.Object](items []T) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].GetNamespace() == items[j].GetNamespace() {
+			return items[i].GetName() < items[j].GetName()
+		}
+		return items[i].GetNamespace() < items[j].GetNamespace()
+	})
+}
+
+// values copies lister results out of the shared cache in namespace/name
+// order, so callers can treat them like the Items of a List response.
+func values[T any, P interface {
+	*T
+	v1.Object
+}](items []P) []T {
+	sortObjects(items)
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		out = append(out, *item)
+	}
+	return out
+}
@@ -0,0 +1,570 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Logs are streamed line by line, never buffered whole. limitBytes caps a
+// response, and each container stream, so a noisy pod cannot exhaust memory
+// or the connection; overlong lines are cut.
+const (
+	logDefaultLimitBytes = 10 << 20
+	logMaxLimitBytes     = 50 << 20
+	logMaxLineBytes      = 64 << 10
+	logMaxPods           = 20
+	logFlushLines        = 100
+)
+
+// LogLine is the payload of "log" and "error" events with format=sse.
+type LogLine struct {
+	Pod       string `json:"pod,omitempty"`
+	Container string `json:"container,omitempty"`
+	Line      string `json:"line"`
+}
+
+// LogEnd is the payload of the final "end" event with format=sse.
+type LogEnd struct {
+	Truncated bool  `json:"truncated"`
+	Bytes     int64 `json:"bytes"`
+}
+
+type logRequest struct {
+	container  string
+	options    corev1.PodLogOptions
+	limitBytes int64
+	sse        bool
+}
+
+type logSource struct {
+	pod       string
+	container string
+	prefix    string
+}
+
+type logEntry struct {
+	text string
+	time time.Time
+	err  error
+}
+
+// SnapshotLogsHandler answers log requests while a snapshot is served. The
+// archive holds no logs, and its fake clientset would stream placeholder
+// text as if it came from the pod.
+func SnapshotLogsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, http.StatusNotImplemented, "logs are not available from a snapshot")
+	}
+}
+
+// PodLogsHandler streams the logs of one pod. Without container, it streams
+// the kubectl.kubernetes.io/default-container or else every container, each
+// line prefixed with its container.
+func PodLogsHandler(cache *kube.Cache, clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		namespace := query.Get("ns")
+		name := query.Get("name")
+		if namespace == "" || name == "" {
+			respondError(w, http.StatusBadRequest, "ns and name are required")
+			return
+		}
+		req, err := parseLogRequest(query)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := contextWithTimeout(r)
+		pod, err := getPod(ctx, cache, namespace, name)
+		cancel()
+		if apierrors.IsNotFound(err) {
+			respondError(w, http.StatusNotFound, "pod "+namespace+"/"+name+" not found")
+			return
+		}
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		containers := []string{req.container}
+		switch {
+		case req.container != "":
+			if !podHasContainer(pod, req.container) {
+				respondError(w, http.StatusBadRequest, "container "+req.container+" not found in pod "+namespace+"/"+name)
+				return
+			}
+		case podHasContainer(pod, pod.Annotations["kubectl.kubernetes.io/default-container"]):
+			containers = []string{pod.Annotations["kubectl.kubernetes.io/default-container"]}
+		default:
+			containers = containers[:0]
+			for _, container := range pod.Spec.Containers {
+				containers = append(containers, container.Name)
+			}
+		}
+
+		sources := []logSource{}
+		for _, container := range containers {
+			source := logSource{pod: pod.Name, container: container}
+			if len(containers) > 1 {
+				source.prefix = "[" + container + "] "
+			}
+			sources = append(sources, source)
+		}
+		streamLogs(w, r, clientset, namespace, sources, req, nil)
+	}
+}
+
+// WorkloadLogsHandler merges the logs of the pods a workload controls, each
+// line prefixed with its pod. Without follow, lines are ordered by their
+// kubelet timestamps; with follow, by arrival.
+func WorkloadLogsHandler(cache *kube.Cache, clientset kubernetes.Interface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		namespace := query.Get("ns")
+		kind := canonicalKind(query.Get("kind"))
+		name := query.Get("name")
+		if namespace == "" || kind == "" || name == "" {
+			respondError(w, http.StatusBadRequest, "ns, kind and name are required")
+			return
+		}
+		if kind == "Pod" || !containsString(workloadKinds, kind) {
+			respondError(w, http.StatusBadRequest, "invalid kind: must be Deployment, ReplicaSet, StatefulSet, DaemonSet, Job or CronJob")
+			return
+		}
+		req, err := parseLogRequest(query)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+		objects, err := relatedObjects(ctx, cache, ObjectRef{Kind: kind, Namespace: namespace, Name: name})
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		pods := []corev1.Pod{}
+		for _, object := range objects {
+			if object.Kind != "Pod" {
+				continue
+			}
+			pod, err := getPod(ctx, cache, object.Namespace, object.Name)
+			if err == nil && (req.container == "" || podHasContainer(pod, req.container)) {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			respondError(w, http.StatusNotFound, "no pods found for "+kind+" "+namespace+"/"+name)
+			return
+		}
+		sort.Slice(pods, func(i, j int) bool {
+			return pods[i].Name < pods[j].Name
+		})
+
+		var notices []string
+		if len(pods) > logMaxPods {
+			notices = append(notices, fmt.Sprintf("showing %d of %d pods", logMaxPods, len(pods)))
+			pods = pods[:logMaxPods]
+		}
+
+		sources := []logSource{}
+		for _, pod := range pods {
+			if req.container != "" {
+				sources = append(sources, logSource{pod: pod.Name, container: req.container, prefix: "[" + pod.Name + "] "})
+				continue
+			}
+			for _, container := range pod.Spec.Containers {
+				source := logSource{pod: pod.Name, container: container.Name, prefix: "[" + pod.Name + "] "}
+				if len(pod.Spec.Containers) > 1 {
+					source.prefix = "[" + pod.Name + "/" + container.Name + "] "
+				}
+				sources = append(sources, source)
+			}
+		}
+		streamLogs(w, r, clientset, namespace, sources, req, notices)
+	}
+}
+
+func parseLogRequest(query url.Values) (logRequest, error) {
+	req := logRequest{container: query.Get("container"), limitBytes: logDefaultLimitBytes}
+
+	for _, param := range []struct {
+		name  string
+		value *bool
+	}{
+		{"previous", &req.options.Previous},
+		{"timestamps", &req.options.Timestamps},
+		{"follow", &req.options.Follow},
+	} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return req, fmt.Errorf("invalid %s", param.name)
+			}
+			*param.value = parsed
+		}
+	}
+
+	if value := query.Get("sinceSeconds"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			return req, fmt.Errorf("invalid sinceSeconds")
+		}
+		req.options.SinceSeconds = &parsed
+	}
+	if value := query.Get("tailLines"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return req, fmt.Errorf("invalid tailLines")
+		}
+		req.options.TailLines = &parsed
+	}
+	if value := query.Get("limitBytes"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 || parsed > logMaxLimitBytes {
+			return req, fmt.Errorf("invalid limitBytes: must be between 1 and %d", logMaxLimitBytes)
+		}
+		req.limitBytes = parsed
+	}
+
+	switch query.Get("format") {
+	case "", "text":
+	case "sse":
+		req.sse = true
+	default:
+		return req, fmt.Errorf("invalid format: must be text or sse")
+	}
+	return req, nil
+}
+
+func podHasContainer(pod corev1.Pod, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == name {
+			return true
+		}
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		if container.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// streamLogs opens a log stream per source and copies their lines to w.
+// Sources that cannot be opened are reported inline, unless all fail.
+func streamLogs(w http.ResponseWriter, r *http.Request, clientset kubernetes.Interface, namespace string, sources []logSource, req logRequest, notices []string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Merging by time needs the kubelet timestamps; they are stripped again
+	// unless requested.
+	merge := !req.options.Follow && len(sources) > 1
+	streams := make([]io.ReadCloser, len(sources))
+	openErrs := make([]error, len(sources))
+	var firstErr error
+	for i, source := range sources {
+		options := req.options
+		options.Container = source.container
+		options.LimitBytes = &req.limitBytes
+		options.Timestamps = options.Timestamps || merge
+		streams[i], openErrs[i] = clientset.CoreV1().Pods(namespace).GetLogs(source.pod, &options).Stream(ctx)
+		if openErrs[i] != nil && firstErr == nil {
+			firstErr = openErrs[i]
+		}
+	}
+	defer func() {
+		for _, stream := range streams {
+			if stream != nil {
+				_ = stream.Close()
+			}
+		}
+	}()
+	if firstErr != nil && !containsStream(streams) {
+		respondError(w, logErrorStatus(firstErr), firstErr.Error())
+		return
+	}
+
+	// The server-wide write timeout would otherwise cut the stream.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if req.sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	out := &logWriter{w: w, flusher: flusher, sse: req.sse, follow: req.options.Follow, limit: req.limitBytes}
+	for _, notice := range notices {
+		out.notice(logSource{}, notice)
+	}
+	for i, err := range openErrs {
+		if err != nil {
+			out.notice(sources[i], err.Error())
+		}
+	}
+
+	channels := make([]chan logEntry, len(sources))
+	for i, stream := range streams {
+		if stream == nil {
+			continue
+		}
+		channels[i] = make(chan logEntry, 64)
+		go readLogLines(ctx, stream, channels[i], merge, req.options.Timestamps)
+	}
+
+	truncated := false
+	if merge {
+		truncated = !mergeLogLines(channels, sources, out)
+	} else {
+		truncated = !forwardLogLines(ctx, channels, sources, out)
+	}
+	out.end(truncated)
+}
+
+func containsStream(streams []io.ReadCloser) bool {
+	for _, stream := range streams {
+		if stream != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func logErrorStatus(err error) int {
+	switch {
+	case apierrors.IsNotFound(err):
+		return http.StatusNotFound
+	case apierrors.IsBadRequest(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+// mergeLogLines writes the earliest pending line of all sources first. It
+// returns false when the byte limit was reached.
+func mergeLogLines(channels []chan logEntry, sources []logSource, out *logWriter) bool {
+	pending := make([]*logEntry, len(channels))
+	next := func(i int) {
+		pending[i] = nil
+		if channels[i] == nil {
+			return
+		}
+		if entry, ok := <-channels[i]; ok {
+			pending[i] = &entry
+		}
+	}
+	for i := range channels {
+		next(i)
+	}
+	for {
+		best := -1
+		for i, entry := range pending {
+			if entry != nil && (best < 0 || entry.time.Before(pending[best].time)) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return true
+		}
+		entry := *pending[best]
+		next(best)
+		if entry.err != nil {
+			out.notice(sources[best], entry.err.Error())
+			continue
+		}
+		if !out.line(sources[best], entry.text) {
+			return false
+		}
+	}
+}
+
+// forwardLogLines writes lines as they arrive from any source. It returns
+// false when the byte limit was reached.
+func forwardLogLines(ctx context.Context, channels []chan logEntry, sources []logSource, out *logWriter) bool {
+	type sourceEntry struct {
+		source int
+		entry  logEntry
+	}
+	merged := make(chan sourceEntry)
+	var wg sync.WaitGroup
+	for i, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, channel chan logEntry) {
+			defer wg.Done()
+			for entry := range channel {
+				select {
+				case merged <- sourceEntry{source: i, entry: entry}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(i, channel)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case item, ok := <-merged:
+			if !ok {
+				return true
+			}
+			if item.entry.err != nil {
+				out.notice(sources[item.source], item.entry.err.Error())
+				continue
+			}
+			if !out.line(sources[item.source], item.entry.text) {
+				return false
+			}
+		}
+	}
+}
+
+// readLogLines sends the lines of stream to out until it ends. With
+// parseTime, lines start with a kubelet timestamp, which is removed unless
+// keepTime is set; lines without one inherit the previous line's time.
+func readLogLines(ctx context.Context, stream io.Reader, out chan<- logEntry, parseTime, keepTime bool) {
+	defer close(out)
+	reader := bufio.NewReaderSize(stream, 32<<10)
+	var last time.Time
+	for {
+		text, err := readLogLine(reader)
+		if err == nil || text != "" {
+			entry := logEntry{text: text, time: last}
+			if parseTime {
+				if stamp, rest, ok := strings.Cut(text, " "); ok {
+					if t, parseErr := time.Parse(time.RFC3339Nano, stamp); parseErr == nil {
+						entry.time, last = t, t
+						if !keepTime {
+							entry.text = rest
+						}
+					}
+				}
+			}
+			select {
+			case out <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				select {
+				case out <- logEntry{time: last, err: err}:
+				case <-ctx.Done():
+				}
+			}
+			return
+		}
+	}
+}
+
+// readLogLine returns the next line without its newline, cut at
+// logMaxLineBytes; the rest of an overlong line is discarded.
+func readLogLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if room := logMaxLineBytes - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return strings.TrimRight(string(line), "\r\n"), err
+	}
+}
+
+type logWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+	follow  bool
+	limit   int64
+	written int64
+	lines   int
+}
+
+// line writes one log line and reports false, without writing, once it
+// would exceed the byte limit.
+func (l *logWriter) line(source logSource, text string) bool {
+	size := int64(len(source.prefix) + len(text) + 1)
+	if l.written+size > l.limit {
+		return false
+	}
+	l.written += size
+	if l.sse {
+		writeSSE(l.w, "log", strconv.Itoa(l.lines), LogLine{Pod: source.pod, Container: source.container, Line: text})
+	} else {
+		_, _ = io.WriteString(l.w, source.prefix+text+"\n")
+	}
+	l.lines++
+	if l.follow || l.lines%logFlushLines == 0 {
+		l.flusher.Flush()
+	}
+	return true
+}
+
+func (l *logWriter) notice(source logSource, message string) {
+	if l.sse {
+		writeSSE(l.w, "error", strconv.Itoa(l.lines), LogLine{Pod: source.pod, Container: source.container, Line: message})
+	} else {
+		if source.pod != "" {
+			message = source.pod + "/" + source.container + ": " + message
+		}
+		_, _ = io.WriteString(l.w, "[kubi] "+message+"\n")
+	}
+	l.flusher.Flush()
+}
+
+func (l *logWriter) end(truncated bool) {
+	if l.sse {
+		writeSSE(l.w, "end", strconv.Itoa(l.lines), LogEnd{Truncated: truncated, Bytes: l.written})
+	} else if truncated {
+		_, _ = fmt.Fprintf(l.w, "[kubi] output truncated at %d bytes\n", l.limit)
+	}
+	l.flusher.Flush()
+}
@@ -7,7 +7,6 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
 )
 
 type MetricSample struct {
@@ -24,7 +23,7 @@ type MetricsResponse struct {
 	Pods      []MetricSample `json:"pods"`
 }
 
-func MetricsHandler(restCfg *rest.Config) http.HandlerFunc {
+func MetricsHandler(dynamicClient dynamic.Interface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
@@ -34,14 +33,8 @@ func MetricsHandler(restCfg *rest.Config) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		dyn, err := dynamic.NewForConfig(restCfg)
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
-		}
-
 		result := MetricsResponse{Available: true}
-		nodes, nodeErr := dyn.Resource(schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}).List(ctx, v1.ListOptions{})
+		nodes, nodeErr := dynamicClient.Resource(schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}).List(ctx, v1.ListOptions{})
 		if nodeErr != nil {
 			result.Available = false
 			result.Message = "Metrics API not available"
@@ -50,8 +43,9 @@ func MetricsHandler(restCfg *rest.Config) http.HandlerFunc {
 		}
 		result.Nodes = mapMetricList(nodes.Items)
 
-		podsResource := dyn.Resource(schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"})
+		podsResource := dynamicClient.Resource(schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"})
 		var pods *unstructured.UnstructuredList
+		var err error
 		if namespace == v1.NamespaceAll {
 			pods, err = podsResource.List(ctx, v1.ListOptions{})
 		} else {
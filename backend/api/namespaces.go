@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"time"
 
-	"k8s.io/client-go/kubernetes"
+	"github.com/YanaDevOps/kubi/backend/kube"
 )
 
 type NamespaceItem struct {
@@ -20,25 +20,37 @@ type NamespaceList struct {
 	Continue string          `json:"continue"`
 }
 
-func NamespacesHandler(client kubernetes.Interface) http.HandlerFunc {
+func NamespacesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		list, err := client.CoreV1().Namespaces().List(ctx, opts)
+		lister, err := cache.Namespaces(ctx)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]NamespaceItem, 0, len(list.Items))
-		for _, ns := range list.Items {
+		namespaces, err := lister.List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(namespaces, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]NamespaceItem, 0, len(page))
+		for _, ns := range page {
 			items = append(items, NamespaceItem{
 				Name:      ns.Name,
 				Status:    string(ns.Status.Phase),
@@ -47,7 +59,7 @@ func NamespacesHandler(client kubernetes.Interface) http.HandlerFunc {
 			})
 		}
 
-		respondJSON(w, http.StatusOK, NamespaceList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, NamespaceList{Items: items, Continue: next})
 	}
 }
 
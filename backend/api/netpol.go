@@ -0,0 +1,272 @@
+package api
+
+import (
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+type PolicyRuleRef struct {
+	Policy string `json:"policy"`
+	Rule   int    `json:"rule"`
+	Peers  string `json:"peers"`
+	Ports  string `json:"ports"`
+}
+
+type PolicyDecision struct {
+	Direction string          `json:"direction"`
+	Isolated  bool            `json:"isolated"`
+	Allowed   bool            `json:"allowed"`
+	Reason    string          `json:"reason"`
+	Policies  []string        `json:"policies"`
+	Rules     []PolicyRuleRef `json:"rules"`
+}
+
+// policyEvaluator applies NetworkPolicy semantics: a pod is isolated for a
+// direction once any policy of that type selects it, and isolated traffic is
+// allowed if any rule of any selecting policy allows it.
+type policyEvaluator struct {
+	policies   []networkingv1.NetworkPolicy
+	namespaces map[string]labels.Set
+}
+
+func newPolicyEvaluator(policies []networkingv1.NetworkPolicy, namespaces []corev1.Namespace) policyEvaluator {
+	evaluator := policyEvaluator{policies: policies, namespaces: map[string]labels.Set{}}
+	for _, ns := range namespaces {
+		evaluator.namespaces[ns.Name] = labels.Set(ns.Labels)
+	}
+	return evaluator
+}
+
+// egress decides whether src may send to dst, ingress whether dst accepts
+// from src. Traffic flows only if both allow it.
+func (e policyEvaluator) egress(src, dst corev1.Pod, port int32, protocol corev1.Protocol) PolicyDecision {
+	return e.evaluate(networkingv1.PolicyTypeEgress, src, dst, dst, port, protocol)
+}
+
+func (e policyEvaluator) ingress(src, dst corev1.Pod, port int32, protocol corev1.Protocol) PolicyDecision {
+	return e.evaluate(networkingv1.PolicyTypeIngress, dst, src, dst, port, protocol)
+}
+
+func (e policyEvaluator) evaluate(direction networkingv1.PolicyType, subject, peer, destination corev1.Pod, port int32, protocol corev1.Protocol) PolicyDecision {
+	decision := PolicyDecision{
+		Direction: strings.ToLower(string(direction)),
+		Policies:  []string{},
+		Rules:     []PolicyRuleRef{},
+	}
+
+	for _, policy := range e.policies {
+		if !e.selects(policy, direction, subject) {
+			continue
+		}
+		name := policy.Namespace + "/" + policy.Name
+		decision.Isolated = true
+		decision.Policies = append(decision.Policies, name)
+
+		if direction == networkingv1.PolicyTypeIngress {
+			for i, rule := range policy.Spec.Ingress {
+				if e.peersMatch(rule.From, policy.Namespace, peer) && portsMatch(rule.Ports, port, protocol, destination) {
+					decision.Rules = append(decision.Rules, PolicyRuleRef{Policy: name, Rule: i, Peers: peersString(rule.From), Ports: portsString(rule.Ports)})
+				}
+			}
+		} else {
+			for i, rule := range policy.Spec.Egress {
+				if e.peersMatch(rule.To, policy.Namespace, peer) && portsMatch(rule.Ports, port, protocol, destination) {
+					decision.Rules = append(decision.Rules, PolicyRuleRef{Policy: name, Rule: i, Peers: peersString(rule.To), Ports: portsString(rule.Ports)})
+				}
+			}
+		}
+	}
+
+	switch {
+	case !decision.Isolated:
+		decision.Allowed = true
+		decision.Reason = "no NetworkPolicy selects the pod for " + decision.Direction
+	case len(decision.Rules) > 0:
+		decision.Allowed = true
+		decision.Reason = "allowed by " + itoa(len(decision.Rules)) + " rule(s)"
+	default:
+		decision.Reason = "isolated and no rule allows the traffic"
+	}
+	return decision
+}
+
+// isolated reports whether any policy selects the pod for direction.
+func (e policyEvaluator) isolated(direction networkingv1.PolicyType, pod corev1.Pod) bool {
+	for _, policy := range e.policies {
+		if e.selects(policy, direction, pod) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e policyEvaluator) selects(policy networkingv1.NetworkPolicy, direction networkingv1.PolicyType, pod corev1.Pod) bool {
+	if policy.Namespace != pod.Namespace || !hasPolicyType(policy, direction) {
+		return false
+	}
+	return labelSelector(&policy.Spec.PodSelector).Matches(labels.Set(pod.Labels))
+}
+
+func (e policyEvaluator) peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, pod corev1.Pod) bool {
+	// An empty peer list matches everything.
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if e.peerMatches(peer, policyNamespace, pod) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e policyEvaluator) peerMatches(peer networkingv1.NetworkPolicyPeer, policyNamespace string, pod corev1.Pod) bool {
+	switch {
+	case peer.IPBlock != nil:
+		return ipBlockMatches(peer.IPBlock, pod.Status.PodIP)
+	case peer.NamespaceSelector != nil:
+		if !labelSelector(peer.NamespaceSelector).Matches(e.namespaces[pod.Namespace]) {
+			return false
+		}
+		return peer.PodSelector == nil || labelSelector(peer.PodSelector).Matches(labels.Set(pod.Labels))
+	case peer.PodSelector != nil:
+		return pod.Namespace == policyNamespace && labelSelector(peer.PodSelector).Matches(labels.Set(pod.Labels))
+	}
+	return false
+}
+
+func hasPolicyType(policy networkingv1.NetworkPolicy, direction networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		// Defaulting: Ingress always, Egress only when egress rules exist.
+		return direction == networkingv1.PolicyTypeIngress || len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// portsMatch resolves named ports against the destination pod, which is
+// where the port is declared for both ingress and egress rules.
+func portsMatch(ports []networkingv1.NetworkPolicyPort, port int32, protocol corev1.Protocol, destination corev1.Pod) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, rulePort := range ports {
+		ruleProtocol := corev1.ProtocolTCP
+		if rulePort.Protocol != nil {
+			ruleProtocol = *rulePort.Protocol
+		}
+		if ruleProtocol != protocol {
+			continue
+		}
+		if rulePort.Port == nil {
+			return true
+		}
+		if rulePort.Port.Type == intstr.String {
+			if resolved, ok := namedContainerPort(destination, rulePort.Port.StrVal, protocol); ok && resolved == port {
+				return true
+			}
+			continue
+		}
+		start := rulePort.Port.IntVal
+		end := start
+		if rulePort.EndPort != nil {
+			end = *rulePort.EndPort
+		}
+		if port >= start && port <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// namedContainerPort searches init containers too, since sidecars declare
+// their ports there.
+func namedContainerPort(pod corev1.Pod, name string, protocol corev1.Protocol) (int32, bool) {
+	containers := append(append([]corev1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
+	for _, container := range containers {
+		for _, port := range container.Ports {
+			portProtocol := port.Protocol
+			if portProtocol == "" {
+				portProtocol = corev1.ProtocolTCP
+			}
+			if port.Name == name && portProtocol == protocol {
+				return port.ContainerPort, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func ipBlockMatches(block *networkingv1.IPBlock, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(addr) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, excluded, err := net.ParseCIDR(except); err == nil && excluded.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func peersString(peers []networkingv1.NetworkPolicyPeer) string {
+	if len(peers) == 0 {
+		return "all"
+	}
+	parts := []string{}
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			part := "ipBlock " + peer.IPBlock.CIDR
+			if len(peer.IPBlock.Except) > 0 {
+				part += " except " + strings.Join(peer.IPBlock.Except, ",")
+			}
+			parts = append(parts, part)
+		case peer.NamespaceSelector != nil:
+			part := "namespaces " + describeSelector(peer.NamespaceSelector)
+			if peer.PodSelector != nil {
+				part += " pods " + describeSelector(peer.PodSelector)
+			}
+			parts = append(parts, part)
+		case peer.PodSelector != nil:
+			parts = append(parts, "pods "+describeSelector(peer.PodSelector))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func portsString(ports []networkingv1.NetworkPolicyPort) string {
+	if len(ports) == 0 {
+		return "all"
+	}
+	parts := []string{}
+	for _, port := range ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		value := "*"
+		if port.Port != nil {
+			value = port.Port.String()
+			if port.EndPort != nil {
+				value += "-" + itoa(int(*port.EndPort))
+			}
+		}
+		parts = append(parts, value+"/"+string(protocol))
+	}
+	return strings.Join(parts, ",")
+}
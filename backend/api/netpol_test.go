@@ -0,0 +1,315 @@
+package api
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testPod(namespace, name, ip string, labels map[string]string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func testPolicy(namespace, name string, spec networkingv1.NetworkPolicySpec) networkingv1.NetworkPolicy {
+	return networkingv1.NetworkPolicy{ObjectMeta: v1.ObjectMeta{Namespace: namespace, Name: name}, Spec: spec}
+}
+
+func testPort(port intstr.IntOrString, endPort *int32) networkingv1.NetworkPolicyPort {
+	return networkingv1.NetworkPolicyPort{Port: &port, EndPort: endPort}
+}
+
+func int32Ptr(value int32) *int32 {
+	return &value
+}
+
+func TestPolicyEvaluator(t *testing.T) {
+	client := testPod("shop", "client", "10.0.1.10", map[string]string{"app": "client"})
+	web := testPod("shop", "web", "10.0.1.20", map[string]string{"app": "web"})
+	web.Spec.Containers = []corev1.Container{{Name: "web", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}}}
+	web.Spec.InitContainers = []corev1.Container{{Name: "proxy", Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090}}}}
+	monitor := testPod("monitoring", "prometheus", "10.2.0.5", map[string]string{"app": "prometheus"})
+	intruder := testPod("other", "prometheus", "10.3.0.5", map[string]string{"app": "prometheus"})
+	external := testPod("", "external", "10.1.0.5", nil)
+	namespaces := []corev1.Namespace{
+		{ObjectMeta: v1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}},
+		{ObjectMeta: v1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "ops"}}},
+		{ObjectMeta: v1.ObjectMeta{Name: "other", Labels: map[string]string{"team": "other"}}},
+	}
+
+	selectWeb := v1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	ingressOnly := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	egressOnly := []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+
+	tests := []struct {
+		name      string
+		policies  []networkingv1.NetworkPolicy
+		src, dst  corev1.Pod
+		port      int32
+		direction networkingv1.PolicyType
+		isolated  bool
+		allowed   bool
+	}{
+		{
+			name:      "no policy",
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			allowed:   true,
+		},
+		{
+			name:      "default deny ingress isolates ingress",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "deny", networkingv1.NetworkPolicySpec{PolicyTypes: ingressOnly})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name:      "default deny ingress leaves egress open",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "deny", networkingv1.NetworkPolicySpec{PolicyTypes: ingressOnly})},
+			src:       web,
+			dst:       client,
+			port:      8080,
+			direction: networkingv1.PolicyTypeEgress,
+			allowed:   true,
+		},
+		{
+			name:      "default deny egress isolates egress",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "deny", networkingv1.NetworkPolicySpec{PolicyTypes: egressOnly})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeEgress,
+			isolated:  true,
+		},
+		{
+			name:      "default deny egress leaves ingress open",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "deny", networkingv1.NetworkPolicySpec{PolicyTypes: egressOnly})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			allowed:   true,
+		},
+		{
+			name:      "implicit policyTypes isolate ingress",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "implicit", networkingv1.NetworkPolicySpec{PodSelector: selectWeb})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name:      "implicit policyTypes without egress rules leave egress open",
+			policies:  []networkingv1.NetworkPolicy{testPolicy("shop", "implicit", networkingv1.NetworkPolicySpec{PodSelector: selectWeb})},
+			src:       web,
+			dst:       client,
+			port:      8080,
+			direction: networkingv1.PolicyTypeEgress,
+			allowed:   true,
+		},
+		{
+			name: "implicit policyTypes with egress rules isolate egress",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "implicit", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{PodSelector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}}},
+				}},
+			})},
+			src:       web,
+			dst:       client,
+			port:      8080,
+			direction: networkingv1.PolicyTypeEgress,
+			isolated:  true,
+		},
+		{
+			name: "named port allows its number",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "http", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{testPort(intstr.FromString("http"), nil)}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+			allowed:   true,
+		},
+		{
+			name: "named port denies other numbers",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "http", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{testPort(intstr.FromString("http"), nil)}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      8081,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name: "named port of a sidecar",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "metrics", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{testPort(intstr.FromString("metrics"), nil)}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      9090,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+			allowed:   true,
+		},
+		{
+			name: "endPort includes the range",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "range", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{testPort(intstr.FromInt32(8000), int32Ptr(8100))}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      8100,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+			allowed:   true,
+		},
+		{
+			name: "endPort excludes ports past the range",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "range", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{testPort(intstr.FromInt32(8000), int32Ptr(8100))}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      8101,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name: "ipBlock allows addresses in the CIDR",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "cidr", networkingv1.NetworkPolicySpec{
+				PolicyTypes: egressOnly,
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}}},
+				}},
+			})},
+			src:       client,
+			dst:       monitor,
+			port:      443,
+			direction: networkingv1.PolicyTypeEgress,
+			isolated:  true,
+			allowed:   true,
+		},
+		{
+			name: "ipBlock except denies excluded addresses",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "cidr", networkingv1.NetworkPolicySpec{
+				PolicyTypes: egressOnly,
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}}},
+				}},
+			})},
+			src:       client,
+			dst:       external,
+			port:      443,
+			direction: networkingv1.PolicyTypeEgress,
+			isolated:  true,
+		},
+		{
+			name: "namespaceSelector and podSelector in one peer both apply",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "scrape", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}},
+					PodSelector:       &v1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+				}}}},
+			})},
+			src:       monitor,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+			allowed:   true,
+		},
+		{
+			name: "namespaceSelector and podSelector in one peer reject other namespaces",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "scrape", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}},
+					PodSelector:       &v1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+				}}}},
+			})},
+			src:       intruder,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name: "podSelector alone only matches the policy namespace",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "scrape", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{{
+					PodSelector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}},
+				}}}},
+			})},
+			src:       monitor,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+		},
+		{
+			name: "separate peers are alternatives",
+			policies: []networkingv1.NetworkPolicy{testPolicy("shop", "scrape", networkingv1.NetworkPolicySpec{
+				PodSelector: selectWeb,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{From: []networkingv1.NetworkPolicyPeer{
+					{NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"team": "nobody"}}},
+					{PodSelector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "client"}}},
+				}}},
+			})},
+			src:       client,
+			dst:       web,
+			port:      8080,
+			direction: networkingv1.PolicyTypeIngress,
+			isolated:  true,
+			allowed:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := newPolicyEvaluator(tt.policies, namespaces)
+			var decision PolicyDecision
+			if tt.direction == networkingv1.PolicyTypeIngress {
+				decision = evaluator.ingress(tt.src, tt.dst, tt.port, corev1.ProtocolTCP)
+			} else {
+				decision = evaluator.egress(tt.src, tt.dst, tt.port, corev1.ProtocolTCP)
+			}
+			if decision.Isolated != tt.isolated || decision.Allowed != tt.allowed {
+				t.Errorf("isolated=%v allowed=%v, want isolated=%v allowed=%v (%s)",
+					decision.Isolated, decision.Allowed, tt.isolated, tt.allowed, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestPortsMatchProtocol(t *testing.T) {
+	udp := corev1.ProtocolUDP
+	ports := []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &intstr.IntOrString{IntVal: 53}}}
+	if !portsMatch(ports, 53, corev1.ProtocolUDP, corev1.Pod{}) {
+		t.Error("UDP 53 should match")
+	}
+	if portsMatch(ports, 53, corev1.ProtocolTCP, corev1.Pod{}) {
+		t.Error("TCP 53 should not match a UDP port")
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type NodeItem struct {
@@ -22,35 +22,41 @@ type NodeList struct {
 	Continue string     `json:"continue"`
 }
 
-func NodesHandler(client kubernetes.Interface) http.HandlerFunc {
+func NodesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		list, err := client.CoreV1().Nodes().List(ctx, opts)
+		lister, err := cache.Nodes(ctx)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]NodeItem, 0, len(list.Items))
-		for _, node := range list.Items {
-			items = append(items, NodeItem{
-				Name:           node.Name,
-				Ready:          nodeReady(node.Status.Conditions),
-				Roles:          nodeRoles(node.Labels),
-				KubeletVersion: node.Status.NodeInfo.KubeletVersion,
-				CreatedAt:      node.CreationTimestamp.Time,
-			})
+		nodes, err := lister.List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(nodes, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]NodeItem, 0, len(page))
+		for _, node := range page {
+			items = append(items, mapNode(node))
 		}
 
-		respondJSON(w, http.StatusOK, NodeList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, NodeList{Items: items, Continue: next})
 	}
 }
 
@@ -79,3 +85,13 @@ func nodeRoles(labels map[string]string) []string {
 	}
 	return roles
 }
+
+func mapNode(node *corev1.Node) NodeItem {
+	return NodeItem{
+		Name:           node.Name,
+		Ready:          nodeReady(node.Status.Conditions),
+		Roles:          nodeRoles(node.Labels),
+		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		CreatedAt:      node.CreationTimestamp.Time,
+	}
+}
@@ -0,0 +1,415 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PodDetail struct {
+	PodItem
+	Labels              map[string]string  `json:"labels,omitempty"`
+	ServiceAccount      string             `json:"serviceAccount"`
+	QOSClass            string             `json:"qosClass"`
+	PriorityClass       string             `json:"priorityClass,omitempty"`
+	InitContainers      []ContainerDetail  `json:"initContainers"`
+	Containers          []ContainerDetail  `json:"containers"`
+	EphemeralContainers []ContainerDetail  `json:"ephemeralContainers"`
+	Conditions          []PodConditionItem `json:"conditions"`
+	Tolerations         []TolerationItem   `json:"tolerations"`
+	NodeSelector        map[string]string  `json:"nodeSelector,omitempty"`
+	NodeAffinity        *NodeAffinityItem  `json:"nodeAffinity,omitempty"`
+}
+
+// ContainerDetail joins a container's spec with its status. State is
+// Waiting, Running or Terminated, or empty before the kubelet reports it.
+type ContainerDetail struct {
+	Name            string                `json:"name"`
+	Image           string                `json:"image"`
+	Sidecar         bool                  `json:"sidecar,omitempty"`
+	TargetContainer string                `json:"targetContainer,omitempty"`
+	State           string                `json:"state"`
+	Reason          string                `json:"reason,omitempty"`
+	Message         string                `json:"message,omitempty"`
+	ExitCode        *int32                `json:"exitCode,omitempty"`
+	StartedAt       *time.Time            `json:"startedAt,omitempty"`
+	Ready           bool                  `json:"ready"`
+	RestartCount    int32                 `json:"restartCount"`
+	LastTermination *ContainerTermination `json:"lastTermination,omitempty"`
+	Requests        map[string]string     `json:"requests,omitempty"`
+	Limits          map[string]string     `json:"limits,omitempty"`
+	Probes          []ProbeItem           `json:"probes"`
+	Mounts          []MountItem           `json:"mounts"`
+}
+
+type ContainerTermination struct {
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message,omitempty"`
+	ExitCode   int32     `json:"exitCode"`
+	Signal     int32     `json:"signal,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// ProbeItem is a liveness, readiness or startup probe. Handler reads like
+// kubectl describe, e.g. "http-get http://:8080/healthz".
+type ProbeItem struct {
+	Type                string `json:"type"`
+	Handler             string `json:"handler"`
+	InitialDelaySeconds int32  `json:"initialDelaySeconds"`
+	TimeoutSeconds      int32  `json:"timeoutSeconds"`
+	PeriodSeconds       int32  `json:"periodSeconds"`
+	SuccessThreshold    int32  `json:"successThreshold"`
+	FailureThreshold    int32  `json:"failureThreshold"`
+}
+
+// MountItem is a volume mount; Source names what backs the volume, e.g.
+// ConfigMap/app-config or EmptyDir.
+type MountItem struct {
+	Volume    string `json:"volume"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly"`
+	Source    string `json:"source"`
+}
+
+type PodConditionItem struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+type TolerationItem struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator"`
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// NodeAffinityItem renders node selector terms as expressions such as
+// "zone In (a, b)". Required terms are ORed; the expressions of a term are
+// ANDed.
+type NodeAffinityItem struct {
+	Required  []string               `json:"required,omitempty"`
+	Preferred []WeightedAffinityTerm `json:"preferred,omitempty"`
+}
+
+type WeightedAffinityTerm struct {
+	Weight int32  `json:"weight"`
+	Term   string `json:"term"`
+}
+
+func PodDetailHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		namespace := r.URL.Query().Get("ns")
+		name := r.URL.Query().Get("name")
+		if namespace == "" || name == "" {
+			respondError(w, http.StatusBadRequest, "ns and name are required")
+			return
+		}
+
+		pod, err := getPod(ctx, cache, namespace, name)
+		if apierrors.IsNotFound(err) {
+			respondError(w, http.StatusNotFound, "pod "+namespace+"/"+name+" not found")
+			return
+		}
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, mapPodDetail(&pod))
+	}
+}
+
+func mapPodDetail(pod *corev1.Pod) PodDetail {
+	detail := PodDetail{
+		PodItem:             mapPod(pod),
+		Labels:              pod.Labels,
+		ServiceAccount:      pod.Spec.ServiceAccountName,
+		QOSClass:            string(pod.Status.QOSClass),
+		PriorityClass:       pod.Spec.PriorityClassName,
+		InitContainers:      []ContainerDetail{},
+		Containers:          []ContainerDetail{},
+		EphemeralContainers: []ContainerDetail{},
+		Conditions:          []PodConditionItem{},
+		Tolerations:         []TolerationItem{},
+		NodeSelector:        pod.Spec.NodeSelector,
+		NodeAffinity:        mapNodeAffinity(pod.Spec.Affinity),
+	}
+	if detail.QOSClass == "" {
+		detail.QOSClass = string(podQOSClass(pod))
+	}
+
+	volumes := map[string]corev1.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	statuses := func(list []corev1.ContainerStatus) map[string]corev1.ContainerStatus {
+		out := map[string]corev1.ContainerStatus{}
+		for _, status := range list {
+			out[status.Name] = status
+		}
+		return out
+	}
+
+	initStatuses := statuses(pod.Status.InitContainerStatuses)
+	for _, container := range pod.Spec.InitContainers {
+		item := mapContainerDetail(container, initStatuses[container.Name], volumes)
+		item.Sidecar = isSidecar(container)
+		detail.InitContainers = append(detail.InitContainers, item)
+	}
+	containerStatuses := statuses(pod.Status.ContainerStatuses)
+	for _, container := range pod.Spec.Containers {
+		detail.Containers = append(detail.Containers, mapContainerDetail(container, containerStatuses[container.Name], volumes))
+	}
+	ephemeralStatuses := statuses(pod.Status.EphemeralContainerStatuses)
+	for _, container := range pod.Spec.EphemeralContainers {
+		item := mapContainerDetail(corev1.Container(container.EphemeralContainerCommon), ephemeralStatuses[container.Name], volumes)
+		item.TargetContainer = container.TargetContainerName
+		detail.EphemeralContainers = append(detail.EphemeralContainers, item)
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		detail.Conditions = append(detail.Conditions, PodConditionItem{
+			Type:               string(condition.Type),
+			Status:             string(condition.Status),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+			LastTransitionTime: condition.LastTransitionTime.Time,
+		})
+	}
+	for _, toleration := range pod.Spec.Tolerations {
+		operator := toleration.Operator
+		if operator == "" {
+			operator = corev1.TolerationOpEqual
+		}
+		detail.Tolerations = append(detail.Tolerations, TolerationItem{
+			Key:               toleration.Key,
+			Operator:          string(operator),
+			Value:             toleration.Value,
+			Effect:            string(toleration.Effect),
+			TolerationSeconds: toleration.TolerationSeconds,
+		})
+	}
+	return detail
+}
+
+func mapContainerDetail(container corev1.Container, status corev1.ContainerStatus, volumes map[string]corev1.Volume) ContainerDetail {
+	item := ContainerDetail{
+		Name:         container.Name,
+		Image:        container.Image,
+		Ready:        status.Ready,
+		RestartCount: status.RestartCount,
+		Requests:     resourceStrings(container.Resources.Requests),
+		Limits:       resourceStrings(container.Resources.Limits),
+		Probes:       []ProbeItem{},
+		Mounts:       []MountItem{},
+	}
+
+	switch state := status.State; {
+	case state.Waiting != nil:
+		item.State = "Waiting"
+		item.Reason = state.Waiting.Reason
+		item.Message = state.Waiting.Message
+	case state.Running != nil:
+		item.State = "Running"
+		item.StartedAt = timePtr(state.Running.StartedAt)
+	case state.Terminated != nil:
+		item.State = "Terminated"
+		item.Reason = terminationReason(state.Terminated)
+		item.Message = state.Terminated.Message
+		item.ExitCode = &state.Terminated.ExitCode
+		item.StartedAt = timePtr(state.Terminated.StartedAt)
+	}
+	if last := status.LastTerminationState.Terminated; last != nil {
+		item.LastTermination = &ContainerTermination{
+			Reason:     terminationReason(last),
+			Message:    last.Message,
+			ExitCode:   last.ExitCode,
+			Signal:     last.Signal,
+			StartedAt:  last.StartedAt.Time,
+			FinishedAt: last.FinishedAt.Time,
+		}
+	}
+
+	for _, probe := range []struct {
+		kind  string
+		probe *corev1.Probe
+	}{
+		{"startup", container.StartupProbe},
+		{"liveness", container.LivenessProbe},
+		{"readiness", container.ReadinessProbe},
+	} {
+		if probe.probe != nil {
+			item.Probes = append(item.Probes, mapProbe(probe.kind, probe.probe))
+		}
+	}
+
+	for _, mount := range container.VolumeMounts {
+		subPath := mount.SubPath
+		if subPath == "" {
+			subPath = mount.SubPathExpr
+		}
+		item.Mounts = append(item.Mounts, MountItem{
+			Volume:    mount.Name,
+			MountPath: mount.MountPath,
+			SubPath:   subPath,
+			ReadOnly:  mount.ReadOnly,
+			Source:    volumeSource(volumes[mount.Name]),
+		})
+	}
+	return item
+}
+
+func timePtr(t v1.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
+
+func resourceStrings(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	out := map[string]string{}
+	for name, quantity := range list {
+		out[string(name)] = quantity.String()
+	}
+	return out
+}
+
+func mapProbe(kind string, probe *corev1.Probe) ProbeItem {
+	item := ProbeItem{
+		Type:                kind,
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		SuccessThreshold:    probe.SuccessThreshold,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+	switch handler := probe.ProbeHandler; {
+	case handler.HTTPGet != nil:
+		scheme := strings.ToLower(string(handler.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		item.Handler = fmt.Sprintf("http-get %s://%s:%s%s", scheme, handler.HTTPGet.Host, handler.HTTPGet.Port.String(), handler.HTTPGet.Path)
+	case handler.TCPSocket != nil:
+		item.Handler = fmt.Sprintf("tcp-socket %s:%s", handler.TCPSocket.Host, handler.TCPSocket.Port.String())
+	case handler.GRPC != nil:
+		item.Handler = "grpc :" + strconv.Itoa(int(handler.GRPC.Port))
+		if handler.GRPC.Service != nil && *handler.GRPC.Service != "" {
+			item.Handler += " " + *handler.GRPC.Service
+		}
+	case handler.Exec != nil:
+		item.Handler = "exec [" + strings.Join(handler.Exec.Command, " ") + "]"
+	}
+	return item
+}
+
+func volumeSource(volume corev1.Volume) string {
+	switch source := volume.VolumeSource; {
+	case source.ConfigMap != nil:
+		return "ConfigMap/" + source.ConfigMap.Name
+	case source.Secret != nil:
+		return "Secret/" + source.Secret.SecretName
+	case source.PersistentVolumeClaim != nil:
+		return "PersistentVolumeClaim/" + source.PersistentVolumeClaim.ClaimName
+	case source.HostPath != nil:
+		return "HostPath/" + source.HostPath.Path
+	case source.EmptyDir != nil:
+		return "EmptyDir"
+	case source.Projected != nil:
+		return "Projected"
+	case source.DownwardAPI != nil:
+		return "DownwardAPI"
+	case source.Ephemeral != nil:
+		return "Ephemeral"
+	case source.CSI != nil:
+		return "CSI/" + source.CSI.Driver
+	case source.NFS != nil:
+		return "NFS/" + source.NFS.Server + ":" + source.NFS.Path
+	}
+	if volume.Name == "" {
+		return ""
+	}
+	return "Other"
+}
+
+func mapNodeAffinity(affinity *corev1.Affinity) *NodeAffinityItem {
+	if affinity == nil || affinity.NodeAffinity == nil {
+		return nil
+	}
+	item := &NodeAffinityItem{}
+	if required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+		for _, term := range required.NodeSelectorTerms {
+			item.Required = append(item.Required, nodeSelectorTerm(term))
+		}
+	}
+	for _, preferred := range affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		item.Preferred = append(item.Preferred, WeightedAffinityTerm{Weight: preferred.Weight, Term: nodeSelectorTerm(preferred.Preference)})
+	}
+	if len(item.Required) == 0 && len(item.Preferred) == 0 {
+		return nil
+	}
+	return item
+}
+
+func nodeSelectorTerm(term corev1.NodeSelectorTerm) string {
+	expressions := []string{}
+	for _, requirements := range [][]corev1.NodeSelectorRequirement{term.MatchExpressions, term.MatchFields} {
+		for _, requirement := range requirements {
+			expression := requirement.Key + " " + string(requirement.Operator)
+			if len(requirement.Values) > 0 {
+				expression += " (" + strings.Join(requirement.Values, ", ") + ")"
+			}
+			expressions = append(expressions, expression)
+		}
+	}
+	sort.Strings(expressions)
+	return strings.Join(expressions, ", ")
+}
+
+// podQOSClass derives the QoS class for pods whose status does not report it
+// yet: Guaranteed when every container limits CPU and memory to its
+// requests, BestEffort when none requests or limits either.
+func podQOSClass(pod *corev1.Pod) corev1.PodQOSClass {
+	containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	bestEffort, guaranteed := true, true
+	for _, container := range containers {
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, hasRequest := container.Resources.Requests[name]
+			limit, hasLimit := container.Resources.Limits[name]
+			if hasRequest || hasLimit {
+				bestEffort = false
+			}
+			if !hasLimit || (hasRequest && request.Cmp(limit) != 0) {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case bestEffort:
+		return corev1.PodQOSBestEffort
+	case guaranteed:
+		return corev1.PodQOSGuaranteed
+	}
+	return corev1.PodQOSBurstable
+}
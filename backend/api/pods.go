@@ -1,24 +1,26 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type PodItem struct {
-	Name      string    `json:"name"`
-	Namespace string    `json:"namespace"`
-	Phase     string    `json:"phase"`
-	Ready     bool      `json:"ready"`
-	Restarts  int32     `json:"restarts"`
-	Node      string    `json:"node"`
-	PodIP     string    `json:"podIp"`
-	Images    []string  `json:"images"`
-	CreatedAt time.Time `json:"createdAt"`
+	Name         string    `json:"name"`
+	Namespace    string    `json:"namespace"`
+	Phase        string    `json:"phase"`
+	StatusReason string    `json:"statusReason"`
+	Ready        bool      `json:"ready"`
+	Restarts     int32     `json:"restarts"`
+	Node         string    `json:"node"`
+	PodIP        string    `json:"podIp"`
+	Images       []string  `json:"images"`
+	CreatedAt    time.Time `json:"createdAt"`
 }
 
 type PodList struct {
@@ -26,12 +28,12 @@ type PodList struct {
 	Continue string    `json:"continue"`
 }
 
-func PodsHandler(client kubernetes.Interface) http.HandlerFunc {
+func PodsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -42,28 +44,30 @@ func PodsHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Pods)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]PodItem, 0, len(list.Items))
-		for _, pod := range list.Items {
-			items = append(items, PodItem{
-				Name:      pod.Name,
-				Namespace: pod.Namespace,
-				Phase:     string(pod.Status.Phase),
-				Ready:     podReady(pod.Status.Conditions),
-				Restarts:  podRestarts(pod.Status.ContainerStatuses),
-				Node:      pod.Spec.NodeName,
-				PodIP:     pod.Status.PodIP,
-				Images:    podImages(pod.Spec.Containers),
-				CreatedAt: pod.CreationTimestamp.Time,
-			})
+		pods, err := lister.Pods(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(pods, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
-		respondJSON(w, http.StatusOK, PodList{Items: items, Continue: list.Continue})
+		items := make([]PodItem, 0, len(page))
+		for _, pod := range page {
+			items = append(items, mapPod(pod))
+		}
+
+		respondJSON(w, http.StatusOK, PodList{Items: items, Continue: next})
 	}
 }
 
@@ -93,3 +97,109 @@ func podImages(containers []corev1.Container) []string {
 	}
 	return images
 }
+
+func mapPod(pod *corev1.Pod) PodItem {
+	return PodItem{
+		Name:         pod.Name,
+		Namespace:    pod.Namespace,
+		Phase:        string(pod.Status.Phase),
+		StatusReason: podStatusReason(pod),
+		Ready:        podReady(pod.Status.Conditions),
+		Restarts:     podRestarts(pod.Status.ContainerStatuses),
+		Node:         pod.Spec.NodeName,
+		PodIP:        pod.Status.PodIP,
+		Images:       podImages(pod.Spec.Containers),
+		CreatedAt:    pod.CreationTimestamp.Time,
+	}
+}
+
+// podStatusReason derives the STATUS column of kubectl get pods: the reason
+// of the first unfinished init container, else of the last container that is
+// waiting or terminated, e.g. CrashLoopBackOff, OOMKilled or Init:1/2.
+func podStatusReason(pod *corev1.Pod) string {
+	reason := string(pod.Status.Phase)
+	if pod.Status.Reason != "" {
+		reason = pod.Status.Reason
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Reason == corev1.PodReasonSchedulingGated {
+			reason = corev1.PodReasonSchedulingGated
+		}
+	}
+
+	sidecars := map[string]bool{}
+	for _, container := range pod.Spec.InitContainers {
+		sidecars[container.Name] = isSidecar(container)
+	}
+	initializing := false
+	for i, status := range pod.Status.InitContainerStatuses {
+		switch terminated, waiting := status.State.Terminated, status.State.Waiting; {
+		case terminated != nil && terminated.ExitCode == 0:
+			continue
+		case sidecars[status.Name] && status.Started != nil && *status.Started:
+			continue
+		case terminated != nil:
+			reason = "Init:" + terminationReason(terminated)
+		case waiting != nil && waiting.Reason != "" && waiting.Reason != "PodInitializing":
+			reason = "Init:" + waiting.Reason
+		default:
+			reason = fmt.Sprintf("Init:%d/%d", i, len(pod.Spec.InitContainers))
+		}
+		initializing = true
+		break
+	}
+
+	if !initializing || podInitialized(pod.Status.Conditions) {
+		running := false
+		for i := len(pod.Status.ContainerStatuses) - 1; i >= 0; i-- {
+			status := pod.Status.ContainerStatuses[i]
+			switch {
+			case status.State.Waiting != nil && status.State.Waiting.Reason != "":
+				reason = status.State.Waiting.Reason
+			case status.State.Terminated != nil:
+				reason = terminationReason(status.State.Terminated)
+			case status.Ready && status.State.Running != nil:
+				running = true
+			}
+		}
+		if reason == "Completed" && running {
+			reason = "NotReady"
+			if podReady(pod.Status.Conditions) {
+				reason = "Running"
+			}
+		}
+	}
+
+	if pod.DeletionTimestamp != nil {
+		if pod.Status.Reason == "NodeLost" {
+			return "Unknown"
+		}
+		return "Terminating"
+	}
+	return reason
+}
+
+func terminationReason(state *corev1.ContainerStateTerminated) string {
+	switch {
+	case state.Reason != "":
+		return state.Reason
+	case state.Signal != 0:
+		return fmt.Sprintf("Signal:%d", state.Signal)
+	}
+	return fmt.Sprintf("ExitCode:%d", state.ExitCode)
+}
+
+func podInitialized(conditions []corev1.PodCondition) bool {
+	for _, condition := range conditions {
+		if condition.Type == corev1.PodInitialized {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// isSidecar reports whether an init container keeps running next to the
+// regular containers.
+func isSidecar(container corev1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways
+}
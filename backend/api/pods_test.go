@@ -0,0 +1,231 @@
+package api
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testWaiting(name, reason string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{Name: name, State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}}
+}
+
+func testTerminated(name, reason string, exitCode, signal int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{Name: name, State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode, Signal: signal}}}
+}
+
+func testRunning(name string, ready bool) corev1.ContainerStatus {
+	return corev1.ContainerStatus{Name: name, Ready: ready, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}
+}
+
+func testStarted(status corev1.ContainerStatus, value bool) corev1.ContainerStatus {
+	status.Started = &value
+	return status
+}
+
+func testCondition(kind corev1.PodConditionType, status corev1.ConditionStatus) corev1.PodCondition {
+	return corev1.PodCondition{Type: kind, Status: status}
+}
+
+func TestPodStatusReason(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	initContainers := []corev1.Container{{Name: "migrate"}, {Name: "seed"}}
+	withSidecar := []corev1.Container{{Name: "proxy", RestartPolicy: &always}, {Name: "migrate"}}
+	deleted := v1.Now()
+
+	tests := []struct {
+		name       string
+		pod        corev1.Pod
+		deleting   bool
+		initSpec   []corev1.Container
+		want       string
+		podReason  string
+		conditions []corev1.PodCondition
+	}{
+		{
+			name: "phase",
+			pod:  corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}},
+			want: "Pending",
+		},
+		{
+			name:      "pod reason wins over phase",
+			pod:       corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}},
+			podReason: "Evicted",
+			want:      "Evicted",
+		},
+		{
+			name: "scheduling gated",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonSchedulingGated},
+			}}},
+			want: "SchedulingGated",
+		},
+		{
+			name:     "first init container running",
+			initSpec: initContainers,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testRunning("migrate", false), testWaiting("seed", "PodInitializing"),
+			}}},
+			want: "Init:0/2",
+		},
+		{
+			name:     "second init container running",
+			initSpec: initContainers,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("migrate", "Completed", 0, 0), testRunning("seed", false),
+			}}},
+			want: "Init:1/2",
+		},
+		{
+			name:     "init container crash loop",
+			initSpec: initContainers,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testWaiting("migrate", "CrashLoopBackOff"), testWaiting("seed", "PodInitializing"),
+			}}},
+			want: "Init:CrashLoopBackOff",
+		},
+		{
+			name:     "init container failed with exit code",
+			initSpec: initContainers,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("migrate", "", 3, 0),
+			}}},
+			want: "Init:ExitCode:3",
+		},
+		{
+			name:     "init container killed by signal",
+			initSpec: initContainers,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("migrate", "", 137, 9),
+			}}},
+			want: "Init:Signal:9",
+		},
+		{
+			name:     "started sidecar does not hold up init",
+			initSpec: withSidecar,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testStarted(testRunning("proxy", true), true), testRunning("migrate", false),
+			}}},
+			want: "Init:1/2",
+		},
+		{
+			name:     "sidecar not started yet",
+			initSpec: withSidecar,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending, InitContainerStatuses: []corev1.ContainerStatus{
+				testStarted(testRunning("proxy", false), false), testWaiting("migrate", "PodInitializing"),
+			}}},
+			want: "Init:0/2",
+		},
+		{
+			name:     "running sidecar after init",
+			initSpec: withSidecar,
+			pod: corev1.Pod{Status: corev1.PodStatus{
+				Phase:                 corev1.PodRunning,
+				InitContainerStatuses: []corev1.ContainerStatus{testStarted(testRunning("proxy", true), true), testTerminated("migrate", "Completed", 0, 0)},
+				ContainerStatuses:     []corev1.ContainerStatus{testRunning("app", true)},
+			}},
+			conditions: []corev1.PodCondition{testCondition(corev1.PodInitialized, corev1.ConditionTrue), testCondition(corev1.PodReady, corev1.ConditionTrue)},
+			want:       "Running",
+		},
+		{
+			name: "crash loop",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testWaiting("app", "CrashLoopBackOff"),
+			}}},
+			want: "CrashLoopBackOff",
+		},
+		{
+			name: "OOM killed",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("app", "OOMKilled", 137, 0),
+			}}},
+			want: "OOMKilled",
+		},
+		{
+			name: "first container in the list wins",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testWaiting("app", "ImagePullBackOff"), testWaiting("proxy", "ContainerCreating"),
+			}}},
+			want: "ImagePullBackOff",
+		},
+		{
+			name: "completed next to running and ready",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("job", "Completed", 0, 0), testRunning("app", true),
+			}}},
+			conditions: []corev1.PodCondition{testCondition(corev1.PodReady, corev1.ConditionTrue)},
+			want:       "Running",
+		},
+		{
+			name: "completed next to running, pod not ready",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("job", "Completed", 0, 0), testRunning("app", true),
+			}}},
+			conditions: []corev1.PodCondition{testCondition(corev1.PodReady, corev1.ConditionFalse)},
+			want:       "NotReady",
+		},
+		{
+			name: "completed next to an unready container",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("job", "Completed", 0, 0), testRunning("app", false),
+			}}},
+			want: "Completed",
+		},
+		{
+			name: "all completed",
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded, ContainerStatuses: []corev1.ContainerStatus{
+				testTerminated("job", "Completed", 0, 0),
+			}}},
+			want: "Completed",
+		},
+		{
+			name:     "terminating",
+			deleting: true,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testRunning("app", true),
+			}}},
+			want: "Terminating",
+		},
+		{
+			name:     "terminating hides a crash loop",
+			deleting: true,
+			pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{
+				testWaiting("app", "CrashLoopBackOff"),
+			}}},
+			want: "Terminating",
+		},
+		{
+			name:      "node lost",
+			deleting:  true,
+			pod:       corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			podReason: "NodeLost",
+			want:      "Unknown",
+		},
+		{
+			name:      "node lost without deletion",
+			pod:       corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}},
+			podReason: "NodeLost",
+			want:      "NodeLost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := tt.pod
+			pod.Spec.InitContainers = tt.initSpec
+			if tt.podReason != "" {
+				pod.Status.Reason = tt.podReason
+			}
+			if tt.conditions != nil {
+				pod.Status.Conditions = tt.conditions
+			}
+			if tt.deleting {
+				pod.DeletionTimestamp = &deleted
+			}
+			if got := podStatusReason(&pod); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
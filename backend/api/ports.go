@@ -5,12 +5,12 @@ import (
 	"sort"
 	"strconv"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/client-go/kubernetes"
 )
 
 type ServicePortMapping struct {
@@ -44,17 +44,22 @@ type IngressPortMapping struct {
 }
 
 type PortsResponse struct {
-	Services   []ServicePortMapping   `json:"services"`
-	Containers []ContainerPortMapping `json:"containers"`
-	Ingresses  []IngressPortMapping   `json:"ingresses"`
+	Services   []ServicePortMapping     `json:"services"`
+	Containers []ContainerPortMapping   `json:"containers"`
+	Ingresses  []IngressPortMapping     `json:"ingresses"`
+	Listeners  []GatewayListenerMapping `json:"listeners"`
+	Routes     []RouteIntent            `json:"routes"`
+	// Unavailable names the optional resources left out because the
+	// credentials may not list them.
+	Unavailable []string `json:"unavailable,omitempty"`
 }
 
-func PortsHandler(client kubernetes.Interface) http.HandlerFunc {
+func PortsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		_, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -65,31 +70,37 @@ func PortsHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		pods, err := listPods(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		services, err := client.CoreV1().Services(namespace).List(ctx, opts)
+		services, err := listServices(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		slices, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, opts)
+		slices, err := listEndpointSlices(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+		ingresses, err := listIngresses(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		response := buildPorts(pods.Items, services.Items, slices.Items, ingresses.Items)
+		gatewayAPI, err := listGatewayAPI(ctx, cache, namespace, selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		response := buildPorts(pods, services, slices, ingresses, gatewayAPI)
 		respondJSON(w, http.StatusOK, response)
 	}
 }
@@ -99,6 +110,7 @@ func buildPorts(
 	services []corev1.Service,
 	slices []discoveryv1.EndpointSlice,
 	ingresses []networkingv1.Ingress,
+	gatewayAPI gatewaySources,
 ) PortsResponse {
 	containerPorts := []ContainerPortMapping{}
 	for _, pod := range pods {
@@ -187,7 +199,14 @@ func buildPorts(
 		}
 		return servicePorts[i].Namespace < servicePorts[j].Namespace
 	})
-	return PortsResponse{Services: servicePorts, Containers: containerPorts, Ingresses: ingressPorts}
+	return PortsResponse{
+		Services:    servicePorts,
+		Containers:  containerPorts,
+		Ingresses:   ingressPorts,
+		Listeners:   mapGatewayListeners(gatewayAPI.gateways),
+		Routes:      mapRouteIntents(gatewayAPI.routes),
+		Unavailable: gatewayAPI.unavailable,
+	}
 }
 
 func serviceEndpointsForPort(port corev1.ServicePort, slices []discoveryv1.EndpointSlice) []string {
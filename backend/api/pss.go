@@ -0,0 +1,326 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	pssPrivileged = "privileged"
+	pssBaseline   = "baseline"
+	pssRestricted = "restricted"
+
+	pssLabelPrefix = "pod-security.kubernetes.io/"
+)
+
+var pssLevels = []string{pssPrivileged, pssBaseline, pssRestricted}
+
+type PodSecurityViolation struct {
+	Level     string   `json:"level"`
+	Check     string   `json:"check"`
+	Details   string   `json:"details"`
+	Container string   `json:"container,omitempty"`
+	Values    []string `json:"values,omitempty"`
+}
+
+// WorkloadPodSecurity scores one pod template. Level is the strictest profile
+// the template satisfies; RejectedIfRaised lists the profiles above the
+// namespace's enforce level that would reject it.
+type WorkloadPodSecurity struct {
+	Kind             string                 `json:"kind"`
+	Namespace        string                 `json:"namespace"`
+	Name             string                 `json:"name"`
+	Level            string                 `json:"level"`
+	Enforce          string                 `json:"enforce"`
+	Compliant        bool                   `json:"compliant"`
+	RejectedIfRaised []string               `json:"rejectedIfRaised"`
+	Violations       []PodSecurityViolation `json:"violations"`
+}
+
+type NamespacePodSecurity struct {
+	Name             string         `json:"name"`
+	Enforce          string         `json:"enforce"`
+	Audit            string         `json:"audit"`
+	Warn             string         `json:"warn"`
+	Labeled          bool           `json:"labeled"`
+	Workloads        int            `json:"workloads"`
+	RejectedIfRaised map[string]int `json:"rejectedIfRaised"`
+}
+
+type PodSecurityResponse struct {
+	Namespaces []NamespacePodSecurity `json:"namespaces"`
+	Workloads  []WorkloadPodSecurity  `json:"workloads"`
+	// Unavailable names the optional resources left out because the
+	// credentials may not list them. Without namespaces, the requested
+	// namespace is assumed unlabeled and enforce is privileged.
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+type namespacePSS struct {
+	enforce string
+	audit   string
+	warn    string
+	labeled bool
+}
+
+func PodSecurityHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		namespace := r.URL.Query().Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
+		}
+
+		snapshot := &ValidationSnapshot{Namespace: namespace}
+		var unavailable []string
+		var err error
+		snapshot.Namespaces, err = listNamespaces(ctx, cache, labels.Everything())
+		if apierrors.IsForbidden(err) && namespace != v1.NamespaceAll {
+			// Namespaces are cluster-scoped; namespace-scoped credentials
+			// still get their own namespace, scored at the default level.
+			snapshot.Namespaces = []corev1.Namespace{{ObjectMeta: v1.ObjectMeta{Name: namespace}}}
+			unavailable = append(unavailable, "namespaces")
+		} else if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.Pods, err = listPods(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.Deployments, err = listDeployments(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.StatefulSets, err = listStatefulSets(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.DaemonSets, err = listDaemonSets(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.Jobs, err = listJobs(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		if snapshot.CronJobs, err = listCronJobs(ctx, cache, namespace, labels.Everything()); err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		workloads := evaluateWorkloadPodSecurity(snapshot)
+		respondJSON(w, http.StatusOK, PodSecurityResponse{
+			Namespaces:  summarizeNamespacePodSecurity(snapshot, workloads),
+			Workloads:   workloads,
+			Unavailable: unavailable,
+		})
+	}
+}
+
+func evaluateWorkloadPodSecurity(s *ValidationSnapshot) []WorkloadPodSecurity {
+	levels := namespacePSSLevels(s.Namespaces)
+	items := []WorkloadPodSecurity{}
+	for _, t := range snapshotPodTemplates(s) {
+		ns := levels[t.meta.Namespace]
+		if ns.enforce == "" {
+			ns.enforce = pssPrivileged
+		}
+		violations := evaluatePodSecurity(t.template.ObjectMeta, t.template.Spec)
+		level := podSecurityLevel(violations)
+		item := WorkloadPodSecurity{
+			Kind:             t.kind,
+			Namespace:        t.meta.Namespace,
+			Name:             t.meta.Name,
+			Level:            level,
+			Enforce:          ns.enforce,
+			Compliant:        pssRank(level) >= pssRank(ns.enforce),
+			RejectedIfRaised: []string{},
+			Violations:       violations,
+		}
+		for _, candidate := range pssLevels {
+			if pssRank(candidate) > pssRank(ns.enforce) && pssRank(candidate) > pssRank(level) {
+				item.RejectedIfRaised = append(item.RejectedIfRaised, candidate)
+			}
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Namespace != items[j].Namespace {
+			return items[i].Namespace < items[j].Namespace
+		}
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+func summarizeNamespacePodSecurity(s *ValidationSnapshot, workloads []WorkloadPodSecurity) []NamespacePodSecurity {
+	levels := namespacePSSLevels(s.Namespaces)
+	items := []NamespacePodSecurity{}
+	index := map[string]int{}
+	for _, ns := range s.Namespaces {
+		if s.Namespace != v1.NamespaceAll && ns.Name != s.Namespace {
+			continue
+		}
+		pss := levels[ns.Name]
+		item := NamespacePodSecurity{
+			Name:             ns.Name,
+			Enforce:          pss.enforce,
+			Audit:            pss.audit,
+			Warn:             pss.warn,
+			Labeled:          pss.labeled,
+			RejectedIfRaised: map[string]int{},
+		}
+		if item.Enforce == "" {
+			item.Enforce = pssPrivileged
+		}
+		index[ns.Name] = len(items)
+		items = append(items, item)
+	}
+	for _, workload := range workloads {
+		i, ok := index[workload.Namespace]
+		if !ok {
+			continue
+		}
+		items[i].Workloads++
+		for _, level := range workload.RejectedIfRaised {
+			items[i].RejectedIfRaised[level]++
+		}
+	}
+	return items
+}
+
+// namespacePSSLevels reads the pod-security.kubernetes.io labels. Unknown
+// values are ignored; an unlabeled namespace runs at the cluster default,
+// which is privileged unless the admission plugin is configured otherwise.
+func namespacePSSLevels(namespaces []corev1.Namespace) map[string]namespacePSS {
+	levels := map[string]namespacePSS{}
+	for _, ns := range namespaces {
+		pss := namespacePSS{}
+		for key, value := range ns.Labels {
+			if !strings.HasPrefix(key, pssLabelPrefix) || pssRank(value) < 0 {
+				continue
+			}
+			switch strings.TrimPrefix(key, pssLabelPrefix) {
+			case "enforce":
+				pss.enforce = value
+				pss.labeled = true
+			case "audit":
+				pss.audit = value
+			case "warn":
+				pss.warn = value
+			}
+		}
+		levels[ns.Name] = pss
+	}
+	return levels
+}
+
+func ownedBy(refs []v1.OwnerReference, kinds ...string) bool {
+	for _, ref := range refs {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		for _, kind := range kinds {
+			if ref.Kind == kind {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pssRank(level string) int {
+	for i, candidate := range pssLevels {
+		if candidate == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func podSecurityLevel(violations []PodSecurityViolation) string {
+	level := pssRestricted
+	for _, violation := range violations {
+		switch violation.Level {
+		case pssBaseline:
+			return pssPrivileged
+		case pssRestricted:
+			level = pssBaseline
+		}
+	}
+	return level
+}
+
+// validatePodSecurity reports workloads that fail their namespace's enforce
+// level; they were admitted before the label was set and will be rejected
+// when recreated.
+func validatePodSecurity(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, workload := range evaluateWorkloadPodSecurity(s) {
+		if workload.Compliant {
+			continue
+		}
+		objects = append(objects, workload.Namespace+"/"+workload.Name+" ("+workload.Kind+", "+workload.Level+" < "+workload.Enforce+")")
+		refs = append(refs, ObjectRef{Kind: workload.Kind, Namespace: workload.Namespace, Name: workload.Name})
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "pod-security-enforced",
+		Severity: "warning",
+		Title:    "Workloads violate enforced Pod Security level",
+		Details:  "Pod templates do not satisfy the namespace's pod-security.kubernetes.io/enforce level; new pods will be rejected.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+// validatePodSecurityRaise reports, per profile, the workloads that would be
+// rejected if their namespace's enforcement were raised to it.
+func validatePodSecurityRaise(s *ValidationSnapshot) []ValidationItem {
+	items := []ValidationItem{}
+	workloads := evaluateWorkloadPodSecurity(s)
+	for _, level := range pssLevels[1:] {
+		objects := []string{}
+		refs := []ObjectRef{}
+		for _, workload := range workloads {
+			if !workload.Compliant {
+				continue
+			}
+			for _, rejected := range workload.RejectedIfRaised {
+				if rejected == level {
+					objects = append(objects, workload.Namespace+"/"+workload.Name+" ("+workload.Kind+")")
+					refs = append(refs, ObjectRef{Kind: workload.Kind, Namespace: workload.Namespace, Name: workload.Name})
+				}
+			}
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		items = append(items, ValidationItem{
+			ID:       "pod-security-raise-" + level,
+			Severity: "info",
+			Title:    "Workloads blocking " + level + " enforcement",
+			Details:  "These workloads would be rejected if their namespace enforced the " + level + " Pod Security Standard.",
+			Objects:  objects,
+			Refs:     refs,
+		})
+	}
+	return items
+}
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The checks follow the Pod Security Standards at their latest version. A
+// violation's Level is the least strict profile that forbids it; baseline
+// violations therefore also fail restricted.
+
+var pssBaselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true,
+	"FSETID": true, "KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true,
+	"SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+var pssSafeSysctls = map[string]bool{
+	"kernel.shm_rmid_forced":              true,
+	"net.ipv4.ip_local_port_range":        true,
+	"net.ipv4.ip_unprivileged_port_start": true,
+	"net.ipv4.tcp_syncookies":             true,
+	"net.ipv4.ping_group_range":           true,
+	"net.ipv4.ip_local_reserved_ports":    true,
+	"net.ipv4.tcp_keepalive_time":         true,
+	"net.ipv4.tcp_fin_timeout":            true,
+	"net.ipv4.tcp_keepalive_intvl":        true,
+	"net.ipv4.tcp_keepalive_probes":       true,
+}
+
+var pssSELinuxTypes = map[string]bool{
+	"": true, "container_t": true, "container_init_t": true, "container_kvm_t": true, "container_engine_t": true,
+}
+
+type pssContainer struct {
+	name            string
+	securityContext *corev1.SecurityContext
+	ports           []corev1.ContainerPort
+}
+
+func evaluatePodSecurity(meta v1.ObjectMeta, spec corev1.PodSpec) []PodSecurityViolation {
+	violations := []PodSecurityViolation{}
+	add := func(level, check, details, container string, values ...string) {
+		violations = append(violations, PodSecurityViolation{Level: level, Check: check, Details: details, Container: container, Values: values})
+	}
+
+	containers := pssContainers(spec)
+	podContext := spec.SecurityContext
+	if podContext == nil {
+		podContext = &corev1.PodSecurityContext{}
+	}
+	windows := spec.OS != nil && spec.OS.Name == corev1.Windows
+
+	// Baseline.
+	if spec.HostNetwork {
+		add(pssBaseline, "hostNamespaces", "hostNetwork is true", "")
+	}
+	if spec.HostPID {
+		add(pssBaseline, "hostNamespaces", "hostPID is true", "")
+	}
+	if spec.HostIPC {
+		add(pssBaseline, "hostNamespaces", "hostIPC is true", "")
+	}
+	if podContext.WindowsOptions != nil && isTrue(podContext.WindowsOptions.HostProcess) {
+		add(pssBaseline, "hostProcess", "pod runs as a Windows host process", "")
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			add(pssBaseline, "hostPathVolumes", "hostPath volume "+volume.Name, "", volume.HostPath.Path)
+		}
+	}
+	for _, sysctl := range podContext.Sysctls {
+		if !pssSafeSysctls[sysctl.Name] {
+			add(pssBaseline, "sysctls", "unsafe sysctl "+sysctl.Name, "", sysctl.Name)
+		}
+	}
+	if profile := podContext.SeccompProfile; profile != nil && profile.Type == corev1.SeccompProfileTypeUnconfined {
+		add(pssBaseline, "seccompProfile", "pod seccomp profile is Unconfined", "")
+	}
+	if profile := podContext.AppArmorProfile; profile != nil && profile.Type == corev1.AppArmorProfileTypeUnconfined {
+		add(pssBaseline, "appArmorProfile", "pod AppArmor profile is Unconfined", "")
+	}
+	if options := podContext.SELinuxOptions; options != nil {
+		if details, ok := seLinuxViolation(options); ok {
+			add(pssBaseline, "seLinuxOptions", details, "")
+		}
+	}
+	for key, value := range meta.Annotations {
+		if !strings.HasPrefix(key, corev1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix) {
+			continue
+		}
+		if value != corev1.DeprecatedAppArmorBetaProfileRuntimeDefault && !strings.HasPrefix(value, corev1.DeprecatedAppArmorBetaProfileNamePrefix) {
+			add(pssBaseline, "appArmorProfile", "AppArmor annotation sets "+value, strings.TrimPrefix(key, corev1.DeprecatedAppArmorBetaContainerAnnotationKeyPrefix), value)
+		}
+	}
+
+	for _, container := range containers {
+		sc := container.securityContext
+		for _, port := range container.ports {
+			if port.HostPort != 0 {
+				add(pssBaseline, "hostPorts", "container uses hostPort "+itoa(int(port.HostPort)), container.name)
+			}
+		}
+		if sc == nil {
+			continue
+		}
+		if isTrue(sc.Privileged) {
+			add(pssBaseline, "privileged", "container is privileged", container.name)
+		}
+		if sc.WindowsOptions != nil && isTrue(sc.WindowsOptions.HostProcess) {
+			add(pssBaseline, "hostProcess", "container runs as a Windows host process", container.name)
+		}
+		if sc.Capabilities != nil {
+			extra := []string{}
+			for _, capability := range sc.Capabilities.Add {
+				if !pssBaselineCapabilities[capability] {
+					extra = append(extra, string(capability))
+				}
+			}
+			if len(extra) > 0 {
+				add(pssBaseline, "capabilities", "container adds capabilities beyond the baseline set", container.name, extra...)
+			}
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			add(pssBaseline, "procMount", "container uses procMount "+string(*sc.ProcMount), container.name)
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			add(pssBaseline, "seccompProfile", "container seccomp profile is Unconfined", container.name)
+		}
+		if sc.AppArmorProfile != nil && sc.AppArmorProfile.Type == corev1.AppArmorProfileTypeUnconfined {
+			add(pssBaseline, "appArmorProfile", "container AppArmor profile is Unconfined", container.name)
+		}
+		if sc.SELinuxOptions != nil {
+			if details, ok := seLinuxViolation(sc.SELinuxOptions); ok {
+				add(pssBaseline, "seLinuxOptions", details, container.name)
+			}
+		}
+	}
+
+	// Restricted.
+	for _, volume := range spec.Volumes {
+		if kind := restrictedVolumeType(volume); kind != "" {
+			add(pssRestricted, "volumeTypes", "volume "+volume.Name+" uses "+kind, "", kind)
+		}
+	}
+	if podContext.RunAsUser != nil && *podContext.RunAsUser == 0 {
+		add(pssRestricted, "runAsUser", "pod runs as UID 0", "")
+	}
+
+	podNonRoot := isTrue(podContext.RunAsNonRoot)
+	podSeccomp := podContext.SeccompProfile != nil && allowedSeccomp(podContext.SeccompProfile.Type)
+	for _, container := range containers {
+		sc := container.securityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if !windows && (sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation) {
+			add(pssRestricted, "allowPrivilegeEscalation", "allowPrivilegeEscalation is not false", container.name)
+		}
+		switch {
+		case sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot:
+			add(pssRestricted, "runAsNonRoot", "runAsNonRoot is false", container.name)
+		case sc.RunAsNonRoot == nil && !podNonRoot:
+			add(pssRestricted, "runAsNonRoot", "runAsNonRoot is not set to true", container.name)
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			add(pssRestricted, "runAsUser", "container runs as UID 0", container.name)
+		}
+		if !windows {
+			switch {
+			case sc.SeccompProfile != nil && !allowedSeccomp(sc.SeccompProfile.Type):
+				// Unconfined is already a baseline violation.
+			case sc.SeccompProfile == nil && !podSeccomp:
+				add(pssRestricted, "seccompProfile", "seccomp profile is not RuntimeDefault or Localhost", container.name)
+			}
+			if !dropsAllCapabilities(sc.Capabilities) {
+				add(pssRestricted, "capabilities", "capabilities do not drop ALL", container.name)
+			}
+			if sc.Capabilities != nil {
+				extra := []string{}
+				for _, capability := range sc.Capabilities.Add {
+					if capability != "NET_BIND_SERVICE" && pssBaselineCapabilities[capability] {
+						extra = append(extra, string(capability))
+					}
+				}
+				if len(extra) > 0 {
+					add(pssRestricted, "capabilities", "container adds capabilities other than NET_BIND_SERVICE", container.name, extra...)
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+func pssContainers(spec corev1.PodSpec) []pssContainer {
+	containers := []pssContainer{}
+	for _, container := range spec.InitContainers {
+		containers = append(containers, pssContainer{name: container.Name, securityContext: container.SecurityContext, ports: container.Ports})
+	}
+	for _, container := range spec.Containers {
+		containers = append(containers, pssContainer{name: container.Name, securityContext: container.SecurityContext, ports: container.Ports})
+	}
+	for _, container := range spec.EphemeralContainers {
+		containers = append(containers, pssContainer{name: container.Name, securityContext: container.SecurityContext, ports: container.Ports})
+	}
+	return containers
+}
+
+func seLinuxViolation(options *corev1.SELinuxOptions) (string, bool) {
+	switch {
+	case !pssSELinuxTypes[options.Type]:
+		return "SELinux type " + options.Type + " is not allowed", true
+	case options.User != "":
+		return "SELinux user is set", true
+	case options.Role != "":
+		return "SELinux role is set", true
+	}
+	return "", false
+}
+
+// restrictedVolumeType returns the volume source for volumes outside the
+// restricted allow list, or "" when the volume is allowed. hostPath is left
+// to the baseline check.
+func restrictedVolumeType(volume corev1.Volume) string {
+	source := volume.VolumeSource
+	switch {
+	case source.ConfigMap != nil, source.CSI != nil, source.DownwardAPI != nil, source.EmptyDir != nil,
+		source.Ephemeral != nil, source.PersistentVolumeClaim != nil, source.Projected != nil, source.Secret != nil,
+		source.HostPath != nil:
+		return ""
+	case source.NFS != nil:
+		return "nfs"
+	case source.ISCSI != nil:
+		return "iscsi"
+	case source.GitRepo != nil:
+		return "gitRepo"
+	case source.RBD != nil:
+		return "rbd"
+	case source.CephFS != nil:
+		return "cephfs"
+	case source.FC != nil:
+		return "fc"
+	case source.Glusterfs != nil:
+		return "glusterfs"
+	case source.FlexVolume != nil:
+		return "flexVolume"
+	}
+	return "a non-core volume type"
+}
+
+func allowedSeccomp(profile corev1.SeccompProfileType) bool {
+	return profile == corev1.SeccompProfileTypeRuntimeDefault || profile == corev1.SeccompProfileTypeLocalhost
+}
+
+func dropsAllCapabilities(capabilities *corev1.Capabilities) bool {
+	if capabilities == nil {
+		return false
+	}
+	for _, capability := range capabilities.Drop {
+		if capability == "ALL" {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrue(value *bool) bool {
+	return value != nil && *value
+}
@@ -4,9 +4,10 @@ import (
 	"net/http"
 	"sort"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type RuleSummary struct {
@@ -64,12 +65,12 @@ type EffectivePermissions struct {
 	Rules          []RuleSummary `json:"rules"`
 }
 
-func RolesHandler(client kubernetes.Interface) http.HandlerFunc {
+func RolesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -80,14 +81,26 @@ func RolesHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.RbacV1().Roles(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Roles)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]RoleItem, 0, len(list.Items))
-		for _, role := range list.Items {
+		roles, err := lister.Roles(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(roles, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]RoleItem, 0, len(page))
+		for _, role := range page {
 			items = append(items, RoleItem{
 				Name:      role.Name,
 				Namespace: role.Namespace,
@@ -95,29 +108,41 @@ func RolesHandler(client kubernetes.Interface) http.HandlerFunc {
 			})
 		}
 
-		respondJSON(w, http.StatusOK, RoleList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, RoleList{Items: items, Continue: next})
 	}
 }
 
-func ClusterRolesHandler(client kubernetes.Interface) http.HandlerFunc {
+func ClusterRolesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		list, err := client.RbacV1().ClusterRoles().List(ctx, opts)
+		lister, err := cache.ClusterRoles(ctx)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]RoleItem, 0, len(list.Items))
-		for _, role := range list.Items {
+		roles, err := lister.List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(roles, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]RoleItem, 0, len(page))
+		for _, role := range page {
 			items = append(items, RoleItem{
 				Name:      role.Name,
 				Namespace: "",
@@ -125,16 +150,16 @@ func ClusterRolesHandler(client kubernetes.Interface) http.HandlerFunc {
 			})
 		}
 
-		respondJSON(w, http.StatusOK, RoleList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, RoleList{Items: items, Continue: next})
 	}
 }
 
-func RoleBindingsHandler(client kubernetes.Interface) http.HandlerFunc {
+func RoleBindingsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -145,14 +170,26 @@ func RoleBindingsHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.RbacV1().RoleBindings(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).RoleBindings)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]RoleBindingItem, 0, len(list.Items))
-		for _, binding := range list.Items {
+		bindings, err := lister.RoleBindings(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(bindings, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]RoleBindingItem, 0, len(page))
+		for _, binding := range page {
 			items = append(items, RoleBindingItem{
 				Name:      binding.Name,
 				Namespace: binding.Namespace,
@@ -162,29 +199,41 @@ func RoleBindingsHandler(client kubernetes.Interface) http.HandlerFunc {
 			})
 		}
 
-		respondJSON(w, http.StatusOK, RoleBindingList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, RoleBindingList{Items: items, Continue: next})
 	}
 }
 
-func ClusterRoleBindingsHandler(client kubernetes.Interface) http.HandlerFunc {
+func ClusterRoleBindingsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		list, err := client.RbacV1().ClusterRoleBindings().List(ctx, opts)
+		lister, err := cache.ClusterRoleBindings(ctx)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		bindings, err := lister.List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(bindings, opts)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		items := make([]RoleBindingItem, 0, len(list.Items))
-		for _, binding := range list.Items {
+		items := make([]RoleBindingItem, 0, len(page))
+		for _, binding := range page {
 			items = append(items, RoleBindingItem{
 				Name:      binding.Name,
 				Namespace: "",
@@ -194,16 +243,16 @@ func ClusterRoleBindingsHandler(client kubernetes.Interface) http.HandlerFunc {
 			})
 		}
 
-		respondJSON(w, http.StatusOK, RoleBindingList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, RoleBindingList{Items: items, Continue: next})
 	}
 }
 
-func ServiceAccountsHandler(client kubernetes.Interface) http.HandlerFunc {
+func ServiceAccountsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -214,25 +263,37 @@ func ServiceAccountsHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).ServiceAccounts)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		accounts, err := lister.ServiceAccounts(namespace).List(selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(accounts, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		items := make([]ServiceAccountItem, 0, len(list.Items))
-		for _, sa := range list.Items {
+		items := make([]ServiceAccountItem, 0, len(page))
+		for _, sa := range page {
 			items = append(items, ServiceAccountItem{
 				Name:      sa.Name,
 				Namespace: sa.Namespace,
 			})
 		}
 
-		respondJSON(w, http.StatusOK, ServiceAccountList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, ServiceAccountList{Items: items, Continue: next})
 	}
 }
 
-func EffectivePermissionsHandler(client kubernetes.Interface) http.HandlerFunc {
+func EffectivePermissionsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
@@ -244,42 +305,42 @@ func EffectivePermissionsHandler(client kubernetes.Interface) http.HandlerFunc {
 			return
 		}
 
-		roleBindings, err := client.RbacV1().RoleBindings(namespace).List(ctx, v1.ListOptions{})
+		roleBindings, err := listRoleBindings(ctx, cache, namespace, labels.Everything())
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		clusterRoleBindings, err := client.RbacV1().ClusterRoleBindings().List(ctx, v1.ListOptions{})
+		clusterRoleBindings, err := listClusterRoleBindings(ctx, cache, labels.Everything())
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		roles, err := client.RbacV1().Roles(namespace).List(ctx, v1.ListOptions{})
+		roles, err := listRoles(ctx, cache, namespace, labels.Everything())
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		clusterRoles, err := client.RbacV1().ClusterRoles().List(ctx, v1.ListOptions{})
+		clusterRoles, err := listClusterRoles(ctx, cache, labels.Everything())
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
 		roleRules := map[string][]RuleSummary{}
-		for _, role := range roles.Items {
+		for _, role := range roles {
 			roleRules[role.Name] = mapRules(role.Rules)
 		}
 
 		clusterRoleRules := map[string][]RuleSummary{}
-		for _, role := range clusterRoles.Items {
+		for _, role := range clusterRoles {
 			clusterRoleRules[role.Name] = mapRules(role.Rules)
 		}
 
 		rules := []RuleSummary{}
-		for _, binding := range roleBindings.Items {
+		for _, binding := range roleBindings {
 			if !bindingApplies(binding.Subjects, namespace, serviceAccount) {
 				continue
 			}
@@ -291,7 +352,7 @@ func EffectivePermissionsHandler(client kubernetes.Interface) http.HandlerFunc {
 			}
 		}
 
-		for _, binding := range clusterRoleBindings.Items {
+		for _, binding := range clusterRoleBindings {
 			if !bindingApplies(binding.Subjects, namespace, serviceAccount) {
 				continue
 			}
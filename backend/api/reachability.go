@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type ReachabilityResponse struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Port     int32          `json:"port"`
+	Protocol string         `json:"protocol"`
+	Allowed  bool           `json:"allowed"`
+	Egress   PolicyDecision `json:"egress"`
+	Ingress  PolicyDecision `json:"ingress"`
+}
+
+func ReachabilityHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		query := r.URL.Query()
+		fromNamespace, fromName, err := parsePodRef(query.Get("from"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid from: "+err.Error())
+			return
+		}
+		toNamespace, toName, err := parsePodRef(query.Get("to"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid to: "+err.Error())
+			return
+		}
+
+		protocol := corev1.Protocol(strings.ToUpper(query.Get("protocol")))
+		switch protocol {
+		case "":
+			protocol = corev1.ProtocolTCP
+		case corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			respondError(w, http.StatusBadRequest, "invalid protocol: must be TCP, UDP or SCTP")
+			return
+		}
+
+		from, err := getPod(ctx, cache, fromNamespace, fromName)
+		if apierrors.IsNotFound(err) {
+			respondError(w, http.StatusNotFound, "pod "+fromNamespace+"/"+fromName+" not found")
+			return
+		}
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		to, err := getPod(ctx, cache, toNamespace, toName)
+		if apierrors.IsNotFound(err) {
+			respondError(w, http.StatusNotFound, "pod "+toNamespace+"/"+toName+" not found")
+			return
+		}
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		port, err := parseTargetPort(query.Get("port"), to, protocol)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		policies, err := listNetworkPolicies(ctx, cache, v1.NamespaceAll, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		namespaces, err := listNamespaces(ctx, cache, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		evaluator := newPolicyEvaluator(policies, namespaces)
+		response := ReachabilityResponse{
+			From:     fromNamespace + "/" + fromName,
+			To:       toNamespace + "/" + toName,
+			Port:     port,
+			Protocol: string(protocol),
+			Egress:   evaluator.egress(from, to, port, protocol),
+			Ingress:  evaluator.ingress(from, to, port, protocol),
+		}
+		response.Allowed = response.Egress.Allowed && response.Ingress.Allowed
+
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+func parsePodRef(value string) (string, string, error) {
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" {
+		return "", "", fmt.Errorf("expected namespace/pod")
+	}
+	return namespace, name, nil
+}
+
+// parseTargetPort accepts a number or a container port name on the target.
+func parseTargetPort(value string, pod corev1.Pod, protocol corev1.Protocol) (int32, error) {
+	if value == "" {
+		return 0, fmt.Errorf("port is required")
+	}
+	if number, err := strconv.ParseInt(value, 10, 32); err == nil {
+		if number < 1 || number > 65535 {
+			return 0, fmt.Errorf("invalid port")
+		}
+		return int32(number), nil
+	}
+	port, ok := namedContainerPort(pod, value, protocol)
+	if !ok {
+		return 0, fmt.Errorf("pod %s/%s has no %s port named %q", pod.Namespace, pod.Name, protocol, value)
+	}
+	return port, nil
+}
+
+func getPod(ctx context.Context, cache *kube.Cache, namespace, name string) (corev1.Pod, error) {
+	lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Pods)
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+	pod, err := lister.Pods(namespace).Get(name)
+	if err != nil {
+		return corev1.Pod{}, err
+	}
+	return *pod, nil
+}
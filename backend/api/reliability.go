@@ -0,0 +1,328 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// podTemplate is a pod template with the workload that owns it. Replicas is
+// nil for kinds without a replica count.
+type podTemplate struct {
+	kind     string
+	meta     v1.ObjectMeta
+	template corev1.PodTemplateSpec
+	replicas *int32
+	selector *v1.LabelSelector
+}
+
+func (t podTemplate) ref() ObjectRef {
+	return ObjectRef{Kind: t.kind, Namespace: t.meta.Namespace, Name: t.meta.Name}
+}
+
+func (t podTemplate) String() string {
+	return t.meta.Namespace + "/" + t.meta.Name + " (" + t.kind + ")"
+}
+
+// longRunning excludes Jobs and CronJobs, whose pods run to completion.
+func (t podTemplate) longRunning() bool {
+	return t.kind != "Job" && t.kind != "CronJob"
+}
+
+// snapshotPodTemplates returns every pod template in the snapshot. Pods
+// created by a workload controller are covered by its template; other pods
+// count as their own template.
+func snapshotPodTemplates(s *ValidationSnapshot) []podTemplate {
+	templates := []podTemplate{}
+	for _, item := range s.Deployments {
+		replicas := int32(1)
+		if item.Spec.Replicas != nil {
+			replicas = *item.Spec.Replicas
+		}
+		templates = append(templates, podTemplate{kind: "Deployment", meta: item.ObjectMeta, template: item.Spec.Template, replicas: &replicas, selector: item.Spec.Selector})
+	}
+	for _, item := range s.StatefulSets {
+		replicas := int32(1)
+		if item.Spec.Replicas != nil {
+			replicas = *item.Spec.Replicas
+		}
+		templates = append(templates, podTemplate{kind: "StatefulSet", meta: item.ObjectMeta, template: item.Spec.Template, replicas: &replicas, selector: item.Spec.Selector})
+	}
+	for _, item := range s.DaemonSets {
+		templates = append(templates, podTemplate{kind: "DaemonSet", meta: item.ObjectMeta, template: item.Spec.Template, selector: item.Spec.Selector})
+	}
+	for _, item := range s.CronJobs {
+		templates = append(templates, podTemplate{kind: "CronJob", meta: item.ObjectMeta, template: item.Spec.JobTemplate.Spec.Template})
+	}
+	for _, item := range s.Jobs {
+		if !ownedBy(item.OwnerReferences, "CronJob") {
+			templates = append(templates, podTemplate{kind: "Job", meta: item.ObjectMeta, template: item.Spec.Template, selector: item.Spec.Selector})
+		}
+	}
+	for _, item := range s.Pods {
+		if !ownedBy(item.OwnerReferences, "ReplicaSet", "StatefulSet", "DaemonSet", "Job") {
+			templates = append(templates, podTemplate{kind: "Pod", meta: item.ObjectMeta, template: corev1.PodTemplateSpec{ObjectMeta: item.ObjectMeta, Spec: item.Spec}})
+		}
+	}
+	return templates
+}
+
+func validateProbes(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, t := range snapshotPodTemplates(s) {
+		if !t.longRunning() {
+			continue
+		}
+		findings := []string{}
+		for _, container := range t.template.Spec.Containers {
+			missing := []string{}
+			if container.ReadinessProbe == nil {
+				missing = append(missing, "readiness")
+			}
+			if container.LivenessProbe == nil {
+				missing = append(missing, "liveness")
+			}
+			if len(missing) > 0 {
+				findings = append(findings, "container "+container.Name+": no "+strings.Join(missing, ", "))
+			}
+		}
+		if len(findings) > 0 {
+			objects = append(objects, t.String()+" "+strings.Join(findings, "; "))
+			refs = append(refs, t.ref())
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "probes-missing",
+		Severity: "warning",
+		Title:    "Containers without probes",
+		Details:  "Containers have no readiness or liveness probe; traffic and restarts do not track their health.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func validateResources(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, t := range snapshotPodTemplates(s) {
+		// Sidecars are left out, as in validateProbes.
+		containers := []corev1.Container{}
+		for _, container := range t.template.Spec.InitContainers {
+			if !isSidecar(container) {
+				containers = append(containers, container)
+			}
+		}
+		containers = append(containers, t.template.Spec.Containers...)
+		findings := []string{}
+		for _, container := range containers {
+			missing := []string{}
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				if _, ok := container.Resources.Requests[name]; !ok {
+					missing = append(missing, "requests."+string(name))
+				}
+			}
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				if _, ok := container.Resources.Limits[name]; !ok {
+					missing = append(missing, "limits."+string(name))
+				}
+			}
+			if len(missing) > 0 {
+				findings = append(findings, "container "+container.Name+": no "+strings.Join(missing, ", "))
+			}
+		}
+		if len(findings) > 0 {
+			objects = append(objects, t.String()+" "+strings.Join(findings, "; "))
+			refs = append(refs, t.ref())
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "resources-missing",
+		Severity: "warning",
+		Title:    "Containers without resource requests or limits",
+		Details:  "Containers lack CPU or memory requests or limits, so scheduling and eviction cannot account for them.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func validateSingleReplica(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, t := range snapshotPodTemplates(s) {
+		if t.kind != "Deployment" || *t.replicas != 1 {
+			continue
+		}
+		services := selectingServices(s.Services, t)
+		if len(services) == 0 {
+			continue
+		}
+		objects = append(objects, t.String()+" behind "+strings.Join(services, ", "))
+		refs = append(refs, t.ref())
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "single-replica-service",
+		Severity: "warning",
+		Title:    "Single-replica Deployments behind a Service",
+		Details:  "The Service has no endpoints while the only replica restarts, reschedules or rolls out.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func validatePDBCoverage(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, t := range snapshotPodTemplates(s) {
+		if t.kind != "Deployment" || *t.replicas == 0 {
+			continue
+		}
+		pdbs := matchingPDBs(s.PodDisruptionBudgets, t)
+		if len(pdbs) == 0 {
+			objects = append(objects, t.String()+": no PodDisruptionBudget")
+			refs = append(refs, t.ref())
+			continue
+		}
+		for _, pdb := range pdbs {
+			if pdbAllowsNoDisruption(pdb, *t.replicas) {
+				objects = append(objects, t.String()+": PodDisruptionBudget "+pdb.Name+" allows zero disruptions")
+				refs = append(refs, t.ref())
+			}
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "pdb-coverage",
+		Severity: "warning",
+		Title:    "Deployments without usable PodDisruptionBudget",
+		Details:  "Without a PDB, node drains can evict every replica at once; a PDB allowing zero disruptions blocks drains instead.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+// validateReplicaSpread flags replicated workloads whose template asks for
+// no spread, and those whose running pods all landed on one node.
+func validateReplicaSpread(s *ValidationSnapshot) []ValidationItem {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for _, t := range snapshotPodTemplates(s) {
+		if t.replicas == nil || *t.replicas < 2 {
+			continue
+		}
+		reasons := []string{}
+		if !spreadsReplicas(t.template.Spec) {
+			reasons = append(reasons, "no topologySpreadConstraints or pod anti-affinity")
+		}
+		pods := podsOf(s.Pods, t)
+		if nodes := podNodes(pods); len(pods) > 1 && len(nodes) == 1 {
+			reasons = append(reasons, "all running pods on node "+nodes[0])
+		}
+		if len(reasons) > 0 {
+			objects = append(objects, t.String()+": "+strings.Join(reasons, "; "))
+			refs = append(refs, t.ref())
+		}
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	return []ValidationItem{{
+		ID:       "replica-spread",
+		Severity: "warning",
+		Title:    "Replicas not spread across nodes or zones",
+		Details:  "Replicas can share a node or zone, so one failure takes them all down.",
+		Objects:  objects,
+		Refs:     refs,
+	}}
+}
+
+func selectingServices(services []corev1.Service, t podTemplate) []string {
+	names := []string{}
+	for _, svc := range services {
+		if svc.Namespace == t.meta.Namespace && mapSelector(svc.Spec.Selector).Matches(labels.Set(t.template.Labels)) {
+			names = append(names, svc.Name)
+		}
+	}
+	return names
+}
+
+func matchingPDBs(pdbs []policyv1.PodDisruptionBudget, t podTemplate) []policyv1.PodDisruptionBudget {
+	matched := []policyv1.PodDisruptionBudget{}
+	for _, pdb := range pdbs {
+		if pdb.Namespace == t.meta.Namespace && labelSelector(pdb.Spec.Selector).Matches(labels.Set(t.template.Labels)) {
+			matched = append(matched, pdb)
+		}
+	}
+	return matched
+}
+
+// pdbAllowsNoDisruption reads the PDB spec rather than its status, which is
+// also zero during every rollout.
+func pdbAllowsNoDisruption(pdb policyv1.PodDisruptionBudget, replicas int32) bool {
+	if value := pdb.Spec.MaxUnavailable; value != nil {
+		unavailable, err := intstr.GetScaledValueFromIntOrPercent(value, int(replicas), true)
+		return err == nil && unavailable <= 0
+	}
+	if value := pdb.Spec.MinAvailable; value != nil {
+		available, err := intstr.GetScaledValueFromIntOrPercent(value, int(replicas), true)
+		return err == nil && available >= int(replicas)
+	}
+	return false
+}
+
+func spreadsReplicas(spec corev1.PodSpec) bool {
+	if len(spec.TopologySpreadConstraints) > 0 {
+		return true
+	}
+	affinity := spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+	anti := affinity.PodAntiAffinity
+	return len(anti.RequiredDuringSchedulingIgnoredDuringExecution) > 0 || len(anti.PreferredDuringSchedulingIgnoredDuringExecution) > 0
+}
+
+func podsOf(pods []corev1.Pod, t podTemplate) []corev1.Pod {
+	if t.selector == nil {
+		return nil
+	}
+	selector := labelSelector(t.selector)
+	matched := []corev1.Pod{}
+	for _, pod := range pods {
+		if pod.Namespace == t.meta.Namespace && pod.Status.Phase == corev1.PodRunning && selector.Matches(labels.Set(pod.Labels)) {
+			matched = append(matched, pod)
+		}
+	}
+	return matched
+}
+
+func podNodes(pods []corev1.Pod) []string {
+	seen := map[string]bool{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != "" {
+			seen[pod.Spec.NodeName] = true
+		}
+	}
+	nodes := make([]string, 0, len(seen))
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
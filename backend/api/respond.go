@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 func respondJSON(w http.ResponseWriter, status int, payload any) {
@@ -14,3 +16,13 @@ func respondJSON(w http.ResponseWriter, status int, payload any) {
 func respondError(w http.ResponseWriter, status int, message string) {
 	respondJSON(w, status, map[string]string{"error": message})
 }
+
+// respondClusterError reports a failed cluster read: 403 when the
+// credentials may not read the resource, else 502.
+func respondClusterError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	if apierrors.IsForbidden(err) {
+		status = http.StatusForbidden
+	}
+	respondError(w, status, err.Error())
+}
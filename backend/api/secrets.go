@@ -0,0 +1,334 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretItem describes a Secret without its values. Type and Keys need the
+// Secret body, so they are empty when only metadata may be read.
+type SecretItem struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Type      string            `json:"type,omitempty"`
+	Keys      []SecretKey       `json:"keys,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	UsedBy    []SecretUser      `json:"usedBy"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// SecretKey is one data key. Value is only set when secret values are
+// allowed; values that are not valid UTF-8 are base64 encoded.
+type SecretKey struct {
+	Name   string  `json:"name"`
+	Size   int     `json:"size"`
+	Value  *string `json:"value,omitempty"`
+	Base64 bool    `json:"base64,omitempty"`
+}
+
+// SecretUser is a pod or ServiceAccount referencing a Secret, or an owner of
+// it. Via names the reference, e.g. PodMountsSecret or ServiceAccountToken.
+type SecretUser struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Via  string `json:"via"`
+}
+
+type SecretList struct {
+	Items        []SecretItem `json:"items"`
+	Continue     string       `json:"continue"`
+	MetadataOnly bool         `json:"metadataOnly"`
+}
+
+type SecretDetail struct {
+	SecretItem
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Decoded      *SecretDecoded    `json:"decoded,omitempty"`
+	MetadataOnly bool              `json:"metadataOnly"`
+	Values       bool              `json:"values"`
+}
+
+// SecretsHandler lists Secret metadata from the metadata informer. Unless
+// metadataOnly is set, the bodies of the page are read to report type, key
+// names and sizes; otherwise those stay empty, as Secret metadata carries
+// none of them. Values are never returned.
+func SecretsHandler(cache *kube.Cache, clientset kubernetes.Interface, metadataOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		opts, selector, err := parseListSelector(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		namespace := r.URL.Query().Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
+		}
+
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).SecretMetadata)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		secrets, err := lister.Namespace(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(secrets, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		users, err := secretUsers(ctx, cache, namespace)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		bodies := map[string]corev1.Secret{}
+		if !metadataOnly && len(page) > 0 {
+			bodies, err = secretBodies(ctx, clientset, namespace, opts.LabelSelector, page)
+			if err != nil {
+				respondClusterError(w, err)
+				return
+			}
+		}
+
+		items := make([]SecretItem, 0, len(page))
+		for _, meta := range page {
+			key := meta.Namespace + "/" + meta.Name
+			var body *corev1.Secret
+			if secret, ok := bodies[key]; ok {
+				body = &secret
+			}
+			items = append(items, mapSecret(meta, body, users[key], false))
+		}
+
+		respondJSON(w, http.StatusOK, SecretList{Items: items, Continue: next, MetadataOnly: metadataOnly})
+	}
+}
+
+// SecretDetailHandler describes one Secret. Unless metadataOnly is set, its
+// body is read and decoded by type; values are only included with
+// allowValues.
+func SecretDetailHandler(cache *kube.Cache, clientset kubernetes.Interface, metadataOnly, allowValues bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		namespace := r.URL.Query().Get("ns")
+		name := r.URL.Query().Get("name")
+		if namespace == "" || name == "" {
+			respondError(w, http.StatusBadRequest, "ns and name are required")
+			return
+		}
+
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).SecretMetadata)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		object, err := lister.Namespace(namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			respondError(w, http.StatusNotFound, "secret "+namespace+"/"+name+" not found")
+			return
+		}
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		var body *corev1.Secret
+		if !metadataOnly {
+			body, err = clientset.CoreV1().Secrets(namespace).Get(ctx, name, v1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				respondError(w, http.StatusNotFound, "secret "+namespace+"/"+name+" not found")
+				return
+			}
+			if err != nil {
+				respondClusterError(w, err)
+				return
+			}
+		}
+
+		users, err := secretUsers(ctx, cache, namespace)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		detail := SecretDetail{
+			SecretItem:   mapSecret(object, body, users[namespace+"/"+name], allowValues),
+			Annotations:  secretAnnotations(object.Annotations),
+			MetadataOnly: metadataOnly,
+			Values:       allowValues && body != nil,
+		}
+		if body != nil {
+			detail.Decoded = decodeSecret(body)
+		}
+
+		respondJSON(w, http.StatusOK, detail)
+	}
+}
+
+// Small pages read their Secret bodies one by one; larger ones list the
+// namespace in chunks and keep only the page, so memory follows the page
+// size rather than the cluster.
+const (
+	secretBodyGets  = 20
+	secretListChunk = 250
+)
+
+func secretBodies(ctx context.Context, clientset kubernetes.Interface, namespace, labelSelector string, page []*v1.PartialObjectMetadata) (map[string]corev1.Secret, error) {
+	bodies := map[string]corev1.Secret{}
+	if len(page) <= secretBodyGets {
+		for _, meta := range page {
+			secret, err := clientset.CoreV1().Secrets(meta.Namespace).Get(ctx, meta.Name, v1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			bodies[meta.Namespace+"/"+meta.Name] = *secret
+		}
+		return bodies, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, meta := range page {
+		wanted[meta.Namespace+"/"+meta.Name] = true
+	}
+	opts := v1.ListOptions{LabelSelector: labelSelector, Limit: secretListChunk}
+	for {
+		list, err := clientset.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range list.Items {
+			if key := secret.Namespace + "/" + secret.Name; wanted[key] {
+				bodies[key] = secret
+			}
+		}
+		if list.Continue == "" {
+			return bodies, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
+
+func mapSecret(meta *v1.PartialObjectMetadata, body *corev1.Secret, users []SecretUser, withValues bool) SecretItem {
+	item := SecretItem{
+		Name:      meta.Name,
+		Namespace: meta.Namespace,
+		Labels:    meta.Labels,
+		UsedBy:    users,
+		CreatedAt: meta.CreationTimestamp.Time,
+	}
+	if item.UsedBy == nil {
+		item.UsedBy = []SecretUser{}
+	}
+	if account := meta.Annotations[corev1.ServiceAccountNameKey]; account != "" {
+		item.UsedBy = append(item.UsedBy, SecretUser{Kind: "ServiceAccount", Name: account, Via: "ServiceAccountToken"})
+	}
+	for _, owner := range meta.OwnerReferences {
+		item.UsedBy = append(item.UsedBy, SecretUser{Kind: owner.Kind, Name: owner.Name, Via: "OwnerReference"})
+	}
+	if body == nil {
+		return item
+	}
+
+	item.Type = string(body.Type)
+	for name, data := range body.Data {
+		key := SecretKey{Name: name, Size: len(data)}
+		if withValues {
+			value := string(data)
+			if !utf8.Valid(data) {
+				value = base64.StdEncoding.EncodeToString(data)
+				key.Base64 = true
+			}
+			key.Value = &value
+		}
+		item.Keys = append(item.Keys, key)
+	}
+	sort.Slice(item.Keys, func(i, j int) bool {
+		return item.Keys[i].Name < item.Keys[j].Name
+	})
+	return item
+}
+
+// secretAnnotations drops the last-applied annotation, which kubectl apply
+// fills with the full Secret including its values.
+func secretAnnotations(annotations map[string]string) map[string]string {
+	out := map[string]string{}
+	for key, value := range annotations {
+		if key != corev1.LastAppliedConfigAnnotation {
+			out[key] = value
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// secretUsers indexes the pods and ServiceAccounts referencing each Secret
+// by namespace/name.
+func secretUsers(ctx context.Context, cache *kube.Cache, namespace string) (map[string][]SecretUser, error) {
+	pods, err := listPods(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	serviceAccounts, err := listServiceAccounts(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[string][]SecretUser{}
+	seen := map[string]bool{}
+	add := func(namespace, secret string, user SecretUser) {
+		key := namespace + "/" + secret
+		id := key + "|" + user.Kind + "/" + user.Name + "|" + user.Via
+		if secret == "" || seen[id] {
+			return
+		}
+		seen[id] = true
+		users[key] = append(users[key], user)
+	}
+
+	for _, pod := range pods {
+		for _, ref := range podDependencies(pod) {
+			if ref.kind == "Secret" {
+				add(pod.Namespace, ref.name, SecretUser{Kind: "Pod", Name: pod.Name, Via: ref.edge})
+			}
+		}
+	}
+	for _, sa := range serviceAccounts {
+		for _, ref := range sa.Secrets {
+			add(sa.Namespace, ref.Name, SecretUser{Kind: "ServiceAccount", Name: sa.Name, Via: "ServiceAccountSecret"})
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			add(sa.Namespace, ref.Name, SecretUser{Kind: "ServiceAccount", Name: sa.Name, Via: "ServiceAccountImagePullSecret"})
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SecretDecoded summarizes a Secret by type without exposing credentials:
+// the public certificates of TLS secrets, the registries of docker configs
+// and the claims of service account tokens.
+type SecretDecoded struct {
+	Certificates   []CertificateInfo    `json:"certificates,omitempty"`
+	Registries     []string             `json:"registries,omitempty"`
+	ServiceAccount *ServiceAccountToken `json:"serviceAccount,omitempty"`
+	Error          string               `json:"error,omitempty"`
+}
+
+type CertificateInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	DNSNames    []string  `json:"dnsNames,omitempty"`
+	IPAddresses []string  `json:"ipAddresses,omitempty"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+	IsCA        bool      `json:"isCA"`
+}
+
+// ServiceAccountToken describes a service-account-token Secret. Issuer and
+// Expires come from the unverified JWT claims; legacy tokens do not expire.
+type ServiceAccountToken struct {
+	Name    string     `json:"name"`
+	UID     string     `json:"uid,omitempty"`
+	Issuer  string     `json:"issuer,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	HasCA   bool       `json:"hasCA"`
+}
+
+func decodeSecret(secret *corev1.Secret) *SecretDecoded {
+	decoded := &SecretDecoded{}
+	var err error
+	switch secret.Type {
+	case corev1.SecretTypeTLS:
+		decoded.Certificates, err = parseCertificates(secret.Data[corev1.TLSCertKey])
+	case corev1.SecretTypeDockerConfigJson:
+		decoded.Registries, err = dockerRegistries(secret.Data[corev1.DockerConfigJsonKey], true)
+	case corev1.SecretTypeDockercfg:
+		decoded.Registries, err = dockerRegistries(secret.Data[corev1.DockerConfigKey], false)
+	case corev1.SecretTypeServiceAccountToken:
+		decoded.ServiceAccount = serviceAccountToken(secret)
+	default:
+		return nil
+	}
+	if err != nil {
+		decoded.Error = err.Error()
+	}
+	return decoded
+}
+
+// parseCertificates reads the CERTIFICATE blocks of a PEM bundle, leaf
+// first. Other blocks, private keys in particular, are skipped.
+func parseCertificates(data []byte) ([]CertificateInfo, error) {
+	var certificates []CertificateInfo
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certificates, err
+		}
+		info := CertificateInfo{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			IsCA:      cert.IsCA,
+		}
+		for _, ip := range cert.IPAddresses {
+			info.IPAddresses = append(info.IPAddresses, ip.String())
+		}
+		certificates = append(certificates, info)
+	}
+	return certificates, nil
+}
+
+// dockerRegistries returns the registry names of a docker config, leaving
+// out the credentials. The legacy .dockercfg format has no "auths" wrapper.
+func dockerRegistries(data []byte, wrapped bool) ([]string, error) {
+	var auths map[string]json.RawMessage
+	if wrapped {
+		var config struct {
+			Auths map[string]json.RawMessage `json:"auths"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		auths = config.Auths
+	} else if err := json.Unmarshal(data, &auths); err != nil {
+		return nil, err
+	}
+	registries := make([]string, 0, len(auths))
+	for registry := range auths {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries, nil
+}
+
+func serviceAccountToken(secret *corev1.Secret) *ServiceAccountToken {
+	token := &ServiceAccountToken{
+		Name:  secret.Annotations[corev1.ServiceAccountNameKey],
+		UID:   secret.Annotations[corev1.ServiceAccountUIDKey],
+		HasCA: len(secret.Data[corev1.ServiceAccountRootCAKey]) > 0,
+	}
+	parts := strings.Split(string(secret.Data[corev1.ServiceAccountTokenKey]), ".")
+	if len(parts) != 3 {
+		return token
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return token
+	}
+	var claims struct {
+		Issuer  string `json:"iss"`
+		Expires int64  `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return token
+	}
+	token.Issuer = claims.Issuer
+	if claims.Expires > 0 {
+		expires := time.Unix(claims.Expires, 0).UTC()
+		token.Expires = &expires
+	}
+	return token
+}
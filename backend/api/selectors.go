@@ -0,0 +1,55 @@
+package api
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Selectors come in two shapes: plain maps such as Service.spec.selector and
+// metav1.LabelSelector with matchExpressions. Both are converted to
+// labels.Selector here so matching and rendering follow upstream semantics.
+
+// labelSelector converts a LabelSelector. As in the Kubernetes API, a nil
+// selector matches nothing and an empty one matches everything.
+func labelSelector(selector *v1.LabelSelector) labels.Selector {
+	if selector == nil {
+		return labels.Nothing()
+	}
+	parsed, err := v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return labels.Nothing()
+	}
+	return parsed
+}
+
+// mapSelector converts a map selector. An empty map selects nothing, the way
+// a Service without a selector gets no endpoints.
+func mapSelector(selector map[string]string) labels.Selector {
+	if len(selector) == 0 {
+		return labels.Nothing()
+	}
+	return labels.SelectorFromSet(selector)
+}
+
+// describeSelector renders a selector the way kubectl does, for example
+// "app=web,tier in (api,db),!legacy".
+func describeSelector(selector *v1.LabelSelector) string {
+	if selector == nil {
+		return "(none)"
+	}
+	parsed, err := v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "(invalid: " + err.Error() + ")"
+	}
+	if parsed.Empty() {
+		return "(all)"
+	}
+	return parsed.String()
+}
+
+func describeMapSelector(selector map[string]string) string {
+	if len(selector) == 0 {
+		return "(none)"
+	}
+	return labels.SelectorFromSet(selector).String()
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var selectorTestLabels = []labels.Set{
+	nil,
+	{},
+	{"app": "web"},
+	{"app": "api"},
+	{"app": "web", "tier": "frontend"},
+	{"app": "web", "tier": "backend", "legacy": "true"},
+	{"tier": "db"},
+	{"legacy": ""},
+}
+
+func TestLabelSelectorMatchesUpstream(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector *v1.LabelSelector
+	}{
+		{"nil", nil},
+		{"empty", &v1.LabelSelector{}},
+		{"matchLabels", &v1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+		{"matchLabels several", &v1.LabelSelector{MatchLabels: map[string]string{"app": "web", "tier": "frontend"}}},
+		{"In", &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+			{Key: "tier", Operator: v1.LabelSelectorOpIn, Values: []string{"frontend", "db"}},
+		}}},
+		{"NotIn", &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+			{Key: "tier", Operator: v1.LabelSelectorOpNotIn, Values: []string{"frontend"}},
+		}}},
+		{"Exists", &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+			{Key: "legacy", Operator: v1.LabelSelectorOpExists},
+		}}},
+		{"DoesNotExist", &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+			{Key: "legacy", Operator: v1.LabelSelectorOpDoesNotExist},
+		}}},
+		{"matchLabels and expressions", &v1.LabelSelector{
+			MatchLabels: map[string]string{"app": "web"},
+			MatchExpressions: []v1.LabelSelectorRequirement{
+				{Key: "tier", Operator: v1.LabelSelectorOpNotIn, Values: []string{"backend"}},
+				{Key: "legacy", Operator: v1.LabelSelectorOpDoesNotExist},
+			},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := v1.LabelSelectorAsSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("LabelSelectorAsSelector: %v", err)
+			}
+			got := labelSelector(tt.selector)
+			for _, set := range selectorTestLabels {
+				if got.Matches(set) != want.Matches(set) {
+					t.Errorf("labels %v: got %v, upstream %v", set, got.Matches(set), want.Matches(set))
+				}
+			}
+		})
+	}
+}
+
+func TestLabelSelectorInvalidMatchesNothing(t *testing.T) {
+	selector := labelSelector(&v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+		{Key: "tier", Operator: v1.LabelSelectorOpIn},
+	}})
+	for _, set := range selectorTestLabels {
+		if selector.Matches(set) {
+			t.Errorf("labels %v: invalid selector matched", set)
+		}
+	}
+}
+
+func TestMapSelectorMatchesUpstream(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector map[string]string
+	}{
+		{"one label", map[string]string{"app": "web"}},
+		{"two labels", map[string]string{"app": "web", "tier": "frontend"}},
+		{"empty value", map[string]string{"legacy": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := labels.SelectorFromSet(tt.selector)
+			got := mapSelector(tt.selector)
+			for _, set := range selectorTestLabels {
+				if got.Matches(set) != want.Matches(set) {
+					t.Errorf("labels %v: got %v, upstream %v", set, got.Matches(set), want.Matches(set))
+				}
+			}
+		})
+	}
+}
+
+// Unlike labels.SelectorFromSet, an empty map selects nothing, the way a
+// Service without a selector gets no endpoints.
+func TestMapSelectorEmptyMatchesNothing(t *testing.T) {
+	for _, selector := range []map[string]string{nil, {}} {
+		for _, set := range selectorTestLabels {
+			if mapSelector(selector).Matches(set) {
+				t.Errorf("selector %v matched labels %v", selector, set)
+			}
+		}
+	}
+}
+
+func TestDescribeSelector(t *testing.T) {
+	tests := []struct {
+		selector *v1.LabelSelector
+		want     string
+	}{
+		{nil, "(none)"},
+		{&v1.LabelSelector{}, "(all)"},
+		{&v1.LabelSelector{
+			MatchLabels: map[string]string{"app": "web"},
+			MatchExpressions: []v1.LabelSelectorRequirement{
+				{Key: "tier", Operator: v1.LabelSelectorOpIn, Values: []string{"db", "api"}},
+				{Key: "legacy", Operator: v1.LabelSelectorOpDoesNotExist},
+			},
+		}, "app=web,!legacy,tier in (api,db)"},
+	}
+
+	for _, tt := range tests {
+		if got := describeSelector(tt.selector); got != tt.want {
+			t.Errorf("describeSelector(%v) = %q, want %q", tt.selector, got, tt.want)
+		}
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type ServicePort struct {
@@ -31,12 +31,12 @@ type ServiceList struct {
 	Continue string        `json:"continue"`
 }
 
-func ServicesHandler(client kubernetes.Interface) http.HandlerFunc {
+func ServicesHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		opts, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -47,26 +47,30 @@ func ServicesHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		list, err := client.CoreV1().Services(namespace).List(ctx, opts)
+		lister, err := namespaced(ctx, cache, namespace, (*kube.Cache).Services)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		items := make([]ServiceItem, 0, len(list.Items))
-		for _, svc := range list.Items {
-			items = append(items, ServiceItem{
-				Name:       svc.Name,
-				Namespace:  svc.Namespace,
-				Type:       string(svc.Spec.Type),
-				ClusterIP:  svc.Spec.ClusterIP,
-				ExternalIP: serviceExternalIPs(&svc),
-				Ports:      mapServicePorts(svc.Spec.Ports),
-				CreatedAt:  svc.CreationTimestamp.Time,
-			})
+		services, err := lister.Services(namespace).List(selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		page, next, err := paginate(services, opts)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		items := make([]ServiceItem, 0, len(page))
+		for _, svc := range page {
+			items = append(items, mapService(svc))
 		}
 
-		respondJSON(w, http.StatusOK, ServiceList{Items: items, Continue: list.Continue})
+		respondJSON(w, http.StatusOK, ServiceList{Items: items, Continue: next})
 	}
 }
 
@@ -105,3 +109,15 @@ func serviceExternalIPs(svc *corev1.Service) []string {
 	}
 	return nil
 }
+
+func mapService(svc *corev1.Service) ServiceItem {
+	return ServiceItem{
+		Name:       svc.Name,
+		Namespace:  svc.Namespace,
+		Type:       string(svc.Spec.Type),
+		ClusterIP:  svc.Spec.ClusterIP,
+		ExternalIP: serviceExternalIPs(svc),
+		Ports:      mapServicePorts(svc.Spec.Ports),
+		CreatedAt:  svc.CreationTimestamp.Time,
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type StorageClassItem struct {
@@ -52,12 +52,12 @@ type StorageOverview struct {
 	CSIDrivers     []CSIDriverItem             `json:"csiDrivers"`
 }
 
-func StorageHandler(client kubernetes.Interface) http.HandlerFunc {
+func StorageHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		_, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -68,35 +68,35 @@ func StorageHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		scList, err := client.StorageV1().StorageClasses().List(ctx, opts)
+		scList, err := listStorageClasses(ctx, cache, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		pvList, err := client.CoreV1().PersistentVolumes().List(ctx, opts)
+		pvList, err := listPVs(ctx, cache, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		pvcList, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		pvcList, err := listPVCs(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		csiList, err := client.StorageV1().CSIDrivers().List(ctx, opts)
+		csiList, err := listCSIDrivers(ctx, cache, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
 		response := StorageOverview{
-			StorageClasses: mapStorageClasses(scList.Items),
-			Volumes:        mapPVs(pvList.Items),
-			Claims:         mapPVCs(pvcList.Items),
-			CSIDrivers:     mapCSIDrivers(csiList.Items),
+			StorageClasses: mapStorageClasses(scList),
+			Volumes:        mapPVs(pvList),
+			Claims:         mapPVCs(pvcList),
+			CSIDrivers:     mapCSIDrivers(csiList),
 		}
 
 		respondJSON(w, http.StatusOK, response)
@@ -1,15 +1,20 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type TopologyNode struct {
@@ -19,78 +24,216 @@ type TopologyNode struct {
 	Namespace string            `json:"namespace,omitempty"`
 	Status    string            `json:"status,omitempty"`
 	Labels    map[string]string `json:"labels,omitempty"`
+	Parent    string            `json:"parent,omitempty"`
+	Dangling  bool              `json:"dangling,omitempty"`
 }
 
 type TopologyEdge struct {
-	ID   string `json:"id"`
-	From string `json:"from"`
-	To   string `json:"to"`
-	Kind string `json:"kind"`
+	ID       string `json:"id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Kind     string `json:"kind"`
+	Dangling bool   `json:"dangling,omitempty"`
 }
 
 type TopologyResponse struct {
-	Nodes []TopologyNode `json:"nodes"`
-	Edges []TopologyEdge `json:"edges"`
+	Revision string         `json:"revision"`
+	GroupBy  string         `json:"groupBy,omitempty"`
+	Nodes    []TopologyNode `json:"nodes"`
+	Edges    []TopologyEdge `json:"edges"`
+	// Unavailable names the optional resources left out because the
+	// credentials may not list them.
+	Unavailable []string `json:"unavailable,omitempty"`
 }
 
-func TopologyHandler(client kubernetes.Interface) http.HandlerFunc {
+type topologyQuery struct {
+	namespace           string
+	selector            labels.Selector
+	collapseReplicaSets bool
+	groupBy             string
+	dependencies        bool
+}
+
+type topologySources struct {
+	nodes        []corev1.Node
+	pods         []corev1.Pod
+	services     []corev1.Service
+	ingresses    []networkingv1.Ingress
+	slices       []discoveryv1.EndpointSlice
+	deployments  []appsv1.Deployment
+	replicaSets  []appsv1.ReplicaSet
+	statefulSets []appsv1.StatefulSet
+	daemonSets   []appsv1.DaemonSet
+	jobs         []batchv1.Job
+	cronJobs     []batchv1.CronJob
+	gatewayAPI   gatewaySources
+
+	configMaps      []v1.PartialObjectMetadata
+	secrets         []v1.PartialObjectMetadata
+	pvcs            []corev1.PersistentVolumeClaim
+	pvs             []corev1.PersistentVolume
+	serviceAccounts []corev1.ServiceAccount
+	// unavailable names the optional resources the credentials may not list.
+	unavailable []string
+}
+
+func TopologyHandler(cache *kube.Cache, history *TopologyHistory) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		query, err := parseTopologyQuery(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		namespace := r.URL.Query().Get("ns")
-		if namespace == "" {
-			namespace = v1.NamespaceAll
-		}
-
-		nodes, err := client.CoreV1().Nodes().List(ctx, opts)
+		response, err := collectTopology(ctx, cache, query)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
-		}
+		response.Revision = history.Record(response)
+		respondJSON(w, http.StatusOK, response)
+	}
+}
 
-		services, err := client.CoreV1().Services(namespace).List(ctx, opts)
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
-		}
+func parseTopologyQuery(r *http.Request) (topologyQuery, error) {
+	_, selector, err := parseListSelector(r)
+	if err != nil {
+		return topologyQuery{}, err
+	}
+
+	query := topologyQuery{
+		namespace: r.URL.Query().Get("ns"),
+		selector:  selector,
+	}
+	if query.namespace == "" {
+		query.namespace = v1.NamespaceAll
+	}
 
-		ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+	if value := r.URL.Query().Get("collapseReplicaSets"); value != "" {
+		collapse, err := strconv.ParseBool(value)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
+			return topologyQuery{}, fmt.Errorf("invalid collapseReplicaSets")
 		}
+		query.collapseReplicaSets = collapse
+	}
 
-		slices, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, opts)
+	if value := r.URL.Query().Get("dependencies"); value != "" {
+		dependencies, err := strconv.ParseBool(value)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
+			return topologyQuery{}, fmt.Errorf("invalid dependencies")
 		}
+		query.dependencies = dependencies
+	}
 
-		response := buildTopology(nodes.Items, pods.Items, services.Items, ingresses.Items, slices.Items)
-		respondJSON(w, http.StatusOK, response)
+	switch groupBy := r.URL.Query().Get("groupBy"); groupBy {
+	case "", "node", "namespace", "zone":
+		query.groupBy = groupBy
+	default:
+		return topologyQuery{}, fmt.Errorf("invalid groupBy: must be node, namespace or zone")
+	}
+
+	return query, nil
+}
+
+func collectTopology(ctx context.Context, cache *kube.Cache, query topologyQuery) (TopologyResponse, error) {
+	namespace := query.namespace
+	selector := query.selector
+
+	nodes, err := listNodes(ctx, cache, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	pods, err := listPods(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	services, err := listServices(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	ingresses, err := listIngresses(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	slices, err := listEndpointSlices(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	deployments, err := listDeployments(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	replicaSets, err := listReplicaSets(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	statefulSets, err := listStatefulSets(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	daemonSets, err := listDaemonSets(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	jobs, err := listJobs(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	cronJobs, err := listCronJobs(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
+	}
+
+	gatewayAPI, err := listGatewayAPI(ctx, cache, namespace, selector)
+	if err != nil {
+		return TopologyResponse{}, err
 	}
+
+	sources := topologySources{
+		nodes:        nodes,
+		pods:         pods,
+		services:     services,
+		ingresses:    ingresses,
+		slices:       slices,
+		deployments:  deployments,
+		replicaSets:  replicaSets,
+		statefulSets: statefulSets,
+		daemonSets:   daemonSets,
+		jobs:         jobs,
+		cronJobs:     cronJobs,
+		gatewayAPI:   gatewayAPI,
+	}
+
+	if query.dependencies {
+		if err := collectDependencies(ctx, cache, namespace, &sources); err != nil {
+			return TopologyResponse{}, err
+		}
+	}
+
+	return buildTopology(sources, query), nil
 }
 
-func buildTopology(
-	nodes []corev1.Node,
-	pods []corev1.Pod,
-	services []corev1.Service,
-	ingresses []networkingv1.Ingress,
-	slices []discoveryv1.EndpointSlice,
-) TopologyResponse {
+func buildTopology(sources topologySources, query topologyQuery) TopologyResponse {
+	nodes := sources.nodes
+	pods := sources.pods
+	services := sources.services
+	ingresses := sources.ingresses
+	slices := sources.slices
+
 	result := TopologyResponse{}
 
 	podByIP := map[string]string{}
@@ -208,16 +351,48 @@ func buildTopology(
 		}
 	}
 
+	addOwnership(&result, sources, query.collapseReplicaSets)
+	addScheduling(&result, sources)
+	addGatewayAPI(&result, sources.gatewayAPI)
+	if query.dependencies {
+		addDependencies(&result, sources, query.selector)
+	}
+	applyGrouping(&result, sources, query.groupBy)
+
 	sort.Slice(result.Nodes, func(i, j int) bool {
 		if result.Nodes[i].Kind == result.Nodes[j].Kind {
+			if result.Nodes[i].Name == result.Nodes[j].Name {
+				return result.Nodes[i].ID < result.Nodes[j].ID
+			}
 			return result.Nodes[i].Name < result.Nodes[j].Name
 		}
 		return result.Nodes[i].Kind < result.Nodes[j].Kind
 	})
+	result.Edges = dedupeEdges(result.Edges)
+	result.Unavailable = append(append([]string{}, sources.gatewayAPI.unavailable...), sources.unavailable...)
+	if len(result.Unavailable) == 0 {
+		result.Unavailable = nil
+	}
 
 	return result
 }
 
+func dedupeEdges(edges []TopologyEdge) []TopologyEdge {
+	seen := map[string]bool{}
+	out := make([]TopologyEdge, 0, len(edges))
+	for _, edge := range edges {
+		if seen[edge.ID] {
+			continue
+		}
+		seen[edge.ID] = true
+		out = append(out, edge)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
 func nodeID(name string) string {
 	return "node:" + name
 }
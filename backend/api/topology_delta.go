@@ -0,0 +1,168 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+type TopologyDelta struct {
+	Revision     string         `json:"revision"`
+	BaseRevision string         `json:"baseRevision"`
+	Full         bool           `json:"full"`
+	AddedNodes   []TopologyNode `json:"addedNodes"`
+	ChangedNodes []TopologyNode `json:"changedNodes"`
+	RemovedNodes []string       `json:"removedNodes"`
+	AddedEdges   []TopologyEdge `json:"addedEdges"`
+	ChangedEdges []TopologyEdge `json:"changedEdges"`
+	RemovedEdges []string       `json:"removedEdges"`
+	Unavailable  []string       `json:"unavailable,omitempty"`
+}
+
+// TopologyHistory remembers recently served topologies by revision so that
+// clients can ask for the difference against the graph they already have.
+// Revisions are content hashes, so identical graphs share a revision.
+type TopologyHistory struct {
+	mu        sync.Mutex
+	size      int
+	order     []string
+	snapshots map[string]TopologyResponse
+}
+
+func NewTopologyHistory(size int) *TopologyHistory {
+	return &TopologyHistory{size: size, snapshots: map[string]TopologyResponse{}}
+}
+
+func (h *TopologyHistory) Record(topology TopologyResponse) string {
+	revision := topologyRevision(topology)
+	topology.Revision = revision
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.snapshots[revision]; ok {
+		for i, existing := range h.order {
+			if existing == revision {
+				h.order = append(h.order[:i], h.order[i+1:]...)
+				break
+			}
+		}
+	} else if len(h.order) >= h.size {
+		oldest := h.order[0]
+		h.order = h.order[1:]
+		delete(h.snapshots, oldest)
+	}
+	h.order = append(h.order, revision)
+	h.snapshots[revision] = topology
+	return revision
+}
+
+func (h *TopologyHistory) Get(revision string) (TopologyResponse, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	topology, ok := h.snapshots[revision]
+	return topology, ok
+}
+
+func TopologyDeltaHandler(cache *kube.Cache, history *TopologyHistory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		query, err := parseTopologyQuery(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		current, err := collectTopology(ctx, cache, query)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		current.Revision = history.Record(current)
+
+		since := r.URL.Query().Get("since")
+		previous, ok := history.Get(since)
+		if since == "" || !ok {
+			// Unknown base: send everything as added and let the client
+			// replace its graph.
+			delta := diffTopology(TopologyResponse{Revision: since}, current)
+			delta.Full = true
+			respondJSON(w, http.StatusOK, delta)
+			return
+		}
+
+		respondJSON(w, http.StatusOK, diffTopology(previous, current))
+	}
+}
+
+func diffTopology(previous, current TopologyResponse) TopologyDelta {
+	delta := TopologyDelta{
+		Revision:     current.Revision,
+		BaseRevision: previous.Revision,
+		AddedNodes:   []TopologyNode{},
+		ChangedNodes: []TopologyNode{},
+		RemovedNodes: []string{},
+		AddedEdges:   []TopologyEdge{},
+		ChangedEdges: []TopologyEdge{},
+		RemovedEdges: []string{},
+		Unavailable:  current.Unavailable,
+	}
+
+	previousNodes := map[string]TopologyNode{}
+	for _, node := range previous.Nodes {
+		previousNodes[node.ID] = node
+	}
+	for _, node := range current.Nodes {
+		old, ok := previousNodes[node.ID]
+		switch {
+		case !ok:
+			delta.AddedNodes = append(delta.AddedNodes, node)
+		case !reflect.DeepEqual(old, node):
+			delta.ChangedNodes = append(delta.ChangedNodes, node)
+		}
+		delete(previousNodes, node.ID)
+	}
+	for id := range previousNodes {
+		delta.RemovedNodes = append(delta.RemovedNodes, id)
+	}
+
+	previousEdges := map[string]TopologyEdge{}
+	for _, edge := range previous.Edges {
+		previousEdges[edge.ID] = edge
+	}
+	for _, edge := range current.Edges {
+		old, ok := previousEdges[edge.ID]
+		switch {
+		case !ok:
+			delta.AddedEdges = append(delta.AddedEdges, edge)
+		case !reflect.DeepEqual(old, edge):
+			delta.ChangedEdges = append(delta.ChangedEdges, edge)
+		}
+		delete(previousEdges, edge.ID)
+	}
+	for id := range previousEdges {
+		delta.RemovedEdges = append(delta.RemovedEdges, id)
+	}
+
+	sort.Strings(delta.RemovedNodes)
+	sort.Strings(delta.RemovedEdges)
+	return delta
+}
+
+func topologyRevision(topology TopologyResponse) string {
+	topology.Revision = ""
+	data, err := json.Marshal(topology)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
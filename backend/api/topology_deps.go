@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type dependencyRef struct {
+	kind     string
+	name     string
+	edge     string
+	optional bool
+}
+
+// collectDependencies lists config and storage objects without the label
+// selector: a reference only counts as dangling if the object is really gone.
+// Secrets come from the metadata informer, so values are never read.
+// PersistentVolumes are cluster-scoped; when they may not be listed, PVCs
+// are shown without their volumes.
+func collectDependencies(ctx context.Context, cache *kube.Cache, namespace string, sources *topologySources) error {
+	configMaps, err := listConfigMapMetadata(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	secrets, err := listSecretMetadata(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	pvcs, err := listPVCs(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	pvs, err := listPVs(ctx, cache, labels.Everything())
+	if apierrors.IsForbidden(err) {
+		sources.unavailable = append(sources.unavailable, "persistentvolumes")
+	} else if err != nil {
+		return err
+	}
+
+	serviceAccounts, err := listServiceAccounts(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	sources.configMaps = configMaps
+	sources.secrets = secrets
+	sources.pvcs = pvcs
+	sources.pvs = pvs
+	sources.serviceAccounts = serviceAccounts
+	return nil
+}
+
+// addDependencies draws ConfigMaps, Secrets, PVCs, PVs and ServiceAccounts.
+// Objects matching the selector are always shown; others only when a pod
+// references them. References to missing objects get a dangling node, except
+// optional ones, which the kubelet tolerates.
+func addDependencies(result *TopologyResponse, sources topologySources, selector labels.Selector) {
+	known := map[string]bool{}
+	for _, node := range result.Nodes {
+		known[node.ID] = true
+	}
+	add := func(node TopologyNode) {
+		if known[node.ID] {
+			return
+		}
+		known[node.ID] = true
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	existing := map[string]TopologyNode{}
+	for _, cm := range sources.configMaps {
+		node := dependencyNode("ConfigMap", cm.Namespace, cm.Name, "", cm.Labels)
+		existing[node.ID] = node
+	}
+	for _, secret := range sources.secrets {
+		node := dependencyNode("Secret", secret.Namespace, secret.Name, "", secret.Labels)
+		existing[node.ID] = node
+	}
+	for _, pvc := range sources.pvcs {
+		node := dependencyNode("PersistentVolumeClaim", pvc.Namespace, pvc.Name, string(pvc.Status.Phase), pvc.Labels)
+		existing[node.ID] = node
+	}
+	for _, pv := range sources.pvs {
+		node := dependencyNode("PersistentVolume", "", pv.Name, string(pv.Status.Phase), pv.Labels)
+		existing[node.ID] = node
+	}
+	for _, sa := range sources.serviceAccounts {
+		node := dependencyNode("ServiceAccount", sa.Namespace, sa.Name, "", sa.Labels)
+		existing[node.ID] = node
+	}
+
+	for _, node := range existing {
+		if node.Kind != "PersistentVolume" && selector.Matches(labels.Set(node.Labels)) {
+			add(node)
+		}
+	}
+
+	link := func(from, kind, namespace, name, edgeKind string, optional bool) {
+		target := dependencyNode(kind, namespace, name, "", nil)
+		node, ok := existing[target.ID]
+		if !ok && optional {
+			return
+		}
+		if !ok {
+			target.Status = "Missing"
+			target.Dangling = true
+			node = target
+		}
+		add(node)
+		result.Edges = append(result.Edges, TopologyEdge{
+			ID:       from + "->" + target.ID + ":" + edgeKind,
+			From:     from,
+			To:       target.ID,
+			Kind:     edgeKind,
+			Dangling: !ok,
+		})
+	}
+
+	for _, pod := range sources.pods {
+		from := podID(pod.Namespace, pod.Name)
+		for _, ref := range podDependencies(pod) {
+			link(from, ref.kind, pod.Namespace, ref.name, ref.edge, ref.optional)
+		}
+	}
+
+	// Bound volumes are resolved after pods so PVCs pulled in by a pod
+	// reference are included. Unlisted volumes are unknown, not missing.
+	if containsString(sources.unavailable, "persistentvolumes") {
+		return
+	}
+	for _, pvc := range sources.pvcs {
+		from := dependencyID("PersistentVolumeClaim", pvc.Namespace, pvc.Name)
+		if !known[from] || pvc.Spec.VolumeName == "" {
+			continue
+		}
+		link(from, "PersistentVolume", "", pvc.Spec.VolumeName, "PVCBoundToPV", false)
+	}
+}
+
+func podDependencies(pod corev1.Pod) []dependencyRef {
+	refs := []dependencyRef{}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	refs = append(refs, dependencyRef{kind: "ServiceAccount", name: serviceAccount, edge: "PodUsesServiceAccount"})
+
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		if secret.Name != "" {
+			refs = append(refs, dependencyRef{kind: "Secret", name: secret.Name, edge: "PodImagePullSecret"})
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			refs = append(refs, dependencyRef{kind: "ConfigMap", name: volume.ConfigMap.Name, edge: "PodMountsConfigMap", optional: isOptional(volume.ConfigMap.Optional)})
+		case volume.Secret != nil:
+			refs = append(refs, dependencyRef{kind: "Secret", name: volume.Secret.SecretName, edge: "PodMountsSecret", optional: isOptional(volume.Secret.Optional)})
+		case volume.PersistentVolumeClaim != nil:
+			refs = append(refs, dependencyRef{kind: "PersistentVolumeClaim", name: volume.PersistentVolumeClaim.ClaimName, edge: "PodMountsPVC"})
+		case volume.Ephemeral != nil:
+			// Generic ephemeral volumes are backed by a PVC named after the
+			// pod and the volume.
+			refs = append(refs, dependencyRef{kind: "PersistentVolumeClaim", name: pod.Name + "-" + volume.Name, edge: "PodMountsPVC"})
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					refs = append(refs, dependencyRef{kind: "ConfigMap", name: source.ConfigMap.Name, edge: "PodMountsConfigMap", optional: isOptional(source.ConfigMap.Optional)})
+				}
+				if source.Secret != nil {
+					refs = append(refs, dependencyRef{kind: "Secret", name: source.Secret.Name, edge: "PodMountsSecret", optional: isOptional(source.Secret.Optional)})
+				}
+			}
+		}
+	}
+
+	containers := append([]corev1.Container{}, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, corev1.Container(container.EphemeralContainerCommon))
+	}
+	for _, container := range containers {
+		for _, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil {
+				refs = append(refs, dependencyRef{kind: "ConfigMap", name: source.ConfigMapRef.Name, edge: "PodEnvFromConfigMap", optional: isOptional(source.ConfigMapRef.Optional)})
+			}
+			if source.SecretRef != nil {
+				refs = append(refs, dependencyRef{kind: "Secret", name: source.SecretRef.Name, edge: "PodEnvFromSecret", optional: isOptional(source.SecretRef.Optional)})
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				refs = append(refs, dependencyRef{kind: "ConfigMap", name: ref.Name, edge: "PodEnvFromConfigMap", optional: isOptional(ref.Optional)})
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				refs = append(refs, dependencyRef{kind: "Secret", name: ref.Name, edge: "PodEnvFromSecret", optional: isOptional(ref.Optional)})
+			}
+		}
+	}
+
+	return refs
+}
+
+func dependencyNode(kind, namespace, name, status string, labels map[string]string) TopologyNode {
+	return TopologyNode{
+		ID:        dependencyID(kind, namespace, name),
+		Kind:      kind,
+		Name:      name,
+		Namespace: namespace,
+		Status:    status,
+		Labels:    labels,
+	}
+}
+
+func dependencyID(kind, namespace, name string) string {
+	switch kind {
+	case "PersistentVolumeClaim":
+		return "pvc:" + namespace + "/" + name
+	case "PersistentVolume":
+		return "pv:" + name
+	}
+	return workloadID(kind, namespace, name)
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
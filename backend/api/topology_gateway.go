@@ -0,0 +1,82 @@
+package api
+
+import "strings"
+
+func addGatewayAPI(result *TopologyResponse, sources gatewaySources) {
+	known := map[string]bool{}
+	for _, node := range result.Nodes {
+		known[node.ID] = true
+	}
+	add := func(node TopologyNode) {
+		known[node.ID] = true
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	for _, class := range sources.classes {
+		add(TopologyNode{
+			ID:     gatewayClassID(class.Name),
+			Kind:   "GatewayClass",
+			Name:   class.Name,
+			Status: class.Spec.ControllerName,
+			Labels: class.Labels,
+		})
+	}
+
+	for _, gw := range sources.gateways {
+		add(TopologyNode{
+			ID:        workloadID("Gateway", gw.Namespace, gw.Name),
+			Kind:      "Gateway",
+			Name:      gw.Name,
+			Namespace: gw.Namespace,
+			Status:    gw.Spec.GatewayClassName,
+			Labels:    gw.Labels,
+		})
+	}
+
+	for _, route := range sources.routes {
+		add(TopologyNode{
+			ID:        workloadID(route.Kind, route.Namespace, route.Name),
+			Kind:      route.Kind,
+			Name:      route.Name,
+			Namespace: route.Namespace,
+			Status:    strings.Join(route.Spec.Hostnames, ","),
+			Labels:    route.Labels,
+		})
+	}
+
+	link := func(from, to, kind string) {
+		if !known[from] || !known[to] {
+			return
+		}
+		result.Edges = append(result.Edges, TopologyEdge{
+			ID:   from + "->" + to,
+			From: from,
+			To:   to,
+			Kind: kind,
+		})
+	}
+
+	for _, gw := range sources.gateways {
+		link(gatewayClassID(gw.Spec.GatewayClassName), workloadID("Gateway", gw.Namespace, gw.Name), "GatewayClassToGateway")
+	}
+
+	for _, route := range sources.routes {
+		id := workloadID(route.Kind, route.Namespace, route.Name)
+		for _, parent := range route.Spec.ParentRefs {
+			if isGatewayRef(parent) {
+				link(workloadID("Gateway", refNamespace(parent, route.Namespace), parent.Name), id, "GatewayToRoute")
+			}
+		}
+		for _, rule := range route.Spec.Rules {
+			for _, backend := range rule.BackendRefs {
+				if isServiceRef(backend) {
+					link(id, serviceID(refNamespace(backend, route.Namespace), backend.Name), "RouteToService")
+				}
+			}
+		}
+	}
+}
+
+func gatewayClassID(name string) string {
+	return "gatewayclass:" + name
+}
@@ -0,0 +1,90 @@
+package api
+
+const zoneLabel = "topology.kubernetes.io/zone"
+
+func addScheduling(result *TopologyResponse, sources topologySources) {
+	nodes := map[string]bool{}
+	for _, node := range sources.nodes {
+		nodes[node.Name] = true
+	}
+	for _, pod := range sources.pods {
+		if pod.Spec.NodeName == "" || !nodes[pod.Spec.NodeName] {
+			continue
+		}
+		from := podID(pod.Namespace, pod.Name)
+		to := nodeID(pod.Spec.NodeName)
+		result.Edges = append(result.Edges, TopologyEdge{
+			ID:   from + "->" + to,
+			From: from,
+			To:   to,
+			Kind: "PodOnNode",
+		})
+	}
+}
+
+// applyGrouping sets Parent on nodes so the UI can draw compound nodes.
+// Grouping by zone nests pods in their node and nodes in their zone.
+func applyGrouping(result *TopologyResponse, sources topologySources, groupBy string) {
+	result.GroupBy = groupBy
+
+	switch groupBy {
+	case "node", "zone":
+		podNode := map[string]string{}
+		for _, pod := range sources.pods {
+			podNode[podID(pod.Namespace, pod.Name)] = pod.Spec.NodeName
+		}
+		nodeZone := map[string]string{}
+		for _, node := range sources.nodes {
+			nodeZone[nodeID(node.Name)] = node.Labels[zoneLabel]
+		}
+		zones := map[string]bool{}
+		for i := range result.Nodes {
+			node := &result.Nodes[i]
+			switch node.Kind {
+			case "Pod":
+				if name := podNode[node.ID]; name != "" {
+					if _, ok := nodeZone[nodeID(name)]; ok {
+						node.Parent = nodeID(name)
+					}
+				}
+			case "Node":
+				if zone := nodeZone[node.ID]; groupBy == "zone" && zone != "" {
+					node.Parent = zoneID(zone)
+					zones[zone] = true
+				}
+			}
+		}
+		for zone := range zones {
+			result.Nodes = append(result.Nodes, TopologyNode{
+				ID:   zoneID(zone),
+				Kind: "Zone",
+				Name: zone,
+			})
+		}
+	case "namespace":
+		namespaces := map[string]bool{}
+		for i := range result.Nodes {
+			node := &result.Nodes[i]
+			if node.Namespace == "" {
+				continue
+			}
+			node.Parent = namespaceID(node.Namespace)
+			namespaces[node.Namespace] = true
+		}
+		for namespace := range namespaces {
+			result.Nodes = append(result.Nodes, TopologyNode{
+				ID:   namespaceID(namespace),
+				Kind: "Namespace",
+				Name: namespace,
+			})
+		}
+	}
+}
+
+func zoneID(zone string) string {
+	return "zone:" + zone
+}
+
+func namespaceID(name string) string {
+	return "namespace:" + name
+}
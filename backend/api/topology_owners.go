@@ -0,0 +1,189 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func addOwnership(result *TopologyResponse, sources topologySources, collapseReplicaSets bool) {
+	known := map[string]bool{}
+	for _, node := range result.Nodes {
+		known[node.ID] = true
+	}
+	add := func(node TopologyNode) {
+		known[node.ID] = true
+		result.Nodes = append(result.Nodes, node)
+	}
+
+	for _, deployment := range sources.deployments {
+		add(TopologyNode{
+			ID:        workloadID("Deployment", deployment.Namespace, deployment.Name),
+			Kind:      "Deployment",
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+			Status:    replicaStatus(deployment.Status.ReadyReplicas, deployment.Spec.Replicas),
+			Labels:    deployment.Labels,
+		})
+	}
+
+	// With collapsing enabled, a ReplicaSet owned by a known Deployment is
+	// not drawn and its pods are attached to the Deployment directly.
+	collapsed := map[string]string{}
+	for _, rs := range sources.replicaSets {
+		id := workloadID("ReplicaSet", rs.Namespace, rs.Name)
+		if collapseReplicaSets {
+			if owner := controllerID(rs.Namespace, rs.OwnerReferences); owner != "" && known[owner] {
+				collapsed[id] = owner
+				continue
+			}
+		}
+		add(TopologyNode{
+			ID:        id,
+			Kind:      "ReplicaSet",
+			Name:      rs.Name,
+			Namespace: rs.Namespace,
+			Status:    replicaStatus(rs.Status.ReadyReplicas, rs.Spec.Replicas),
+			Labels:    rs.Labels,
+		})
+	}
+
+	for _, sts := range sources.statefulSets {
+		add(TopologyNode{
+			ID:        workloadID("StatefulSet", sts.Namespace, sts.Name),
+			Kind:      "StatefulSet",
+			Name:      sts.Name,
+			Namespace: sts.Namespace,
+			Status:    replicaStatus(sts.Status.ReadyReplicas, sts.Spec.Replicas),
+			Labels:    sts.Labels,
+		})
+	}
+
+	for _, ds := range sources.daemonSets {
+		desired := ds.Status.DesiredNumberScheduled
+		add(TopologyNode{
+			ID:        workloadID("DaemonSet", ds.Namespace, ds.Name),
+			Kind:      "DaemonSet",
+			Name:      ds.Name,
+			Namespace: ds.Namespace,
+			Status:    replicaStatus(ds.Status.NumberReady, &desired),
+			Labels:    ds.Labels,
+		})
+	}
+
+	for _, job := range sources.jobs {
+		add(TopologyNode{
+			ID:        workloadID("Job", job.Namespace, job.Name),
+			Kind:      "Job",
+			Name:      job.Name,
+			Namespace: job.Namespace,
+			Status:    jobStatus(job),
+			Labels:    job.Labels,
+		})
+	}
+
+	for _, cronJob := range sources.cronJobs {
+		add(TopologyNode{
+			ID:        workloadID("CronJob", cronJob.Namespace, cronJob.Name),
+			Kind:      "CronJob",
+			Name:      cronJob.Name,
+			Namespace: cronJob.Namespace,
+			Status:    cronJobStatus(cronJob),
+			Labels:    cronJob.Labels,
+		})
+	}
+
+	link := func(from, to, kind string) {
+		if !known[from] || !known[to] {
+			return
+		}
+		result.Edges = append(result.Edges, TopologyEdge{
+			ID:   from + "->" + to,
+			From: from,
+			To:   to,
+			Kind: kind,
+		})
+	}
+
+	for _, rs := range sources.replicaSets {
+		id := workloadID("ReplicaSet", rs.Namespace, rs.Name)
+		if _, ok := collapsed[id]; ok {
+			continue
+		}
+		if owner := controllerID(rs.Namespace, rs.OwnerReferences); strings.HasPrefix(owner, "deployment:") {
+			link(owner, id, "DeploymentToReplicaSet")
+		}
+	}
+
+	for _, job := range sources.jobs {
+		if owner := controllerID(job.Namespace, job.OwnerReferences); strings.HasPrefix(owner, "cronjob:") {
+			link(owner, workloadID("Job", job.Namespace, job.Name), "CronJobToJob")
+		}
+	}
+
+	for _, pod := range sources.pods {
+		owner := controllerID(pod.Namespace, pod.OwnerReferences)
+		if owner == "" {
+			continue
+		}
+		if deployment, ok := collapsed[owner]; ok {
+			owner = deployment
+		}
+		link(owner, podID(pod.Namespace, pod.Name), "OwnerToPod")
+	}
+}
+
+func workloadID(kind, namespace, name string) string {
+	return strings.ToLower(kind) + ":" + namespace + "/" + name
+}
+
+func controllerID(namespace string, refs []v1.OwnerReference) string {
+	for _, ref := range refs {
+		if ref.Controller == nil || !*ref.Controller {
+			continue
+		}
+		switch ref.Kind {
+		case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "Job", "CronJob":
+			return workloadID(ref.Kind, namespace, ref.Name)
+		}
+	}
+	return ""
+}
+
+func replicaStatus(ready int32, desired *int32) string {
+	want := int32(1)
+	if desired != nil {
+		want = *desired
+	}
+	return "Ready " + strconv.Itoa(int(ready)) + "/" + strconv.Itoa(int(want))
+}
+
+func jobStatus(job batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return "Complete"
+		case batchv1.JobFailed:
+			return "Failed"
+		case batchv1.JobSuspended:
+			return "Suspended"
+		}
+	}
+	if job.Status.Active > 0 {
+		return "Running"
+	}
+	return "Pending"
+}
+
+func cronJobStatus(cronJob batchv1.CronJob) string {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return "Suspended"
+	}
+	return cronJob.Spec.Schedule
+}
@@ -3,10 +3,11 @@ package api
 import (
 	"net/http"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type ServiceIntent struct {
@@ -37,15 +38,19 @@ type NetworkPolicySummary struct {
 type TrafficResponse struct {
 	ServiceIntents  []ServiceIntent        `json:"serviceIntents"`
 	IngressIntents  []IngressIntent        `json:"ingressIntents"`
+	RouteIntents    []RouteIntent          `json:"routeIntents"`
 	NetworkPolicies []NetworkPolicySummary `json:"networkPolicies"`
+	// Unavailable names the optional resources left out because the
+	// credentials may not list them.
+	Unavailable []string `json:"unavailable,omitempty"`
 }
 
-func TrafficHandler(client kubernetes.Interface) http.HandlerFunc {
+func TrafficHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		_, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -56,34 +61,42 @@ func TrafficHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		services, err := client.CoreV1().Services(namespace).List(ctx, opts)
+		services, err := listServices(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		pods, err := client.CoreV1().Pods(namespace).List(ctx, opts)
+		pods, err := listPods(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+		ingresses, err := listIngresses(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		policies, err := client.NetworkingV1().NetworkPolicies(namespace).List(ctx, opts)
+		policies, err := listNetworkPolicies(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
+			return
+		}
+
+		gatewayAPI, err := listGatewayAPI(ctx, cache, namespace, selector)
+		if err != nil {
+			respondClusterError(w, err)
 			return
 		}
 
 		response := TrafficResponse{
-			ServiceIntents:  mapServiceIntents(services.Items, pods.Items),
-			IngressIntents:  mapIngressIntents(ingresses.Items),
-			NetworkPolicies: mapNetworkPolicies(policies.Items),
+			ServiceIntents:  mapServiceIntents(services, pods),
+			IngressIntents:  mapIngressIntents(ingresses),
+			RouteIntents:    mapRouteIntents(gatewayAPI.routes),
+			NetworkPolicies: mapNetworkPolicies(policies),
+			Unavailable:     gatewayAPI.unavailable,
 		}
 
 		respondJSON(w, http.StatusOK, response)
@@ -93,29 +106,20 @@ func TrafficHandler(client kubernetes.Interface) http.HandlerFunc {
 func mapServiceIntents(services []corev1.Service, pods []corev1.Pod) []ServiceIntent {
 	items := []ServiceIntent{}
 	for _, svc := range services {
-		selector := selectorString(svc.Spec.Selector)
-		if selector == "" {
-			items = append(items, ServiceIntent{
-				Namespace: svc.Namespace,
-				Service:   svc.Name,
-				Selector:  "(none)",
-				Pods:      []string{},
-			})
-			continue
-		}
+		selector := mapSelector(svc.Spec.Selector)
 		matches := []string{}
 		for _, pod := range pods {
 			if pod.Namespace != svc.Namespace {
 				continue
 			}
-			if selectorMatches(svc.Spec.Selector, pod.Labels) {
+			if selector.Matches(labels.Set(pod.Labels)) {
 				matches = append(matches, pod.Name)
 			}
 		}
 		items = append(items, ServiceIntent{
 			Namespace: svc.Namespace,
 			Service:   svc.Name,
-			Selector:  selector,
+			Selector:  describeMapSelector(svc.Spec.Selector),
 			Pods:      matches,
 		})
 	}
@@ -169,7 +173,7 @@ func mapNetworkPolicies(policies []networkingv1.NetworkPolicy) []NetworkPolicySu
 			Namespace:    policy.Namespace,
 			Name:         policy.Name,
 			Types:        types,
-			PodSelector:  selectorString(policy.Spec.PodSelector.MatchLabels),
+			PodSelector:  describeSelector(&policy.Spec.PodSelector),
 			IngressRules: len(policy.Spec.Ingress),
 			EgressRules:  len(policy.Spec.Egress),
 		})
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type TrafficMatrix struct {
+	Granularity string         `json:"granularity"`
+	Labels      []string       `json:"labels"`
+	Cells       [][]MatrixCell `json:"cells"`
+	Unselected  []string       `json:"unselected"`
+}
+
+// MatrixCell describes traffic from the row to the column. Egress and
+// Ingress report whether each side allows at least one port, not
+// necessarily the same one; Allowed and Ports only count ports both sides
+// allow.
+type MatrixCell struct {
+	Allowed bool     `json:"allowed"`
+	Egress  bool     `json:"egress"`
+	Ingress bool     `json:"ingress"`
+	Ports   []string `json:"ports"`
+}
+
+type matrixWorkload struct {
+	id        string
+	namespace string
+	pod       corev1.Pod
+	ports     []corev1.ContainerPort
+}
+
+func TrafficMatrixHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := contextWithTimeout(r)
+		defer cancel()
+
+		_, selector, err := parseListSelector(r)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		query := r.URL.Query()
+		granularity := query.Get("granularity")
+		switch granularity {
+		case "":
+			granularity = "workload"
+		case "workload", "namespace":
+		default:
+			respondError(w, http.StatusBadRequest, "invalid granularity: must be workload or namespace")
+			return
+		}
+
+		format := query.Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			respondError(w, http.StatusBadRequest, "invalid format: must be json or csv")
+			return
+		}
+
+		namespace := query.Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
+		}
+
+		pods, err := listPods(ctx, cache, namespace, selector)
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		replicaSets, err := listReplicaSets(ctx, cache, namespace, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		jobs, err := listJobs(ctx, cache, namespace, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		// Peers may live outside the requested namespace, so policies and
+		// namespace labels are always read cluster-wide.
+		policies, err := listNetworkPolicies(ctx, cache, v1.NamespaceAll, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		namespaces, err := listNamespaces(ctx, cache, labels.Everything())
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+
+		workloads := matrixWorkloads(pods, replicaSets, jobs)
+		matrix, err := buildTrafficMatrix(ctx, workloads, newPolicyEvaluator(policies, namespaces), granularity)
+		if err != nil {
+			respondError(w, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="traffic-matrix.csv"`)
+			w.WriteHeader(http.StatusOK)
+			writeTrafficMatrixCSV(w, matrix)
+			return
+		}
+		respondJSON(w, http.StatusOK, matrix)
+	}
+}
+
+// matrixWorkloads groups pods by their top-level controller. Policies select
+// by labels, which pods of one workload share, so the first pod stands in
+// for the rest.
+func matrixWorkloads(pods []corev1.Pod, replicaSets []appsv1.ReplicaSet, jobs []batchv1.Job) []matrixWorkload {
+	parents := map[string]string{}
+	for _, rs := range replicaSets {
+		if owner := controllerID(rs.Namespace, rs.OwnerReferences); owner != "" {
+			parents[workloadID("ReplicaSet", rs.Namespace, rs.Name)] = owner
+		}
+	}
+	for _, job := range jobs {
+		if owner := controllerID(job.Namespace, job.OwnerReferences); owner != "" {
+			parents[workloadID("Job", job.Namespace, job.Name)] = owner
+		}
+	}
+
+	byID := map[string]*matrixWorkload{}
+	order := []string{}
+	for _, pod := range pods {
+		id := controllerID(pod.Namespace, pod.OwnerReferences)
+		if parent, ok := parents[id]; ok {
+			id = parent
+		}
+		if id == "" {
+			id = podID(pod.Namespace, pod.Name)
+		}
+		workload, ok := byID[id]
+		if !ok {
+			workload = &matrixWorkload{id: id, namespace: pod.Namespace, pod: pod}
+			byID[id] = workload
+			order = append(order, id)
+		}
+		workload.ports = mergeContainerPorts(workload.ports, pod)
+	}
+
+	sort.Strings(order)
+	workloads := make([]matrixWorkload, 0, len(order))
+	for _, id := range order {
+		workloads = append(workloads, *byID[id])
+	}
+	return workloads
+}
+
+func mergeContainerPorts(ports []corev1.ContainerPort, pod corev1.Pod) []corev1.ContainerPort {
+	seen := map[string]bool{}
+	for _, port := range ports {
+		seen[containerPortString(port)] = true
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if key := containerPortString(port); !seen[key] {
+				seen[key] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// buildTrafficMatrix evaluates every pair of workloads, which grows with the
+// square of the cluster, so it gives up once ctx is done.
+func buildTrafficMatrix(ctx context.Context, workloads []matrixWorkload, evaluator policyEvaluator, granularity string) (TrafficMatrix, error) {
+	matrix := TrafficMatrix{
+		Granularity: granularity,
+		Labels:      []string{},
+		Cells:       [][]MatrixCell{},
+		Unselected:  []string{},
+	}
+
+	seen := map[string]bool{}
+	for _, workload := range workloads {
+		if !evaluator.isolated(networkingv1.PolicyTypeIngress, workload.pod) && !evaluator.isolated(networkingv1.PolicyTypeEgress, workload.pod) {
+			matrix.Unselected = append(matrix.Unselected, workload.id)
+		}
+		if label := matrixLabel(workload, granularity); !seen[label] {
+			seen[label] = true
+			matrix.Labels = append(matrix.Labels, label)
+		}
+	}
+	sort.Strings(matrix.Labels)
+	index := map[string]int{}
+	for i, label := range matrix.Labels {
+		index[label] = i
+	}
+
+	ports := make([][]map[string]bool, len(matrix.Labels))
+	for i := range matrix.Labels {
+		matrix.Cells = append(matrix.Cells, make([]MatrixCell, len(matrix.Labels)))
+		ports[i] = make([]map[string]bool, len(matrix.Labels))
+		for j := range matrix.Labels {
+			ports[i][j] = map[string]bool{}
+		}
+	}
+
+	for _, src := range workloads {
+		if err := ctx.Err(); err != nil {
+			return TrafficMatrix{}, err
+		}
+		for _, dst := range workloads {
+			row := index[matrixLabel(src, granularity)]
+			col := index[matrixLabel(dst, granularity)]
+			cell := &matrix.Cells[row][col]
+			for _, port := range matrixProbePorts(dst) {
+				egress := evaluator.egress(src.pod, dst.pod, port.ContainerPort, port.Protocol).Allowed
+				ingress := evaluator.ingress(src.pod, dst.pod, port.ContainerPort, port.Protocol).Allowed
+				cell.Egress = cell.Egress || egress
+				cell.Ingress = cell.Ingress || ingress
+				if egress && ingress {
+					cell.Allowed = true
+					ports[row][col][containerPortString(port)] = true
+				}
+			}
+		}
+	}
+
+	for i := range matrix.Cells {
+		for j := range matrix.Cells[i] {
+			allowed := make([]string, 0, len(ports[i][j]))
+			for port := range ports[i][j] {
+				allowed = append(allowed, port)
+			}
+			sort.Strings(allowed)
+			matrix.Cells[i][j].Ports = allowed
+		}
+	}
+	return matrix, nil
+}
+
+// matrixProbePorts returns the declared ports of the destination. Without
+// declared ports, port 0 probes for rules that allow every port.
+func matrixProbePorts(workload matrixWorkload) []corev1.ContainerPort {
+	ports := []corev1.ContainerPort{}
+	for _, port := range workload.ports {
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) == 0 {
+		ports = append(ports, corev1.ContainerPort{Protocol: corev1.ProtocolTCP})
+	}
+	return ports
+}
+
+func matrixLabel(workload matrixWorkload, granularity string) string {
+	if granularity == "namespace" {
+		return workload.namespace
+	}
+	return workload.id
+}
+
+func containerPortString(port corev1.ContainerPort) string {
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	if port.ContainerPort == 0 {
+		return "*/" + string(protocol)
+	}
+	return fmt.Sprintf("%d/%s", port.ContainerPort, protocol)
+}
+
+func writeTrafficMatrixCSV(w http.ResponseWriter, matrix TrafficMatrix) {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"from", "to", "allowed", "egress", "ingress", "ports"})
+	for i, from := range matrix.Labels {
+		for j, to := range matrix.Labels {
+			cell := matrix.Cells[i][j]
+			_ = writer.Write([]string{
+				from,
+				to,
+				fmt.Sprint(cell.Allowed),
+				fmt.Sprint(cell.Egress),
+				fmt.Sprint(cell.Ingress),
+				strings.Join(cell.Ports, " "),
+			})
+		}
+	}
+	writer.Flush()
+}
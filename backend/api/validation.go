@@ -1,101 +1,389 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"sort"
 	"strings"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type ValidationItem struct {
 	ID       string   `json:"id"`
+	Rule     string   `json:"rule"`
 	Severity string   `json:"severity"`
 	Title    string   `json:"title"`
 	Details  string   `json:"details"`
 	Objects  []string `json:"objects"`
+	// Refs identifies the object behind each entry of Objects, index for
+	// index, so findings can be suppressed and reported per object.
+	Refs       []ObjectRef        `json:"refs"`
+	Suppressed []SuppressedObject `json:"suppressed,omitempty"`
+}
+
+type ObjectRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
 }
 
 type ValidationResponse struct {
-	Items []ValidationItem `json:"items"`
+	Items          []ValidationItem `json:"items"`
+	SkippedSources []SkippedSource  `json:"skippedSources"`
+}
+
+// SkippedSource is an optional source a report was built without; rules
+// reading it see nothing. Forbidden tells lacking permissions, which are
+// stable across runs, apart from failures such as a sync timeout.
+type SkippedSource struct {
+	Source    string `json:"source"`
+	Reason    string `json:"reason"`
+	Forbidden bool   `json:"forbidden"`
+}
+
+// ValidationSnapshot is the cluster state rules evaluate against.
+type ValidationSnapshot struct {
+	Namespace            string
+	Services             []corev1.Service
+	Pods                 []corev1.Pod
+	EndpointSlices       []discoveryv1.EndpointSlice
+	Ingresses            []networkingv1.Ingress
+	Nodes                []corev1.Node
+	PVCs                 []corev1.PersistentVolumeClaim
+	Roles                []rbacv1.Role
+	ClusterRoles         []rbacv1.ClusterRole
+	RoleBindings         []rbacv1.RoleBinding
+	ClusterRoleBindings  []rbacv1.ClusterRoleBinding
+	Namespaces           []corev1.Namespace
+	Deployments          []appsv1.Deployment
+	StatefulSets         []appsv1.StatefulSet
+	DaemonSets           []appsv1.DaemonSet
+	Jobs                 []batchv1.Job
+	CronJobs             []batchv1.CronJob
+	PodDisruptionBudgets []policyv1.PodDisruptionBudget
+	Secrets              []v1.PartialObjectMetadata
+	Certificates         []CertificateItem
+	// Skipped lists the optional sources that could not be read.
+	Skipped []SkippedSource
 }
 
-func ValidationHandler(client kubernetes.Interface) http.HandlerFunc {
+// ValidationHandler serves the findings as JSON or, with format=, as SARIF,
+// JUnit XML or JSON Lines. With fail-on=, findings at or above that severity
+// turn the status into 422 so `curl --fail` can gate a pipeline.
+func ValidationHandler(cache *kube.Cache, engine *ValidationEngine, version string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		namespace := r.URL.Query().Get("ns")
-		if namespace == "" {
-			namespace = v1.NamespaceAll
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = FormatJSON
 		}
-
-		services, err := client.CoreV1().Services(namespace).List(ctx, v1.ListOptions{})
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+		if !ValidFormat(format) {
+			respondError(w, http.StatusBadRequest, "invalid format: must be json, sarif, junit or jsonl")
 			return
 		}
-
-		pods, err := client.CoreV1().Pods(namespace).List(ctx, v1.ListOptions{})
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+		failOn := query.Get("fail-on")
+		if failOn != "" && !validSeverity(failOn) {
+			respondError(w, http.StatusBadRequest, "invalid fail-on: must be critical, warning or info")
 			return
 		}
 
-		slices, err := client.DiscoveryV1().EndpointSlices(namespace).List(ctx, v1.ListOptions{})
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
+		namespace := query.Get("ns")
+		if namespace == "" {
+			namespace = v1.NamespaceAll
 		}
 
-		ingresses, err := client.NetworkingV1().Ingresses(namespace).List(ctx, v1.ListOptions{})
+		snapshot, err := CollectValidationSnapshot(ctx, cache, namespace)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		nodes, err := client.CoreV1().Nodes().List(ctx, v1.ListOptions{})
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
+		report := ValidationReport{Version: version, Rules: engine.Rules(), Items: engine.Run(snapshot), Skipped: snapshot.Skipped}
+		status := http.StatusOK
+		if report.Failed(failOn) {
+			status = http.StatusUnprocessableEntity
 		}
+		w.Header().Set("Content-Type", FormatContentType(format))
+		w.WriteHeader(status)
+		_ = report.Write(w, format)
+	}
+}
 
-		pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, v1.ListOptions{})
-		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
-			return
-		}
+func CollectValidationSnapshot(ctx context.Context, cache *kube.Cache, namespace string) (*ValidationSnapshot, error) {
+	services, err := listServices(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := listPods(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
 
-		roles, _ := client.RbacV1().Roles(namespace).List(ctx, v1.ListOptions{})
-		clusterRoles, _ := client.RbacV1().ClusterRoles().List(ctx, v1.ListOptions{})
-		roleBindings, _ := client.RbacV1().RoleBindings(namespace).List(ctx, v1.ListOptions{})
-		clusterRoleBindings, _ := client.RbacV1().ClusterRoleBindings().List(ctx, v1.ListOptions{})
+	slices, err := listEndpointSlices(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	ingresses, err := listIngresses(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
 
-		items := []ValidationItem{}
+	nodes, err := listNodes(ctx, cache, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
 
-		items = append(items, validateServicesWithoutEndpoints(services.Items, slices.Items)...)
-		items = append(items, validatePodsNotReadyBehindService(services.Items, pods.Items)...)
-		items = append(items, validateIngressMissingService(services.Items, ingresses.Items)...)
-		items = append(items, validateEndpointSlicesWithoutService(services.Items, slices.Items)...)
-		items = append(items, validateNodePressure(nodes.Items)...)
-		items = append(items, validatePVCPending(pvcs.Items)...)
-		items = append(items, validateRBACRisky(roles.Items, clusterRoles.Items, roleBindings.Items, clusterRoleBindings.Items)...)
+	pvcs, err := listPVCs(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
 
-		sort.Slice(items, func(i, j int) bool {
-			if items[i].Severity == items[j].Severity {
-				return items[i].Title < items[j].Title
+	namespaces, err := listNamespaces(ctx, cache, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	if namespace != v1.NamespaceAll {
+		filtered := []corev1.Namespace{}
+		for _, ns := range namespaces {
+			if ns.Name == namespace {
+				filtered = append(filtered, ns)
 			}
-			return items[i].Severity < items[j].Severity
-		})
+		}
+		namespaces = filtered
+	}
+
+	deployments, err := listDeployments(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	statefulSets, err := listStatefulSets(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	daemonSets, err := listDaemonSets(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := listJobs(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	cronJobs, err := listCronJobs(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	pdbs, err := listPodDisruptionBudgets(ctx, cache, namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
 
-		respondJSON(w, http.StatusOK, ValidationResponse{Items: items})
+	// RBAC may be forbidden for the current user; RBAC rules then see
+	// nothing instead of failing the whole report.
+	skipped := []SkippedSource{}
+	roles := optionalSource(ctx, &skipped, "roles", func(ctx context.Context) ([]rbacv1.Role, error) {
+		return listRoles(ctx, cache, namespace, labels.Everything())
+	})
+	clusterRoles := optionalSource(ctx, &skipped, "clusterroles", func(ctx context.Context) ([]rbacv1.ClusterRole, error) {
+		return listClusterRoles(ctx, cache, labels.Everything())
+	})
+	roleBindings := optionalSource(ctx, &skipped, "rolebindings", func(ctx context.Context) ([]rbacv1.RoleBinding, error) {
+		return listRoleBindings(ctx, cache, namespace, labels.Everything())
+	})
+	clusterRoleBindings := optionalSource(ctx, &skipped, "clusterrolebindings", func(ctx context.Context) ([]rbacv1.ClusterRoleBinding, error) {
+		return listClusterRoleBindings(ctx, cache, labels.Everything())
+	})
+
+	// Certificates need Secret metadata, which is often not readable
+	// cluster-wide; certificate rules then see nothing. The metadata itself
+	// only serves the ignore annotations of TLS Secrets.
+	secrets := optionalSource(ctx, &skipped, "secrets", func(ctx context.Context) ([]v1.PartialObjectMetadata, error) {
+		return listSecretMetadata(ctx, cache, namespace, labels.Everything())
+	})
+	certificates := optionalSource(ctx, &skipped, "certificates", func(ctx context.Context) ([]CertificateItem, error) {
+		list, err := collectCertificates(ctx, cache, namespace)
+		return list.Items, err
+	})
+
+	return &ValidationSnapshot{
+		Namespace:            namespace,
+		Services:             services,
+		Pods:                 pods,
+		EndpointSlices:       slices,
+		Ingresses:            ingresses,
+		Nodes:                nodes,
+		PVCs:                 pvcs,
+		Roles:                roles,
+		ClusterRoles:         clusterRoles,
+		RoleBindings:         roleBindings,
+		ClusterRoleBindings:  clusterRoleBindings,
+		Namespaces:           namespaces,
+		Deployments:          deployments,
+		StatefulSets:         statefulSets,
+		DaemonSets:           daemonSets,
+		Jobs:                 jobs,
+		CronJobs:             cronJobs,
+		PodDisruptionBudgets: pdbs,
+		Secrets:              secrets,
+		Certificates:         certificates,
+		Skipped:              skipped,
+	}, nil
+}
+
+// optionalSource returns the items of list, or nothing when they cannot be
+// read, recording the source in skipped.
+func optionalSource[T any](ctx context.Context, skipped *[]SkippedSource, source string, list func(context.Context) ([]T, error)) []T {
+	items, err := list(ctx)
+	if err != nil {
+		*skipped = append(*skipped, SkippedSource{Source: source, Reason: err.Error(), Forbidden: apierrors.IsForbidden(err)})
+		return nil
+	}
+	return items
+}
+
+// BuiltinRules returns a registry with the checks that ship with KUBI.
+func BuiltinRules() *RuleRegistry {
+	registry := NewRuleRegistry()
+	rules := []Rule{
+		builtinRule{
+			id:          "services-no-endpoints",
+			severity:    SeverityWarning,
+			description: "Services other than ExternalName have no endpoints in EndpointSlices.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validateServicesWithoutEndpoints(s.Services, s.EndpointSlices)
+			},
+		},
+		builtinRule{
+			id:          "pods-not-ready",
+			severity:    SeverityWarning,
+			description: "Pods selected by a Service are not Ready.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validatePodsNotReadyBehindService(s.Services, s.Pods)
+			},
+		},
+		builtinRule{
+			id:          "ingress-missing-service",
+			severity:    SeverityWarning,
+			description: "Ingress backends reference a Service that does not exist.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validateIngressMissingService(s.Services, s.Ingresses)
+			},
+		},
+		builtinRule{
+			id:          "endpointslice-missing-service",
+			severity:    SeverityWarning,
+			description: "EndpointSlices belong to a Service that does not exist.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validateEndpointSlicesWithoutService(s.Services, s.EndpointSlices)
+			},
+		},
+		builtinRule{
+			id:          "node-pressure",
+			severity:    SeverityCritical,
+			description: "Nodes report memory, disk or PID pressure or an unavailable network.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validateNodePressure(s.Nodes)
+			},
+		},
+		builtinRule{
+			id:          "pvc-pending",
+			severity:    SeverityWarning,
+			description: "PersistentVolumeClaims are stuck in Pending.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validatePVCPending(s.PVCs)
+			},
+		},
+		builtinRule{
+			id:          "pod-security-enforced",
+			severity:    SeverityWarning,
+			description: "Workloads do not satisfy the Pod Security Standard their namespace enforces.",
+			evaluate:    validatePodSecurity,
+		},
+		builtinRule{
+			id:          "pod-security-raise",
+			severity:    SeverityInfo,
+			description: "Workloads that would be rejected if namespace Pod Security enforcement were raised to baseline or restricted.",
+			evaluate:    validatePodSecurityRaise,
+		},
+		builtinRule{
+			id:          "probes-missing",
+			severity:    SeverityWarning,
+			description: "Containers of long-running workloads have no readiness or liveness probe.",
+			evaluate:    validateProbes,
+		},
+		builtinRule{
+			id:          "resources-missing",
+			severity:    SeverityWarning,
+			description: "Containers have no CPU or memory requests or limits.",
+			evaluate:    validateResources,
+		},
+		builtinRule{
+			id:          "single-replica-service",
+			severity:    SeverityWarning,
+			description: "Deployments with one replica are selected by a Service.",
+			evaluate:    validateSingleReplica,
+		},
+		builtinRule{
+			id:          "pdb-coverage",
+			severity:    SeverityWarning,
+			description: "Deployments have no PodDisruptionBudget, or one that allows zero disruptions.",
+			evaluate:    validatePDBCoverage,
+		},
+		builtinRule{
+			id:          "replica-spread",
+			severity:    SeverityWarning,
+			description: "Replicated workloads have no topologySpreadConstraints or pod anti-affinity, or run on a single node.",
+			evaluate:    validateReplicaSpread,
+		},
+		builtinRule{
+			id:          "certificate-expired",
+			severity:    SeverityCritical,
+			description: "TLS certificates in Secrets or cert-manager Certificates have expired.",
+			evaluate:    validateCertificatesExpired,
+		},
+		certificateExpiringRule{days: 30},
+		builtinRule{
+			id:          "certificate-host-mismatch",
+			severity:    SeverityWarning,
+			description: "Ingress TLS hosts are not covered by the certificate's SANs, or the TLS Secret is missing.",
+			evaluate:    validateCertificateHosts,
+		},
+		clusterAdminRule{},
+		builtinRule{
+			id:          "rbac-wildcards",
+			severity:    SeverityWarning,
+			description: "Roles or ClusterRoles grant wildcard verbs or resources.",
+			evaluate: func(s *ValidationSnapshot) []ValidationItem {
+				return validateRBACWildcards(s.Roles, s.ClusterRoles)
+			},
+		},
+	}
+	for _, rule := range rules {
+		if err := registry.Register(rule); err != nil {
+			panic(err)
+		}
 	}
+	return registry
 }
 
 func validateServicesWithoutEndpoints(services []corev1.Service, slices []discoveryv1.EndpointSlice) []ValidationItem {
@@ -111,6 +399,7 @@ func validateServicesWithoutEndpoints(services []corev1.Service, slices []discov
 	}
 
 	missing := []string{}
+	refs := []ObjectRef{}
 	for _, svc := range services {
 		key := svc.Namespace + "/" + svc.Name
 		if svc.Spec.Type == corev1.ServiceTypeExternalName {
@@ -118,6 +407,7 @@ func validateServicesWithoutEndpoints(services []corev1.Service, slices []discov
 		}
 		if !serviceHasEndpoints[key] {
 			missing = append(missing, key)
+			refs = append(refs, ObjectRef{Kind: "Service", Namespace: svc.Namespace, Name: svc.Name})
 		}
 	}
 
@@ -131,21 +421,20 @@ func validateServicesWithoutEndpoints(services []corev1.Service, slices []discov
 		Title:    "Services without endpoints",
 		Details:  "Services have no ready endpoints in EndpointSlices.",
 		Objects:  missing,
+		Refs:     refs,
 	}}
 }
 
 func validatePodsNotReadyBehindService(services []corev1.Service, pods []corev1.Pod) []ValidationItem {
 	items := []ValidationItem{}
 	for _, svc := range services {
-		if len(svc.Spec.Selector) == 0 {
-			continue
-		}
+		selector := mapSelector(svc.Spec.Selector)
 		matching := []corev1.Pod{}
 		for _, pod := range pods {
 			if pod.Namespace != svc.Namespace {
 				continue
 			}
-			if selectorMatches(svc.Spec.Selector, pod.Labels) {
+			if selector.Matches(labels.Set(pod.Labels)) {
 				matching = append(matching, pod)
 			}
 		}
@@ -153,9 +442,11 @@ func validatePodsNotReadyBehindService(services []corev1.Service, pods []corev1.
 			continue
 		}
 		notReady := []string{}
+		refs := []ObjectRef{}
 		for _, pod := range matching {
 			if !podReady(pod.Status.Conditions) {
 				notReady = append(notReady, pod.Namespace+"/"+pod.Name)
+				refs = append(refs, ObjectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
 			}
 		}
 		if len(notReady) > 0 {
@@ -165,6 +456,7 @@ func validatePodsNotReadyBehindService(services []corev1.Service, pods []corev1.
 				Title:    "Pods not Ready behind Service",
 				Details:  fmt.Sprintf("Service %s/%s has pods that are not Ready.", svc.Namespace, svc.Name),
 				Objects:  notReady,
+				Refs:     refs,
 			})
 		}
 	}
@@ -177,11 +469,13 @@ func validateIngressMissingService(services []corev1.Service, ingresses []networ
 		serviceSet[svc.Namespace+"/"+svc.Name] = true
 	}
 	missing := []string{}
+	refs := []ObjectRef{}
 	for _, ing := range ingresses {
 		for _, backend := range ingressBackends(ing) {
 			key := ing.Namespace + "/" + backend.ServiceName
 			if !serviceSet[key] {
 				missing = append(missing, ing.Namespace+"/"+ing.Name+" -> "+backend.ServiceName)
+				refs = append(refs, ObjectRef{Kind: "Ingress", Namespace: ing.Namespace, Name: ing.Name})
 			}
 		}
 	}
@@ -194,6 +488,7 @@ func validateIngressMissingService(services []corev1.Service, ingresses []networ
 		Title:    "Ingress points to missing Service",
 		Details:  "Ingress backend references a Service that does not exist.",
 		Objects:  missing,
+		Refs:     refs,
 	}}
 }
 
@@ -203,6 +498,7 @@ func validateEndpointSlicesWithoutService(services []corev1.Service, slices []di
 		serviceSet[svc.Namespace+"/"+svc.Name] = true
 	}
 	missing := []string{}
+	refs := []ObjectRef{}
 	for _, slice := range slices {
 		name := slice.Labels[discoveryv1.LabelServiceName]
 		if name == "" {
@@ -211,6 +507,7 @@ func validateEndpointSlicesWithoutService(services []corev1.Service, slices []di
 		key := slice.Namespace + "/" + name
 		if !serviceSet[key] {
 			missing = append(missing, slice.Namespace+"/"+slice.Name)
+			refs = append(refs, ObjectRef{Kind: "EndpointSlice", Namespace: slice.Namespace, Name: slice.Name})
 		}
 	}
 	if len(missing) == 0 {
@@ -222,11 +519,13 @@ func validateEndpointSlicesWithoutService(services []corev1.Service, slices []di
 		Title:    "EndpointSlice without Service",
 		Details:  "EndpointSlices reference a Service that is missing.",
 		Objects:  missing,
+		Refs:     refs,
 	}}
 }
 
 func validateNodePressure(nodes []corev1.Node) []ValidationItem {
 	issues := []string{}
+	refs := []ObjectRef{}
 	for _, node := range nodes {
 		for _, condition := range node.Status.Conditions {
 			if condition.Status != corev1.ConditionTrue {
@@ -235,6 +534,7 @@ func validateNodePressure(nodes []corev1.Node) []ValidationItem {
 			switch condition.Type {
 			case corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure, corev1.NodeNetworkUnavailable:
 				issues = append(issues, node.Name+" ("+string(condition.Type)+")")
+				refs = append(refs, ObjectRef{Kind: "Node", Name: node.Name})
 			}
 		}
 	}
@@ -247,14 +547,17 @@ func validateNodePressure(nodes []corev1.Node) []ValidationItem {
 		Title:    "Node pressure conditions",
 		Details:  "Nodes report pressure conditions or network unavailable.",
 		Objects:  issues,
+		Refs:     refs,
 	}}
 }
 
 func validatePVCPending(pvcs []corev1.PersistentVolumeClaim) []ValidationItem {
 	pending := []string{}
+	refs := []ObjectRef{}
 	for _, pvc := range pvcs {
 		if pvc.Status.Phase == corev1.ClaimPending {
 			pending = append(pending, pvc.Namespace+"/"+pvc.Name)
+			refs = append(refs, ObjectRef{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name})
 		}
 	}
 	if len(pending) == 0 {
@@ -266,51 +569,97 @@ func validatePVCPending(pvcs []corev1.PersistentVolumeClaim) []ValidationItem {
 		Title:    "PVCs stuck Pending",
 		Details:  "Some PersistentVolumeClaims are not bound.",
 		Objects:  pending,
+		Refs:     refs,
 	}}
 }
 
-func validateRBACRisky(roles []rbacv1.Role, clusterRoles []rbacv1.ClusterRole, roleBindings []rbacv1.RoleBinding, clusterRoleBindings []rbacv1.ClusterRoleBinding) []ValidationItem {
-	items := []ValidationItem{}
+// clusterAdminRule takes an "ignore" param: comma-separated binding names,
+// where a trailing "*" matches by prefix.
+type clusterAdminRule struct {
+	ignore []string
+}
+
+func (clusterAdminRule) ID() string       { return "rbac-cluster-admin" }
+func (clusterAdminRule) Severity() string { return SeverityWarning }
+
+func (clusterAdminRule) Description() string {
+	return "ClusterRoleBindings grant cluster-admin. Param ignore: binding names to skip."
+}
+
+func (r clusterAdminRule) WithParams(params map[string]string) (Rule, error) {
+	for key, value := range params {
+		if key != "ignore" {
+			return nil, fmt.Errorf("unknown param %q", key)
+		}
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				r.ignore = append(r.ignore, name)
+			}
+		}
+	}
+	return r, nil
+}
 
+func (r clusterAdminRule) Evaluate(snapshot *ValidationSnapshot) []ValidationItem {
 	clusterAdminBindings := []string{}
-	for _, binding := range clusterRoleBindings {
-		if strings.EqualFold(binding.RoleRef.Name, "cluster-admin") {
+	refs := []ObjectRef{}
+	for _, binding := range snapshot.ClusterRoleBindings {
+		if strings.EqualFold(binding.RoleRef.Name, "cluster-admin") && !r.ignored(binding.Name) {
 			clusterAdminBindings = append(clusterAdminBindings, binding.Name)
+			refs = append(refs, ObjectRef{Kind: "ClusterRoleBinding", Name: binding.Name})
 		}
 	}
-	if len(clusterAdminBindings) > 0 {
-		items = append(items, ValidationItem{
-			ID:       "rbac-cluster-admin",
-			Severity: "warning",
-			Title:    "Cluster-admin bindings",
-			Details:  "ClusterRoleBindings grant cluster-admin privileges.",
-			Objects:  clusterAdminBindings,
-		})
+	if len(clusterAdminBindings) == 0 {
+		return nil
 	}
+	return []ValidationItem{{
+		ID:       "rbac-cluster-admin",
+		Severity: "warning",
+		Title:    "Cluster-admin bindings",
+		Details:  "ClusterRoleBindings grant cluster-admin privileges.",
+		Objects:  clusterAdminBindings,
+		Refs:     refs,
+	}}
+}
 
+func (r clusterAdminRule) ignored(name string) bool {
+	for _, pattern := range r.ignore {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+func validateRBACWildcards(roles []rbacv1.Role, clusterRoles []rbacv1.ClusterRole) []ValidationItem {
 	wildcards := []string{}
+	refs := []ObjectRef{}
 	for _, role := range roles {
 		if hasWildcardRule(role.Rules) {
 			wildcards = append(wildcards, role.Namespace+"/"+role.Name)
+			refs = append(refs, ObjectRef{Kind: "Role", Namespace: role.Namespace, Name: role.Name})
 		}
 	}
 	for _, role := range clusterRoles {
 		if hasWildcardRule(role.Rules) {
 			wildcards = append(wildcards, role.Name)
+			refs = append(refs, ObjectRef{Kind: "ClusterRole", Name: role.Name})
 		}
 	}
-	if len(wildcards) > 0 {
-		items = append(items, ValidationItem{
-			ID:       "rbac-wildcards",
-			Severity: "warning",
-			Title:    "RBAC wildcard permissions",
-			Details:  "Roles contain wildcard verbs or resources.",
-			Objects:  wildcards,
-		})
+	if len(wildcards) == 0 {
+		return nil
 	}
-
-	_ = roleBindings
-	return items
+	return []ValidationItem{{
+		ID:       "rbac-wildcards",
+		Severity: "warning",
+		Title:    "RBAC wildcard permissions",
+		Details:  "Roles contain wildcard verbs or resources.",
+		Objects:  wildcards,
+		Refs:     refs,
+	}}
 }
 
 func countEndpoints(slice discoveryv1.EndpointSlice) int {
@@ -321,15 +670,6 @@ func countEndpoints(slice discoveryv1.EndpointSlice) int {
 	return count
 }
 
-func selectorMatches(selector, labels map[string]string) bool {
-	for key, value := range selector {
-		if labels[key] != value {
-			return false
-		}
-	}
-	return true
-}
-
 func hasWildcardRule(rules []rbacv1.PolicyRule) bool {
 	for _, rule := range rules {
 		if containsWildcard(rule.Verbs) || containsWildcard(rule.Resources) {
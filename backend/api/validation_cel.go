@@ -0,0 +1,210 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// celCostLimit bounds a single evaluation so a bad expression cannot stall
+// a validation request.
+const celCostLimit = 1000000
+
+type celObject struct {
+	namespace string
+	name      string
+	object    map[string]interface{}
+}
+
+type celMessageData struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Object    map[string]interface{}
+}
+
+// celRule is a user-defined rule from config.Validation.Custom. Expressions
+// and message templates are compiled once, when the rule is built.
+type celRule struct {
+	id          string
+	kind        string
+	title       string
+	description string
+	severity    string
+	program     cel.Program
+	message     *template.Template
+}
+
+// RegisterCELRules compiles the custom rules and adds them to the registry.
+// Any compile error is returned, so a broken rule fails startup.
+func RegisterCELRules(registry *RuleRegistry, rules []config.CustomRuleConfig) error {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return fmt.Errorf("validation: cel environment: %w", err)
+	}
+
+	for i, cfg := range rules {
+		rule, err := newCELRule(env, cfg)
+		if err != nil {
+			name := cfg.ID
+			if name == "" {
+				name = fmt.Sprintf("#%d", i)
+			}
+			return fmt.Errorf("validation: custom rule %s: %w", name, err)
+		}
+		if err := registry.Register(rule); err != nil {
+			return fmt.Errorf("validation: %w", err)
+		}
+	}
+	return nil
+}
+
+func newCELRule(env *cel.Env, cfg config.CustomRuleConfig) (Rule, error) {
+	if cfg.ID == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if _, ok := celKinds[cfg.Kind]; !ok {
+		return nil, fmt.Errorf("unsupported kind %q", cfg.Kind)
+	}
+	if strings.TrimSpace(cfg.Expression) == "" {
+		return nil, fmt.Errorf("expression is required")
+	}
+
+	ast, issues := env.Compile(cfg.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expression must return bool, got %s", ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(celCostLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	message := cfg.Message
+	if message == "" {
+		message = "{{.Kind}} {{if .Namespace}}{{.Namespace}}/{{end}}{{.Name}} violates " + cfg.ID
+	}
+	tmpl, err := template.New(cfg.ID).Option("missingkey=zero").Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+
+	rule := &celRule{
+		id:          cfg.ID,
+		kind:        cfg.Kind,
+		title:       cfg.Title,
+		description: cfg.Description,
+		severity:    cfg.Severity,
+		program:     program,
+		message:     tmpl,
+	}
+	if rule.title == "" {
+		rule.title = cfg.ID
+	}
+	if rule.description == "" {
+		rule.description = cfg.Kind + ": " + cfg.Expression
+	}
+	if rule.severity == "" {
+		rule.severity = SeverityWarning
+	}
+	return rule, nil
+}
+
+func (r *celRule) ID() string          { return r.id }
+func (r *celRule) Severity() string    { return r.severity }
+func (r *celRule) Description() string { return r.description }
+
+// Evaluate reports one item per object the expression rejects. Evaluation
+// errors, such as reading a missing field without has(), are reported too
+// rather than silently passing.
+func (r *celRule) Evaluate(snapshot *ValidationSnapshot) []ValidationItem {
+	items := []ValidationItem{}
+	for _, object := range celKinds[r.kind](snapshot) {
+		ref := object.name
+		if object.namespace != "" {
+			ref = object.namespace + "/" + object.name
+		}
+
+		out, _, err := r.program.Eval(map[string]interface{}{"object": object.object})
+		var details string
+		switch {
+		case err != nil:
+			details = "CEL evaluation failed: " + err.Error()
+		case out.Value() == true:
+			continue
+		case out.Value() != false:
+			details = fmt.Sprintf("CEL expression returned %v, expected bool", out.Value())
+		default:
+			var message strings.Builder
+			data := celMessageData{Kind: r.kind, Namespace: object.namespace, Name: object.name, Object: object.object}
+			if err := r.message.Execute(&message, data); err != nil {
+				details = "message template failed: " + err.Error()
+			} else {
+				details = message.String()
+			}
+		}
+
+		id := r.id + "-" + object.name
+		if object.namespace != "" {
+			id = r.id + "-" + object.namespace + "-" + object.name
+		}
+		items = append(items, ValidationItem{
+			ID:      id,
+			Title:   r.title,
+			Details: details,
+			Objects: []string{ref},
+			Refs:    []ObjectRef{{Kind: r.kind, Namespace: object.namespace, Name: object.name}},
+		})
+	}
+	return items
+}
+
+// celKinds lists the kinds custom rules can target, keyed by Kind.
+var celKinds = map[string]func(*ValidationSnapshot) []celObject{
+	"Pod":                   func(s *ValidationSnapshot) []celObject { return toCELObjects("Pod", s.Pods) },
+	"Service":               func(s *ValidationSnapshot) []celObject { return toCELObjects("Service", s.Services) },
+	"EndpointSlice":         func(s *ValidationSnapshot) []celObject { return toCELObjects("EndpointSlice", s.EndpointSlices) },
+	"Ingress":               func(s *ValidationSnapshot) []celObject { return toCELObjects("Ingress", s.Ingresses) },
+	"Node":                  func(s *ValidationSnapshot) []celObject { return toCELObjects("Node", s.Nodes) },
+	"Namespace":             func(s *ValidationSnapshot) []celObject { return toCELObjects("Namespace", s.Namespaces) },
+	"PersistentVolumeClaim": func(s *ValidationSnapshot) []celObject { return toCELObjects("PersistentVolumeClaim", s.PVCs) },
+	"Deployment":            func(s *ValidationSnapshot) []celObject { return toCELObjects("Deployment", s.Deployments) },
+	"StatefulSet":           func(s *ValidationSnapshot) []celObject { return toCELObjects("StatefulSet", s.StatefulSets) },
+	"DaemonSet":             func(s *ValidationSnapshot) []celObject { return toCELObjects("DaemonSet", s.DaemonSets) },
+	"Job":                   func(s *ValidationSnapshot) []celObject { return toCELObjects("Job", s.Jobs) },
+	"CronJob":               func(s *ValidationSnapshot) []celObject { return toCELObjects("CronJob", s.CronJobs) },
+	"Role":                  func(s *ValidationSnapshot) []celObject { return toCELObjects("Role", s.Roles) },
+	"ClusterRole":           func(s *ValidationSnapshot) []celObject { return toCELObjects("ClusterRole", s.ClusterRoles) },
+	"RoleBinding":           func(s *ValidationSnapshot) []celObject { return toCELObjects("RoleBinding", s.RoleBindings) },
+	"ClusterRoleBinding": func(s *ValidationSnapshot) []celObject {
+		return toCELObjects("ClusterRoleBinding", s.ClusterRoleBindings)
+	},
+}
+
+// toCELObjects converts typed objects to the map form CEL reads. Lister
+// objects carry no TypeMeta, so kind is filled in.
+func toCELObjects[T any](kind string, items []T) []celObject {
+	objects := make([]celObject, 0, len(items))
+	for i := range items {
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&items[i])
+		if err != nil {
+			continue
+		}
+		object["kind"] = kind
+		metadata, _ := object["metadata"].(map[string]interface{})
+		namespace, _ := metadata["namespace"].(string)
+		name, _ := metadata["name"].(string)
+		objects = append(objects, celObject{namespace: namespace, name: name, object: object})
+	}
+	return objects
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRegisterCELRulesRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule config.CustomRuleConfig
+		want string
+	}{
+		{"missing id", config.CustomRuleConfig{Kind: "Pod", Expression: "true"}, "id is required"},
+		{"unsupported kind", config.CustomRuleConfig{ID: "r", Kind: "Secret", Expression: "true"}, `unsupported kind "Secret"`},
+		{"missing expression", config.CustomRuleConfig{ID: "r", Kind: "Pod", Expression: " "}, "expression is required"},
+		{"syntax error", config.CustomRuleConfig{ID: "r", Kind: "Pod", Expression: "object.metadata.name =="}, "Syntax error"},
+		{"not bool", config.CustomRuleConfig{ID: "r", Kind: "Pod", Expression: "'text'"}, "expression must return bool"},
+		{"bad message", config.CustomRuleConfig{ID: "r", Kind: "Pod", Expression: "true", Message: "{{.Name"}, "message:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterCELRules(NewRuleRegistry(), []config.CustomRuleConfig{tt.rule})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterCELRulesRejectsDuplicateIDs(t *testing.T) {
+	rule := config.CustomRuleConfig{ID: "r", Kind: "Pod", Expression: "true"}
+	if err := RegisterCELRules(NewRuleRegistry(), []config.CustomRuleConfig{rule, rule}); err == nil {
+		t.Fatal("duplicate rule IDs were accepted")
+	}
+}
+
+func TestCELRuleEvaluate(t *testing.T) {
+	labeled := testPod("team-a", "web", "10.0.0.1", map[string]string{"team": "a"})
+	unlabeled := testPod("team-b", "api", "10.0.0.2", nil)
+	snapshot := &ValidationSnapshot{Pods: []corev1.Pod{labeled, unlabeled}}
+
+	tests := []struct {
+		name    string
+		rule    config.CustomRuleConfig
+		ids     []string
+		details []string
+	}{
+		{
+			name: "passing objects are not reported",
+			rule: config.CustomRuleConfig{ID: "any", Kind: "Pod", Expression: "object.kind == 'Pod'"},
+		},
+		{
+			name:    "default message",
+			rule:    config.CustomRuleConfig{ID: "team-label", Kind: "Pod", Expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"},
+			ids:     []string{"team-label-team-b-api"},
+			details: []string{"Pod team-b/api violates team-label"},
+		},
+		{
+			name:    "message template reads the object",
+			rule:    config.CustomRuleConfig{ID: "ip", Kind: "Pod", Expression: "false", Message: "{{.Name}} has {{.Object.status.podIP}}"},
+			ids:     []string{"ip-team-a-web", "ip-team-b-api"},
+			details: []string{"web has 10.0.0.1", "api has 10.0.0.2"},
+		},
+		{
+			name:    "evaluation errors are reported",
+			rule:    config.CustomRuleConfig{ID: "labels", Kind: "Pod", Expression: "object.metadata.labels.team == 'a'"},
+			ids:     []string{"labels-team-b-api"},
+			details: []string{"CEL evaluation failed: "},
+		},
+		{
+			name:    "non-bool result is reported",
+			rule:    config.CustomRuleConfig{ID: "dyn", Kind: "Pod", Expression: "object.metadata.name"},
+			ids:     []string{"dyn-team-a-web", "dyn-team-b-api"},
+			details: []string{"CEL expression returned web, expected bool", "CEL expression returned api, expected bool"},
+		},
+		{
+			name: "other kinds are not evaluated",
+			rule: config.CustomRuleConfig{ID: "svc", Kind: "Service", Expression: "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRuleRegistry()
+			if err := RegisterCELRules(registry, []config.CustomRuleConfig{tt.rule}); err != nil {
+				t.Fatalf("RegisterCELRules: %v", err)
+			}
+			items := registry.Rules()[0].Evaluate(snapshot)
+			if len(items) != len(tt.ids) {
+				t.Fatalf("got %d items %+v, want %d", len(items), items, len(tt.ids))
+			}
+			for i, item := range items {
+				if item.ID != tt.ids[i] {
+					t.Errorf("item %d: got ID %q, want %q", i, item.ID, tt.ids[i])
+				}
+				if !strings.HasPrefix(item.Details, tt.details[i]) {
+					t.Errorf("item %d: got details %q, want prefix %q", i, item.Details, tt.details[i])
+				}
+				if len(item.Refs) != 1 || item.Refs[0].Kind != tt.rule.Kind {
+					t.Errorf("item %d: got refs %+v", i, item.Refs)
+				}
+			}
+		})
+	}
+}
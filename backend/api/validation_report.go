@@ -0,0 +1,435 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Report formats accepted by the format parameter and the CLI.
+const (
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+	FormatJUnit = "junit"
+	FormatJSONL = "jsonl"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// ValidationResult is a single finding for a single object. Machine-readable
+// formats report results rather than items, so each object can be tracked
+// and suppressed on its own.
+type ValidationResult struct {
+	Rule       string `json:"rule"`
+	Severity   string `json:"severity"`
+	Title      string `json:"title"`
+	Message    string `json:"message"`
+	Object     string `json:"object"`
+	Kind       string `json:"kind,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Suppressed bool   `json:"suppressed"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// ValidationReport is everything a report format needs: the findings, the
+// catalog of rules that ran, so passing rules show up too, and the sources
+// the findings were computed without.
+type ValidationReport struct {
+	Version string
+	Rules   []RuleInfo
+	Items   []ValidationItem
+	Skipped []SkippedSource
+}
+
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatSARIF, FormatJUnit, FormatJSONL:
+		return true
+	}
+	return false
+}
+
+func FormatContentType(format string) string {
+	switch format {
+	case FormatSARIF:
+		return "application/sarif+json"
+	case FormatJUnit:
+		return "application/xml"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// Results flattens items into per-object results. Suppressed objects are
+// included and marked.
+func (r ValidationReport) Results() []ValidationResult {
+	results := []ValidationResult{}
+	for _, item := range r.Items {
+		for i, object := range item.Objects {
+			result := ValidationResult{Rule: item.Rule, Severity: item.Severity, Title: item.Title, Message: item.Details, Object: object}
+			if i < len(item.Refs) {
+				result.Kind, result.Namespace, result.Name = item.Refs[i].Kind, item.Refs[i].Namespace, item.Refs[i].Name
+			}
+			results = append(results, result)
+		}
+		for _, suppressed := range item.Suppressed {
+			results = append(results, ValidationResult{
+				Rule:       item.Rule,
+				Severity:   item.Severity,
+				Title:      item.Title,
+				Message:    item.Details,
+				Object:     suppressed.Object,
+				Kind:       suppressed.Ref.Kind,
+				Namespace:  suppressed.Ref.Namespace,
+				Name:       suppressed.Ref.Name,
+				Suppressed: true,
+				Reason:     suppressed.Reason,
+			})
+		}
+	}
+	return results
+}
+
+// Failed reports whether any unsuppressed finding is at or above failOn.
+// An empty or unknown threshold never fails.
+func (r ValidationReport) Failed(failOn string) bool {
+	threshold := severityRank(failOn)
+	if threshold < 0 {
+		return false
+	}
+	for _, item := range r.Items {
+		if len(item.Objects) > 0 && severityRank(item.Severity) <= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// Incomplete reports whether a source was skipped for a reason other than
+// missing permissions, e.g. a timeout, so the findings may differ from run
+// to run.
+func (r ValidationReport) Incomplete() bool {
+	for _, source := range r.Skipped {
+		if !source.Forbidden {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ValidationReport) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatSARIF:
+		return r.writeSARIF(w)
+	case FormatJUnit:
+		return r.writeJUnit(w)
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+		for _, result := range r.Results() {
+			if err := encoder.Encode(result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	skipped := r.Skipped
+	if skipped == nil {
+		skipped = []SkippedSource{}
+	}
+	return json.NewEncoder(w).Encode(ValidationResponse{Items: r.Items, SkippedSources: skipped})
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+}
+
+// sarifInvocation reports skipped sources as tool notifications; the run
+// only counts as successful when none was skipped for a transient reason.
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string             `json:"ruleId"`
+	RuleIndex           *int               `json:"ruleIndex,omitempty"`
+	Level               string             `json:"level"`
+	Message             sarifMessage       `json:"message"`
+	Locations           []sarifLocation    `json:"locations"`
+	PartialFingerprints map[string]string  `json:"partialFingerprints"`
+	Suppressions        []sarifSuppression `json:"suppressions,omitempty"`
+	Properties          map[string]string  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+func (r ValidationReport) writeSARIF(w io.Writer) error {
+	driver := sarifDriver{Name: "kubi", Version: r.Version, Rules: []sarifRule{}}
+	index := map[string]int{}
+	for _, info := range r.Rules {
+		rule := sarifRule{ID: info.ID, ShortDescription: sarifMessage{Text: info.Description}}
+		rule.DefaultConfiguration.Level = sarifLevel(info.Severity)
+		index[info.ID] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, rule)
+	}
+
+	results := []sarifResult{}
+	for _, result := range r.Results() {
+		entry := sarifResult{
+			RuleID:  result.Rule,
+			Level:   sarifLevel(result.Severity),
+			Message: sarifMessage{Text: result.Title + ": " + result.Object + ". " + result.Message},
+			Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+				FullyQualifiedName: resultLocation(result),
+				Kind:               "resource",
+			}}}},
+			PartialFingerprints: map[string]string{"kubi/v1": resultFingerprint(result)},
+		}
+		if ruleIndex, ok := index[result.Rule]; ok {
+			entry.RuleIndex = &ruleIndex
+		}
+		if result.Kind != "" {
+			entry.Properties = map[string]string{"kind": result.Kind, "namespace": result.Namespace, "name": result.Name}
+		}
+		if result.Suppressed {
+			entry.Suppressions = []sarifSuppression{{Kind: "external", Justification: result.Reason}}
+		}
+		results = append(results, entry)
+	}
+
+	invocation := sarifInvocation{ExecutionSuccessful: !r.Incomplete()}
+	for _, source := range r.Skipped {
+		level := "error"
+		if source.Forbidden {
+			level = "warning"
+		}
+		invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, sarifNotification{
+			Level:      level,
+			Message:    sarifMessage{Text: "skipped " + source.Source + ": " + source.Reason},
+			Properties: map[string]string{"source": source.Source},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Invocations: []sarifInvocation{invocation}, Results: results}},
+	})
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+func resultLocation(result ValidationResult) string {
+	if result.Kind == "" {
+		return result.Object
+	}
+	if result.Namespace == "" {
+		return result.Kind + "/" + result.Name
+	}
+	return result.Namespace + "/" + result.Kind + "/" + result.Name
+}
+
+// resultFingerprint is stable across runs for the same rule and object, so
+// CI systems can track a finding over time. It hashes the object's identity
+// rather than its Object text, which carries details such as expiry dates;
+// only findings without a ref fall back to that text.
+func resultFingerprint(result ValidationResult) string {
+	identity := result.Object
+	if result.Kind != "" {
+		identity = result.Kind + "\x00" + result.Namespace + "\x00" + result.Name
+	}
+	sum := sha256.Sum256([]byte(result.Rule + "\x00" + identity))
+	return hex.EncodeToString(sum[:16])
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit emits one suite per enabled rule and one case per object; a
+// rule without findings gets a single passing case. Skipped sources make up
+// a "sources" suite: forbidden ones are skipped cases, others errors.
+func (r ValidationReport) writeJUnit(w io.Writer) error {
+	byRule := map[string][]ValidationResult{}
+	for _, result := range r.Results() {
+		byRule[result.Rule] = append(byRule[result.Rule], result)
+	}
+	rules := []string{}
+	for _, info := range r.Rules {
+		if info.Enabled {
+			rules = append(rules, info.ID)
+		}
+	}
+	for rule := range byRule {
+		if !containsString(rules, rule) {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Strings(rules)
+
+	report := junitTestSuites{Name: "kubi"}
+	for _, rule := range rules {
+		suite := junitTestSuite{Name: rule}
+		results := byRule[rule]
+		if len(results) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "no findings", ClassName: rule})
+		}
+		for _, result := range results {
+			testCase := junitTestCase{Name: result.Object, ClassName: rule}
+			if result.Suppressed {
+				testCase.Skipped = &junitSkipped{Message: "suppressed: " + result.Reason}
+				suite.Skipped++
+			} else {
+				testCase.Failure = &junitFailure{
+					Type:    result.Severity,
+					Message: result.Title,
+					Text:    fmt.Sprintf("%s\n%s", result.Object, result.Message),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if len(r.Skipped) > 0 {
+		suite := junitTestSuite{Name: "sources"}
+		for _, source := range r.Skipped {
+			testCase := junitTestCase{Name: source.Source, ClassName: "sources"}
+			if source.Forbidden {
+				testCase.Skipped = &junitSkipped{Message: "forbidden: " + source.Reason}
+				suite.Skipped++
+			} else {
+				testCase.Error = &junitFailure{Type: "skipped", Message: "source not read", Text: source.Reason}
+				suite.Errors++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func testReport() ValidationReport {
+	return ValidationReport{
+		Version: "test",
+		Rules: []RuleInfo{
+			{ID: "no-probes", Description: "Containers without probes", Severity: SeverityWarning, Enabled: true},
+			{ID: "privileged", Description: "Privileged containers", Severity: SeverityCritical, Enabled: true},
+			{ID: "disabled", Description: "Disabled rule", Severity: SeverityWarning},
+		},
+		Items: []ValidationItem{
+			{
+				Rule:     "privileged",
+				Severity: SeverityCritical,
+				Title:    "Privileged containers",
+				Details:  "Containers run privileged.",
+				Objects:  []string{"shop/web"},
+				Refs:     []ObjectRef{{Kind: "Deployment", Namespace: "shop", Name: "web"}},
+			},
+			{
+				Rule:     "no-probes",
+				Severity: SeverityWarning,
+				Title:    "Missing probes",
+				Details:  "Containers have no readiness probe.",
+				Objects:  []string{"shop/api", "node-1"},
+				Refs:     []ObjectRef{{Kind: "Deployment", Namespace: "shop", Name: "api"}, {Kind: "Node", Name: "node-1"}},
+				Suppressed: []SuppressedObject{{
+					Object: "shop/worker",
+					Ref:    ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "worker"},
+					Source: "config",
+					Reason: "batch job",
+				}},
+			},
+		},
+	}
+}
+
+func TestValidationReportResults(t *testing.T) {
+	results := testReport().Results()
+	want := []struct {
+		rule       string
+		object     string
+		suppressed bool
+	}{
+		{"privileged", "shop/web", false},
+		{"no-probes", "shop/api", false},
+		{"no-probes", "node-1", false},
+		{"no-probes", "shop/worker", true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results %+v, want %d", len(results), results, len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Rule != w.rule || got.Object != w.object || got.Suppressed != w.suppressed {
+			t.Errorf("result %d: got %s %s suppressed=%v, want %s %s suppressed=%v", i, got.Rule, got.Object, got.Suppressed, w.rule, w.object, w.suppressed)
+		}
+	}
+	if results[3].Reason != "batch job" || results[3].Kind != "Deployment" {
+		t.Errorf("suppressed result lost its ref or reason: %+v", results[3])
+	}
+}
+
+func TestValidationReportFailed(t *testing.T) {
+	allSuppressed := ValidationReport{Items: []ValidationItem{{
+		Rule:       "privileged",
+		Severity:   SeverityCritical,
+		Suppressed: []SuppressedObject{{Object: "shop/web"}},
+	}}}
+	tests := []struct {
+		name   string
+		report ValidationReport
+		failOn string
+		want   bool
+	}{
+		{"critical at warning", testReport(), SeverityWarning, true},
+		{"critical at critical", testReport(), SeverityCritical, true},
+		{"no threshold", testReport(), "", false},
+		{"info threshold", testReport(), SeverityInfo, true},
+		{"unknown threshold", testReport(), "high", false},
+		{"suppressed only", allSuppressed, SeverityWarning, false},
+		{"no items", ValidationReport{}, SeverityWarning, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.Failed(tt.failOn); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationReportIncomplete(t *testing.T) {
+	tests := []struct {
+		name    string
+		skipped []SkippedSource
+		want    bool
+	}{
+		{"nothing skipped", nil, false},
+		{"forbidden only", []SkippedSource{{Source: "rbac", Forbidden: true}}, false},
+		{"timeout", []SkippedSource{{Source: "rbac", Forbidden: true}, {Source: "certificates", Reason: "timeout"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (ValidationReport{Skipped: tt.skipped}).Incomplete(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResultFingerprint(t *testing.T) {
+	base := ValidationResult{Rule: "certificate-expiring", Object: "shop/tls (expires 2026-03-01)", Kind: "Secret", Namespace: "shop", Name: "tls"}
+	tests := []struct {
+		name  string
+		other func(ValidationResult) ValidationResult
+		same  bool
+	}{
+		{"identical", func(r ValidationResult) ValidationResult { return r }, true},
+		{"object text changes", func(r ValidationResult) ValidationResult {
+			r.Object = "shop/tls (expires 2026-04-01)"
+			return r
+		}, true},
+		{"message and severity change", func(r ValidationResult) ValidationResult {
+			r.Message, r.Severity = "other", SeverityCritical
+			return r
+		}, true},
+		{"other rule", func(r ValidationResult) ValidationResult {
+			r.Rule = "certificate-expired"
+			return r
+		}, false},
+		{"other namespace", func(r ValidationResult) ValidationResult {
+			r.Namespace = "billing"
+			return r
+		}, false},
+		{"other kind", func(r ValidationResult) ValidationResult {
+			r.Kind = "Ingress"
+			return r
+		}, false},
+		{"fields do not run together", func(r ValidationResult) ValidationResult {
+			r.Namespace, r.Name = "sho", "ptls"
+			return r
+		}, false},
+		{"without a ref the object text counts", func(r ValidationResult) ValidationResult {
+			r.Kind, r.Namespace, r.Name = "", "", ""
+			return r
+		}, false},
+	}
+
+	want := resultFingerprint(base)
+	if len(want) != 32 {
+		t.Fatalf("got fingerprint %q, want 32 hex characters", want)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resultFingerprint(tt.other(base))
+			if (got == want) != tt.same {
+				t.Errorf("got %s for %s, same=%v", got, want, tt.same)
+			}
+		})
+	}
+}
+
+func TestValidationReportWriteJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		skipped []SkippedSource
+		want    int
+	}{
+		{"nothing skipped", nil, 0},
+		{"skipped", []SkippedSource{{Source: "rbac", Reason: "forbidden", Forbidden: true}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testReport()
+			report.Skipped = tt.skipped
+			var buf bytes.Buffer
+			if err := report.Write(&buf, FormatJSON); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			var decoded map[string]json.RawMessage
+			if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var skipped []SkippedSource
+			if string(decoded["skippedSources"]) == "null" || json.Unmarshal(decoded["skippedSources"], &skipped) != nil {
+				t.Fatalf("got skippedSources %s, want a list", decoded["skippedSources"])
+			}
+			if len(skipped) != tt.want {
+				t.Errorf("got %d skipped sources, want %d", len(skipped), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationReportWriteJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().Write(&buf, FormatJSONL); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var result ValidationResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if result.Rule == "" || result.Object == "" {
+			t.Errorf("line %d: incomplete result %s", i, line)
+		}
+	}
+}
+
+func TestValidationReportWriteSARIF(t *testing.T) {
+	tests := []struct {
+		name          string
+		skipped       []SkippedSource
+		successful    bool
+		notifications []string
+	}{
+		{"nothing skipped", nil, true, nil},
+		{"forbidden source", []SkippedSource{{Source: "rbac", Reason: "forbidden", Forbidden: true}}, true, []string{"warning"}},
+		{"failed source", []SkippedSource{{Source: "certificates", Reason: "timeout"}}, false, []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testReport()
+			report.Skipped = tt.skipped
+			var buf bytes.Buffer
+			if err := report.Write(&buf, FormatSARIF); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			var log sarifLog
+			if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("got version %q with %d runs", log.Version, len(log.Runs))
+			}
+			run := log.Runs[0]
+
+			if len(run.Tool.Driver.Rules) != 3 {
+				t.Errorf("got %d rules, want every rule in the catalog", len(run.Tool.Driver.Rules))
+			}
+			if len(run.Results) != 4 {
+				t.Fatalf("got %d results, want 4", len(run.Results))
+			}
+			levels := []string{"error", "warning", "warning", "warning"}
+			for i, result := range run.Results {
+				if result.Level != levels[i] {
+					t.Errorf("result %d: got level %q, want %q", i, result.Level, levels[i])
+				}
+				if result.RuleIndex == nil || run.Tool.Driver.Rules[*result.RuleIndex].ID != result.RuleID {
+					t.Errorf("result %d: rule index does not point at %s", i, result.RuleID)
+				}
+				if result.PartialFingerprints["kubi/v1"] == "" {
+					t.Errorf("result %d: missing fingerprint", i)
+				}
+			}
+			if got := run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName; got != "shop/Deployment/web" {
+				t.Errorf("got location %q", got)
+			}
+			if got := run.Results[2].Locations[0].LogicalLocations[0].FullyQualifiedName; got != "Node/node-1" {
+				t.Errorf("got cluster-scoped location %q", got)
+			}
+			if len(run.Results[2].Suppressions) != 0 || len(run.Results[3].Suppressions) != 1 {
+				t.Errorf("suppressions not reported on the suppressed result only")
+			}
+
+			if len(run.Invocations) != 1 {
+				t.Fatalf("got %d invocations, want 1", len(run.Invocations))
+			}
+			invocation := run.Invocations[0]
+			if invocation.ExecutionSuccessful != tt.successful {
+				t.Errorf("got executionSuccessful %v, want %v", invocation.ExecutionSuccessful, tt.successful)
+			}
+			if len(invocation.ToolExecutionNotifications) != len(tt.notifications) {
+				t.Fatalf("got %d notifications, want %d", len(invocation.ToolExecutionNotifications), len(tt.notifications))
+			}
+			for i, notification := range invocation.ToolExecutionNotifications {
+				if notification.Level != tt.notifications[i] {
+					t.Errorf("notification %d: got level %q, want %q", i, notification.Level, tt.notifications[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidationReportWriteJUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		skipped []SkippedSource
+		suites  []string
+		tests   int
+		errors  int
+		skips   int
+	}{
+		{
+			name:   "rules only",
+			suites: []string{"no-probes", "privileged"},
+			tests:  4,
+			skips:  1,
+		},
+		{
+			name:    "with skipped sources",
+			skipped: []SkippedSource{{Source: "rbac", Reason: "forbidden", Forbidden: true}, {Source: "certificates", Reason: "timeout"}},
+			suites:  []string{"no-probes", "privileged", "sources"},
+			tests:   6,
+			errors:  1,
+			skips:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := testReport()
+			report.Skipped = tt.skipped
+			var buf bytes.Buffer
+			if err := report.Write(&buf, FormatJUnit); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if !strings.HasPrefix(buf.String(), xml.Header) {
+				t.Errorf("missing XML header")
+			}
+			var suites junitTestSuites
+			if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			names := []string{}
+			for _, suite := range suites.Suites {
+				names = append(names, suite.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.suites, ",") {
+				t.Errorf("got suites %v, want %v", names, tt.suites)
+			}
+			if suites.Tests != tt.tests || suites.Failures != 3 || suites.Errors != tt.errors || suites.Skipped != tt.skips {
+				t.Errorf("got tests=%d failures=%d errors=%d skipped=%d, want %d/3/%d/%d",
+					suites.Tests, suites.Failures, suites.Errors, suites.Skipped, tt.tests, tt.errors, tt.skips)
+			}
+		})
+	}
+}
+
+func TestValidationReportJUnitPassingRule(t *testing.T) {
+	report := ValidationReport{Rules: []RuleInfo{{ID: "no-probes", Enabled: true}}}
+	var buf bytes.Buffer
+	if err := report.Write(&buf, FormatJUnit); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(suites.Suites) != 1 || len(suites.Suites[0].Cases) != 1 || suites.Suites[0].Cases[0].Failure != nil {
+		t.Errorf("got %+v, want one passing case", suites.Suites)
+	}
+}
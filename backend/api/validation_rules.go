@@ -0,0 +1,228 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+)
+
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Rule is a single validation check. Evaluate returns the findings for the
+// snapshot; their severity is replaced by the configured one.
+type Rule interface {
+	ID() string
+	Severity() string
+	Description() string
+	Evaluate(snapshot *ValidationSnapshot) []ValidationItem
+}
+
+// ParamRule is implemented by rules that accept parameters from config.
+// WithParams returns a configured copy and rejects unknown parameters.
+type ParamRule interface {
+	Rule
+	WithParams(params map[string]string) (Rule, error)
+}
+
+type RuleInfo struct {
+	ID              string            `json:"id"`
+	Description     string            `json:"description"`
+	DefaultSeverity string            `json:"defaultSeverity"`
+	Severity        string            `json:"severity"`
+	Enabled         bool              `json:"enabled"`
+	Params          map[string]string `json:"params,omitempty"`
+}
+
+type RulesResponse struct {
+	Rules        []RuleInfo        `json:"rules"`
+	Suppressions []SuppressionInfo `json:"suppressions"`
+}
+
+type RuleRegistry struct {
+	rules []Rule
+	ids   map[string]bool
+}
+
+func NewRuleRegistry() *RuleRegistry {
+	return &RuleRegistry{ids: map[string]bool{}}
+}
+
+func (r *RuleRegistry) Register(rule Rule) error {
+	if rule.ID() == "" {
+		return fmt.Errorf("rule without id")
+	}
+	if r.ids[rule.ID()] {
+		return fmt.Errorf("duplicate rule %q", rule.ID())
+	}
+	if !validSeverity(rule.Severity()) {
+		return fmt.Errorf("rule %s: invalid severity %q", rule.ID(), rule.Severity())
+	}
+	r.ids[rule.ID()] = true
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *RuleRegistry) Rules() []Rule {
+	return append([]Rule{}, r.rules...)
+}
+
+type configuredRule struct {
+	rule     Rule
+	enabled  bool
+	severity string
+	params   map[string]string
+}
+
+// ValidationEngine runs the registered rules with the per-rule settings
+// from config. It is built once at startup and safe for concurrent use.
+type ValidationEngine struct {
+	rules        []configuredRule
+	suppressions []suppression
+	now          func() time.Time
+}
+
+func NewValidationEngine(registry *RuleRegistry, cfg config.ValidationConfig) (*ValidationEngine, error) {
+	for id := range cfg.Rules {
+		if !registry.ids[id] {
+			return nil, fmt.Errorf("validation: unknown rule %q", id)
+		}
+	}
+
+	suppressions, err := parseSuppressions(registry.ids, cfg.Suppressions)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &ValidationEngine{suppressions: suppressions, now: time.Now}
+	for _, rule := range registry.rules {
+		settings := cfg.Rules[rule.ID()]
+		configured := configuredRule{
+			rule:     rule,
+			enabled:  true,
+			severity: rule.Severity(),
+			params:   settings.Params,
+		}
+		if settings.Enabled != nil {
+			configured.enabled = *settings.Enabled
+		}
+		if settings.Severity != "" {
+			if !validSeverity(settings.Severity) {
+				return nil, fmt.Errorf("validation: rule %s: invalid severity %q", rule.ID(), settings.Severity)
+			}
+			configured.severity = settings.Severity
+		}
+		if len(settings.Params) > 0 {
+			paramRule, ok := rule.(ParamRule)
+			if !ok {
+				return nil, fmt.Errorf("validation: rule %s takes no params", rule.ID())
+			}
+			withParams, err := paramRule.WithParams(settings.Params)
+			if err != nil {
+				return nil, fmt.Errorf("validation: rule %s: %w", rule.ID(), err)
+			}
+			configured.rule = withParams
+		}
+		engine.rules = append(engine.rules, configured)
+	}
+	return engine, nil
+}
+
+// Run evaluates the enabled rules. Suppressed objects are moved to
+// ValidationItem.Suppressed; items whose objects are all suppressed are kept
+// so audits still see them.
+func (e *ValidationEngine) Run(snapshot *ValidationSnapshot) []ValidationItem {
+	now := e.now()
+	annotations := snapshotAnnotations(snapshot)
+	items := []ValidationItem{}
+	for _, configured := range e.rules {
+		if !configured.enabled {
+			continue
+		}
+		for _, item := range configured.rule.Evaluate(snapshot) {
+			item.Rule = configured.rule.ID()
+			item.Severity = configured.severity
+			e.suppress(&item, annotations, now)
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Severity == items[j].Severity {
+			return items[i].Title < items[j].Title
+		}
+		return severityRank(items[i].Severity) < severityRank(items[j].Severity)
+	})
+	return items
+}
+
+func (e *ValidationEngine) Rules() []RuleInfo {
+	infos := make([]RuleInfo, 0, len(e.rules))
+	for _, configured := range e.rules {
+		infos = append(infos, RuleInfo{
+			ID:              configured.rule.ID(),
+			Description:     configured.rule.Description(),
+			DefaultSeverity: configured.rule.Severity(),
+			Severity:        configured.severity,
+			Enabled:         configured.enabled,
+			Params:          configured.params,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+func (e *ValidationEngine) Suppressions() []SuppressionInfo {
+	now := e.now()
+	infos := make([]SuppressionInfo, 0, len(e.suppressions))
+	for _, s := range e.suppressions {
+		infos = append(infos, s.info(now))
+	}
+	return infos
+}
+
+func ValidationRulesHandler(engine *ValidationEngine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondJSON(w, http.StatusOK, RulesResponse{Rules: engine.Rules(), Suppressions: engine.Suppressions()})
+	}
+}
+
+func validSeverity(severity string) bool {
+	return severityRank(severity) >= 0
+}
+
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityInfo:
+		return 2
+	}
+	return -1
+}
+
+// builtinRule adapts a check function to the Rule interface.
+type builtinRule struct {
+	id          string
+	severity    string
+	description string
+	evaluate    func(snapshot *ValidationSnapshot) []ValidationItem
+}
+
+func (r builtinRule) ID() string          { return r.id }
+func (r builtinRule) Severity() string    { return r.severity }
+func (r builtinRule) Description() string { return r.description }
+
+func (r builtinRule) Evaluate(snapshot *ValidationSnapshot) []ValidationItem {
+	return r.evaluate(snapshot)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// IgnoreAnnotation lists the rule IDs, comma-separated, whose findings
+	// are suppressed for the annotated object; "*" suppresses every rule.
+	IgnoreAnnotation       = "kubi.io/ignore"
+	IgnoreReasonAnnotation = "kubi.io/ignore-reason"
+)
+
+type SuppressedObject struct {
+	Object  string    `json:"object"`
+	Ref     ObjectRef `json:"ref"`
+	Source  string    `json:"source"`
+	Reason  string    `json:"reason"`
+	Expires string    `json:"expires,omitempty"`
+}
+
+type SuppressionInfo struct {
+	Rule      string `json:"rule"`
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Reason    string `json:"reason"`
+	Expires   string `json:"expires,omitempty"`
+	Expired   bool   `json:"expired"`
+}
+
+type suppression struct {
+	config.SuppressionConfig
+	expires time.Time
+}
+
+func parseSuppressions(ids map[string]bool, configs []config.SuppressionConfig) ([]suppression, error) {
+	suppressions := make([]suppression, 0, len(configs))
+	for i, cfg := range configs {
+		if cfg.Rule == "" {
+			return nil, fmt.Errorf("validation: suppression #%d: rule is required", i)
+		}
+		if cfg.Rule != "*" && !ids[cfg.Rule] {
+			return nil, fmt.Errorf("validation: suppression #%d: unknown rule %q", i, cfg.Rule)
+		}
+		if strings.TrimSpace(cfg.Reason) == "" {
+			return nil, fmt.Errorf("validation: suppression #%d: reason is required", i)
+		}
+		for _, pattern := range []string{cfg.Namespace, cfg.Name} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("validation: suppression #%d: invalid pattern %q", i, pattern)
+			}
+		}
+
+		parsed := suppression{SuppressionConfig: cfg}
+		if cfg.Expires != "" {
+			expires, err := parseExpiry(cfg.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("validation: suppression #%d: invalid expires %q", i, cfg.Expires)
+			}
+			parsed.expires = expires
+		}
+		suppressions = append(suppressions, parsed)
+	}
+	return suppressions, nil
+}
+
+// parseExpiry accepts a date, which stays valid through the end of that day
+// in UTC, or a full RFC 3339 timestamp.
+func parseExpiry(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date.Add(24 * time.Hour), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (s suppression) expired(now time.Time) bool {
+	return !s.expires.IsZero() && !now.Before(s.expires)
+}
+
+func (s suppression) matches(rule string, ref ObjectRef) bool {
+	if s.Rule != "*" && s.Rule != rule {
+		return false
+	}
+	if s.Kind != "" && s.Kind != ref.Kind {
+		return false
+	}
+	return globMatch(s.Namespace, ref.Namespace) && globMatch(s.Name, ref.Name)
+}
+
+func (s suppression) info(now time.Time) SuppressionInfo {
+	return SuppressionInfo{
+		Rule:      s.Rule,
+		Kind:      s.Kind,
+		Namespace: s.Namespace,
+		Name:      s.Name,
+		Reason:    s.Reason,
+		Expires:   s.SuppressionConfig.Expires,
+		Expired:   s.expired(now),
+	}
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// suppress moves the objects of item that are suppressed by an annotation or
+// an unexpired config entry to item.Suppressed. Expired entries are skipped,
+// so their findings resurface without a config change.
+func (e *ValidationEngine) suppress(item *ValidationItem, annotations map[ObjectRef]map[string]string, now time.Time) {
+	objects := []string{}
+	refs := []ObjectRef{}
+	for i, object := range item.Objects {
+		var ref ObjectRef
+		if i < len(item.Refs) {
+			ref = item.Refs[i]
+		}
+		if suppressed, ok := e.suppression(item.Rule, object, ref, annotations, now); ok {
+			item.Suppressed = append(item.Suppressed, suppressed)
+			continue
+		}
+		objects = append(objects, object)
+		refs = append(refs, ref)
+	}
+	item.Objects = objects
+	item.Refs = refs
+}
+
+func (e *ValidationEngine) suppression(rule, object string, ref ObjectRef, annotations map[ObjectRef]map[string]string, now time.Time) (SuppressedObject, bool) {
+	if values := annotations[ref]; values != nil && ignoresRule(values[IgnoreAnnotation], rule) {
+		return SuppressedObject{
+			Object: object,
+			Ref:    ref,
+			Source: "annotation",
+			Reason: values[IgnoreReasonAnnotation],
+		}, true
+	}
+	for _, s := range e.suppressions {
+		if s.expired(now) || !s.matches(rule, ref) {
+			continue
+		}
+		return SuppressedObject{
+			Object:  object,
+			Ref:     ref,
+			Source:  "config",
+			Reason:  s.Reason,
+			Expires: s.SuppressionConfig.Expires,
+		}, true
+	}
+	return SuppressedObject{}, false
+}
+
+func ignoresRule(value, rule string) bool {
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id == "*" || id == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotAnnotations indexes the ignore annotations of every snapshot
+// object; objects without them are left out.
+func snapshotAnnotations(s *ValidationSnapshot) map[ObjectRef]map[string]string {
+	index := map[ObjectRef]map[string]string{}
+	indexAnnotations(index, "Pod", s.Pods)
+	indexAnnotations(index, "Service", s.Services)
+	indexAnnotations(index, "EndpointSlice", s.EndpointSlices)
+	indexAnnotations(index, "Ingress", s.Ingresses)
+	indexAnnotations(index, "Node", s.Nodes)
+	indexAnnotations(index, "Namespace", s.Namespaces)
+	indexAnnotations(index, "PersistentVolumeClaim", s.PVCs)
+	indexAnnotations(index, "Deployment", s.Deployments)
+	indexAnnotations(index, "StatefulSet", s.StatefulSets)
+	indexAnnotations(index, "DaemonSet", s.DaemonSets)
+	indexAnnotations(index, "Job", s.Jobs)
+	indexAnnotations(index, "CronJob", s.CronJobs)
+	indexAnnotations(index, "Role", s.Roles)
+	indexAnnotations(index, "ClusterRole", s.ClusterRoles)
+	indexAnnotations(index, "RoleBinding", s.RoleBindings)
+	indexAnnotations(index, "ClusterRoleBinding", s.ClusterRoleBindings)
+	indexAnnotations(index, "Secret", s.Secrets)
+	return index
+}
+
+func indexAnnotations[T any, P interface {
+	*T
+	v1.Object
+}](index map[ObjectRef]map[string]string, kind string, items []T) {
+	for i := range items {
+		object := P(&items[i])
+		annotations := object.GetAnnotations()
+		if annotations[IgnoreAnnotation] == "" {
+			continue
+		}
+		index[ObjectRef{Kind: kind, Namespace: object.GetNamespace(), Name: object.GetName()}] = annotations
+	}
+}
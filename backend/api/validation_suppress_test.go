@@ -0,0 +1,219 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+)
+
+var suppressTestRules = map[string]bool{"no-probes": true, "no-limits": true}
+
+func testSuppressItem() ValidationItem {
+	return ValidationItem{
+		Rule:    "no-probes",
+		Objects: []string{"shop/web", "shop/api", "billing/api"},
+		Refs: []ObjectRef{
+			{Kind: "Deployment", Namespace: "shop", Name: "web"},
+			{Kind: "Deployment", Namespace: "shop", Name: "api"},
+			{Kind: "StatefulSet", Namespace: "billing", Name: "api"},
+		},
+	}
+}
+
+func suppressedObjects(item ValidationItem) []string {
+	objects := []string{}
+	for _, suppressed := range item.Suppressed {
+		objects = append(objects, suppressed.Object)
+	}
+	return objects
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2026-03-01", want: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{value: "2026-03-01T12:30:00Z", want: time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{value: "2026-03-01T12:30:00+02:00", want: time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{value: "01/03/2026", wantErr: true},
+		{value: "2026-02-30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseExpiry(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExpiry: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSuppressionsRejectsInvalidEntries(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.SuppressionConfig
+	}{
+		{"missing rule", config.SuppressionConfig{Reason: "r"}},
+		{"unknown rule", config.SuppressionConfig{Rule: "nope", Reason: "r"}},
+		{"missing reason", config.SuppressionConfig{Rule: "no-probes", Reason: " "}},
+		{"invalid pattern", config.SuppressionConfig{Rule: "no-probes", Reason: "r", Namespace: "["}},
+		{"invalid expires", config.SuppressionConfig{Rule: "no-probes", Reason: "r", Expires: "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSuppressions(suppressTestRules, []config.SuppressionConfig{tt.cfg}); err == nil {
+				t.Fatal("invalid suppression was accepted")
+			}
+		})
+	}
+}
+
+func TestSuppressExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expires    string
+		suppressed bool
+	}{
+		{"no expiry", "", true},
+		{"later date", "2026-03-02", true},
+		{"same date lasts through the day", "2026-03-01", true},
+		{"earlier date", "2026-02-28", false},
+		{"timestamp ahead", "2026-03-01T15:00:01Z", true},
+		{"timestamp reached", "2026-03-01T15:00:00Z", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suppressions, err := parseSuppressions(suppressTestRules, []config.SuppressionConfig{
+				{Rule: "no-probes", Name: "web", Reason: "batch job", Expires: tt.expires},
+			})
+			if err != nil {
+				t.Fatalf("parseSuppressions: %v", err)
+			}
+			engine := &ValidationEngine{suppressions: suppressions}
+			item := testSuppressItem()
+			engine.suppress(&item, nil, now)
+
+			if got := len(item.Suppressed) == 1; got != tt.suppressed {
+				t.Fatalf("suppressed %v, want %v", suppressedObjects(item), tt.suppressed)
+			}
+			if len(item.Objects)+len(item.Suppressed) != 3 || len(item.Objects) != len(item.Refs) {
+				t.Errorf("objects %v and refs %v out of step", item.Objects, item.Refs)
+			}
+			if tt.suppressed && item.Suppressed[0].Expires != tt.expires {
+				t.Errorf("got expires %q, want %q", item.Suppressed[0].Expires, tt.expires)
+			}
+			if got := suppressions[0].info(now).Expired; got == tt.suppressed {
+				t.Errorf("info reports expired %v", got)
+			}
+		})
+	}
+}
+
+func TestSuppressMatching(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		suppressions []config.SuppressionConfig
+		annotations  map[string]string
+		want         []string
+		source       string
+	}{
+		{
+			name: "nothing configured",
+			want: []string{},
+		},
+		{
+			name:        "annotation lists the rule",
+			annotations: map[string]string{IgnoreAnnotation: "no-limits, no-probes", IgnoreReasonAnnotation: "sidecar"},
+			want:        []string{"shop/api"},
+			source:      "annotation",
+		},
+		{
+			name:        "annotation wildcard",
+			annotations: map[string]string{IgnoreAnnotation: "*"},
+			want:        []string{"shop/api"},
+			source:      "annotation",
+		},
+		{
+			name:        "annotation for another rule",
+			annotations: map[string]string{IgnoreAnnotation: "no-limits"},
+			want:        []string{},
+		},
+		{
+			name:         "config namespace glob",
+			suppressions: []config.SuppressionConfig{{Rule: "no-probes", Namespace: "sh*", Reason: "legacy"}},
+			want:         []string{"shop/web", "shop/api"},
+			source:       "config",
+		},
+		{
+			name:         "config kind",
+			suppressions: []config.SuppressionConfig{{Rule: "no-probes", Kind: "StatefulSet", Reason: "legacy"}},
+			want:         []string{"billing/api"},
+			source:       "config",
+		},
+		{
+			name:         "config name across namespaces",
+			suppressions: []config.SuppressionConfig{{Rule: "*", Name: "api", Reason: "legacy"}},
+			want:         []string{"shop/api", "billing/api"},
+			source:       "config",
+		},
+		{
+			name:         "config for another rule",
+			suppressions: []config.SuppressionConfig{{Rule: "no-limits", Reason: "legacy"}},
+			want:         []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suppressions, err := parseSuppressions(suppressTestRules, tt.suppressions)
+			if err != nil {
+				t.Fatalf("parseSuppressions: %v", err)
+			}
+			engine := &ValidationEngine{suppressions: suppressions}
+			annotations := map[ObjectRef]map[string]string{}
+			if tt.annotations != nil {
+				annotations[ObjectRef{Kind: "Deployment", Namespace: "shop", Name: "api"}] = tt.annotations
+			}
+			item := testSuppressItem()
+			engine.suppress(&item, annotations, now)
+
+			if got := suppressedObjects(item); !equalStrings(got, tt.want) {
+				t.Fatalf("got suppressed %v, want %v", got, tt.want)
+			}
+			for _, suppressed := range item.Suppressed {
+				if suppressed.Source != tt.source {
+					t.Errorf("%s: got source %q, want %q", suppressed.Object, suppressed.Source, tt.source)
+				}
+			}
+		})
+	}
+}
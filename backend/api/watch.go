@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/kube"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const watchBookmarkInterval = 15 * time.Second
+
+var watchResources = []string{
+	"pods",
+	"services",
+	"endpointslices",
+	"nodes",
+	"ingresses",
+	"deployments",
+	"statefulsets",
+	"daemonsets",
+}
+
+type WatchEvent struct {
+	Type            string `json:"type"`
+	Resource        string `json:"resource"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+	Object          any    `json:"object"`
+}
+
+type WatchBookmark struct {
+	ResourceVersions map[string]string `json:"resourceVersions"`
+}
+
+type WatchResync struct {
+	Reason string `json:"reason"`
+}
+
+func WatchHandler(cache *kube.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		namespace := query.Get("ns")
+
+		selector, err := labels.Parse(query.Get("labelSelector"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid labelSelector: "+err.Error())
+			return
+		}
+
+		resources, err := parseWatchResources(query.Get("resources"))
+		if err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			respondError(w, http.StatusInternalServerError, "streaming unsupported")
+			return
+		}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = query.Get("since")
+		}
+
+		ctx, cancel := contextWithTimeout(r)
+		cache, sub, err := subscribeWatch(ctx, cache, namespace, lastID, resources, query.Get("resources") == "")
+		resync := errors.Is(err, kube.ErrEventsExpired)
+		if resync {
+			cache, sub, err = subscribeWatch(ctx, cache, namespace, "", resources, query.Get("resources") == "")
+		}
+		cancel()
+		if err != nil {
+			respondClusterError(w, err)
+			return
+		}
+		defer sub.Cancel()
+
+		// The server-wide write timeout would otherwise cut the stream.
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if resync {
+			writeSSE(w, "resync", sub.Cursor, WatchResync{Reason: kube.ErrEventsExpired.Error()})
+		}
+		for _, event := range sub.Replay {
+			if watchEventMatches(event, namespace, selector) {
+				writeSSE(w, "change", event.ID, mapWatchEvent(event))
+			}
+		}
+		// Bookmarks carry the position of the last event this stream has
+		// processed, including filtered ones, so a resume never skips ahead
+		// of events still queued for delivery.
+		cursor := sub.Cursor
+		writeSSE(w, "bookmark", cursor, WatchBookmark{ResourceVersions: cache.ResourceVersions()})
+		flusher.Flush()
+
+		ticker := time.NewTicker(watchBookmarkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				if position := sub.Position(); position != "" {
+					cursor = position
+				}
+				writeSSE(w, "bookmark", cursor, WatchBookmark{ResourceVersions: cache.ResourceVersions()})
+				flusher.Flush()
+			case event, ok := <-sub.Events:
+				if !ok {
+					// Dropped as a slow consumer or the cache was replaced;
+					// the client reconnects with Last-Event-ID.
+					return
+				}
+				cursor = event.ID
+				if !watchEventMatches(event, namespace, selector) {
+					continue
+				}
+				writeSSE(w, "change", event.ID, mapWatchEvent(event))
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// subscribeWatch subscribes across the cluster, or to namespace alone when
+// the credentials may not watch a resource across the cluster, and returns
+// the cache it subscribed to. Nodes are not namespaced, so a namespaced
+// stream leaves them out unless they were asked for.
+func subscribeWatch(ctx context.Context, cache *kube.Cache, namespace, lastID string, resources []string, defaulted bool) (*kube.Cache, *kube.Subscription, error) {
+	sub, err := cache.Subscribe(ctx, lastID, resources...)
+	if err == nil || namespace == "" || !apierrors.IsForbidden(err) {
+		return cache, sub, err
+	}
+	scoped := cache.Namespace(namespace)
+	if defaulted {
+		namespaced := []string{}
+		for _, resource := range resources {
+			if resource != "nodes" {
+				namespaced = append(namespaced, resource)
+			}
+		}
+		resources = namespaced
+	}
+	sub, err = scoped.Subscribe(ctx, lastID, resources...)
+	return scoped, sub, err
+}
+
+func parseWatchResources(value string) ([]string, error) {
+	if value == "" {
+		return watchResources, nil
+	}
+	supported := map[string]bool{}
+	for _, resource := range watchResources {
+		supported[resource] = true
+	}
+	resources := []string{}
+	for _, resource := range strings.Split(value, ",") {
+		resource = strings.TrimSpace(strings.ToLower(resource))
+		if resource == "" {
+			continue
+		}
+		if resource == "workloads" {
+			resources = append(resources, "deployments", "statefulsets", "daemonsets")
+			continue
+		}
+		if !supported[resource] {
+			return nil, fmt.Errorf("unsupported resource %q", resource)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func watchEventMatches(event kube.Event, namespace string, selector labels.Selector) bool {
+	if namespace != "" && event.Namespace != "" && event.Namespace != namespace {
+		return false
+	}
+	return selector.Matches(labels.Set(event.Labels))
+}
+
+func mapWatchEvent(event kube.Event) WatchEvent {
+	return WatchEvent{
+		Type:            event.Type,
+		Resource:        event.Resource,
+		Namespace:       event.Namespace,
+		Name:            event.Name,
+		ResourceVersion: event.ResourceVersion,
+		Object:          mapWatchObject(event.Object),
+	}
+}
+
+func mapWatchObject(obj any) any {
+	switch item := obj.(type) {
+	case *corev1.Pod:
+		return mapPod(item)
+	case *corev1.Service:
+		return mapService(item)
+	case *corev1.Node:
+		return mapNode(item)
+	case *discoveryv1.EndpointSlice:
+		return mapEndpointSlice(*item)
+	case *networkingv1.Ingress:
+		return mapIngressIntents([]networkingv1.Ingress{*item})
+	case *appsv1.Deployment:
+		return mapWorkloadsDeployments([]appsv1.Deployment{*item})[0]
+	case *appsv1.StatefulSet:
+		return mapWorkloadsStateful([]appsv1.StatefulSet{*item})[0]
+	case *appsv1.DaemonSet:
+		return mapWorkloadsDaemon([]appsv1.DaemonSet{*item})[0]
+	}
+	return nil
+}
+
+func writeSSE(w http.ResponseWriter, event, id string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\nid: %s\ndata: %s\n\n", event, id, data)
+}
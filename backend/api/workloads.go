@@ -4,14 +4,15 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/kube"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 type WorkloadItem struct {
 	Name              string    `json:"name"`
 	Namespace         string    `json:"namespace"`
+	Selector          string    `json:"selector"`
 	DesiredReplicas   int32     `json:"desiredReplicas"`
 	ReadyReplicas     int32     `json:"readyReplicas"`
 	UpdatedReplicas   int32     `json:"updatedReplicas"`
@@ -25,12 +26,12 @@ type WorkloadsResponse struct {
 	DaemonSets   []WorkloadItem `json:"daemonSets"`
 }
 
-func WorkloadsHandler(client kubernetes.Interface) http.HandlerFunc {
+func WorkloadsHandler(cache *kube.Cache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := contextWithTimeout(r)
 		defer cancel()
 
-		opts, err := parseListOptions(r)
+		_, selector, err := parseListSelector(r)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, err.Error())
 			return
@@ -41,28 +42,28 @@ func WorkloadsHandler(client kubernetes.Interface) http.HandlerFunc {
 			namespace = v1.NamespaceAll
 		}
 
-		deployments, err := client.AppsV1().Deployments(namespace).List(ctx, opts)
+		deployments, err := listDeployments(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		statefulSets, err := client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		statefulSets, err := listStatefulSets(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
-		daemonSets, err := client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		daemonSets, err := listDaemonSets(ctx, cache, namespace, selector)
 		if err != nil {
-			respondError(w, http.StatusBadGateway, err.Error())
+			respondClusterError(w, err)
 			return
 		}
 
 		response := WorkloadsResponse{
-			Deployments:  mapWorkloadsDeployments(deployments.Items),
-			StatefulSets: mapWorkloadsStateful(statefulSets.Items),
-			DaemonSets:   mapWorkloadsDaemon(daemonSets.Items),
+			Deployments:  mapWorkloadsDeployments(deployments),
+			StatefulSets: mapWorkloadsStateful(statefulSets),
+			DaemonSets:   mapWorkloadsDaemon(daemonSets),
 		}
 
 		respondJSON(w, http.StatusOK, response)
@@ -79,6 +80,7 @@ func mapWorkloadsDeployments(items []appsv1.Deployment) []WorkloadItem {
 		result = append(result, WorkloadItem{
 			Name:              item.Name,
 			Namespace:         item.Namespace,
+			Selector:          describeSelector(item.Spec.Selector),
 			DesiredReplicas:   desired,
 			ReadyReplicas:     item.Status.ReadyReplicas,
 			UpdatedReplicas:   item.Status.UpdatedReplicas,
@@ -99,6 +101,7 @@ func mapWorkloadsStateful(items []appsv1.StatefulSet) []WorkloadItem {
 		result = append(result, WorkloadItem{
 			Name:              item.Name,
 			Namespace:         item.Namespace,
+			Selector:          describeSelector(item.Spec.Selector),
 			DesiredReplicas:   desired,
 			ReadyReplicas:     item.Status.ReadyReplicas,
 			UpdatedReplicas:   item.Status.UpdatedReplicas,
@@ -115,6 +118,7 @@ func mapWorkloadsDaemon(items []appsv1.DaemonSet) []WorkloadItem {
 		result = append(result, WorkloadItem{
 			Name:              item.Name,
 			Namespace:         item.Namespace,
+			Selector:          describeSelector(item.Spec.Selector),
 			DesiredReplicas:   item.Status.DesiredNumberScheduled,
 			ReadyReplicas:     item.Status.NumberReady,
 			UpdatedReplicas:   item.Status.UpdatedNumberScheduled,
@@ -5,25 +5,75 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ConfigFile          string `yaml:"-"`
-	Kubeconfig          string `yaml:"kubeconfig"`
-	Context             string `yaml:"context"`
-	Namespace           string `yaml:"namespace"`
-	Listen              string `yaml:"listen"`
-	Port                int    `yaml:"port"`
-	LogLevel            string `yaml:"logLevel"`
-	NoMetrics           bool   `yaml:"noMetrics"`
-	SecretsMetadataOnly bool   `yaml:"secretsMetadataOnly"`
-	AllowSecretValues   bool   `yaml:"allowSecretValues"`
-	ReadonlyStrict      bool   `yaml:"readonlyStrict"`
+	ConfigFile          string   `yaml:"-"`
+	Kubeconfig          string   `yaml:"kubeconfig"`
+	Context             string   `yaml:"context"`
+	Namespace           string   `yaml:"namespace"`
+	Listen              string   `yaml:"listen"`
+	Port                int      `yaml:"port"`
+	LogLevel            string   `yaml:"logLevel"`
+	NoMetrics           bool     `yaml:"noMetrics"`
+	SecretsMetadataOnly bool     `yaml:"secretsMetadataOnly"`
+	AllowSecretValues   bool     `yaml:"allowSecretValues"`
+	ReadonlyStrict      bool     `yaml:"readonlyStrict"`
+	Snapshot            string   `yaml:"snapshot"`
+	Clusters            []string `yaml:"clusters"`
+
+	Validation ValidationConfig `yaml:"validation"`
+}
+
+type ValidationConfig struct {
+	Rules        map[string]RuleConfig `yaml:"rules"`
+	Custom       []CustomRuleConfig    `yaml:"custom"`
+	Suppressions []SuppressionConfig   `yaml:"suppressions"`
+}
+
+// RuleConfig overrides a validation rule. Unset fields keep the rule's
+// defaults; Enabled is a pointer so "enabled: false" can be told apart.
+type RuleConfig struct {
+	Enabled  *bool             `yaml:"enabled"`
+	Severity string            `yaml:"severity"`
+	Params   map[string]string `yaml:"params"`
+}
+
+// CustomRuleConfig declares a rule as a CEL expression evaluated against
+// every object of Kind. The expression returns true for compliant objects.
+// Message is a text/template rendered per violation.
+type CustomRuleConfig struct {
+	ID          string `yaml:"id"`
+	Kind        string `yaml:"kind"`
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Severity    string `yaml:"severity"`
+	Expression  string `yaml:"expression"`
+	Message     string `yaml:"message"`
+}
+
+// SuppressionConfig hides findings of Rule for matching objects until
+// Expires (YYYY-MM-DD or RFC 3339). Kind, Namespace and Name are optional;
+// Namespace and Name accept glob patterns.
+type SuppressionConfig struct {
+	Rule      string `yaml:"rule"`
+	Kind      string `yaml:"kind"`
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Reason    string `yaml:"reason"`
+	Expires   string `yaml:"expires"`
 }
 
 func Parse() (Config, error) {
+	return ParseArgs(os.Args[0], os.Args[1:], nil)
+}
+
+// ParseArgs parses args like Parse. register, if set, adds flags of a
+// subcommand to the same flag set.
+func ParseArgs(name string, args []string, register func(*flag.FlagSet)) (Config, error) {
 	cfg := Config{
 		Listen:              "127.0.0.1",
 		Port:                17890,
@@ -32,10 +82,13 @@ func Parse() (Config, error) {
 	}
 
 	defaultPath := defaultConfigPath()
-	pre := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	pre := flag.NewFlagSet(name, flag.ContinueOnError)
 	pre.SetOutput(io.Discard)
 	configPath := pre.String("config", "", "path to config file")
-	_ = pre.Parse(os.Args[1:])
+	if register != nil {
+		register(pre)
+	}
+	_ = pre.Parse(args)
 	pathUsed := defaultPath
 	if *configPath != "" {
 		pathUsed = *configPath
@@ -52,7 +105,7 @@ func Parse() (Config, error) {
 		}
 	}
 
-	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
 	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "path to config file")
 	fs.StringVar(&cfg.Kubeconfig, "kubeconfig", cfg.Kubeconfig, "path to kubeconfig file (default: standard kubeconfig resolution)")
 	fs.StringVar(&cfg.Context, "context", cfg.Context, "kubeconfig context to use")
@@ -64,7 +117,22 @@ func Parse() (Config, error) {
 	fs.BoolVar(&cfg.SecretsMetadataOnly, "secrets-metadata-only", cfg.SecretsMetadataOnly, "do not fetch secret values")
 	fs.BoolVar(&cfg.AllowSecretValues, "allow-secret-values", cfg.AllowSecretValues, "allow fetching secret values (unsafe)")
 	fs.BoolVar(&cfg.ReadonlyStrict, "readonly-strict", cfg.ReadonlyStrict, "reject any mutating requests even if handlers are added")
-	fs.Parse(os.Args[1:])
+	clusters := fs.String("clusters", strings.Join(cfg.Clusters, ","), "comma-separated kubeconfig contexts to load at startup and aggregate across")
+	fs.StringVar(&cfg.Snapshot, "snapshot", cfg.Snapshot, "serve a snapshot archive written by kubi snapshot instead of a live cluster")
+	if register != nil {
+		register(fs)
+	}
+	fs.Parse(args)
+	cfg.Clusters = splitList(*clusters)
+
+	// Snapshots capture Secrets as metadata only, so there are no bodies to
+	// read.
+	if cfg.Snapshot != "" {
+		if cfg.AllowSecretValues {
+			return cfg, fmt.Errorf("cannot enable --allow-secret-values with --snapshot: snapshots hold no secret values")
+		}
+		cfg.SecretsMetadataOnly = true
+	}
 
 	if cfg.AllowSecretValues && cfg.SecretsMetadataOnly {
 		return cfg, fmt.Errorf("cannot enable --allow-secret-values while --secrets-metadata-only is true")
@@ -77,6 +145,16 @@ func Parse() (Config, error) {
 	return cfg, nil
 }
 
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func (c Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Listen, c.Port)
 }
@@ -0,0 +1,552 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	eventslisters "k8s.io/client-go/listers/events/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
+	rbaclisters "k8s.io/client-go/listers/rbac/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/metadata/metadatalister"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ErrSecretsMetadataOnly is returned by TLSSecrets when the cache may only
+// read Secret metadata.
+var ErrSecretsMetadataOnly = errors.New("secrets are metadata-only: set secretsMetadataOnly to false to read certificates")
+
+type ResourceStatus struct {
+	Resource string
+	Synced   bool
+	Error    string
+}
+
+// Cache owns the shared informers for a single cluster connection. Informers
+// are registered and started lazily on first use, so resources nobody asks
+// for are never watched.
+type Cache struct {
+	mu        sync.Mutex
+	clientset kubernetes.Interface
+	metaCl    metadata.Interface
+	dynamicCl dynamic.Interface
+	namespace string
+	factory   informers.SharedInformerFactory
+	metadata  metadatainformer.SharedInformerFactory
+	dynamic   dynamicinformer.DynamicSharedInformerFactory
+	tls       informers.SharedInformerFactory
+	discovery discovery.DiscoveryInterface
+	served    map[string]servedGroupVersion
+	stop      chan struct{}
+	stopped   bool
+	informers map[string]cache.SharedIndexInformer
+	errors    map[string]error
+	denied    map[string]chan struct{}
+	scoped    map[string]*Cache
+	events    *eventLog
+}
+
+// NewCache builds the informers of one cluster. Unless readSecrets is set,
+// Secrets are only watched through the metadata informer.
+func NewCache(clientset kubernetes.Interface, metadataClient metadata.Interface, dynamicClient dynamic.Interface, readSecrets bool) *Cache {
+	return newCache(clientset, metadataClient, dynamicClient, readSecrets, v1.NamespaceAll)
+}
+
+func newCache(clientset kubernetes.Interface, metadataClient metadata.Interface, dynamicClient dynamic.Interface, readSecrets bool, namespace string) *Cache {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTransform(stripManagedFields))
+	metadataFactory := metadatainformer.NewSharedInformerFactoryWithOptions(metadataClient, 0, metadatainformer.WithTransform(stripManagedFields))
+	if namespace != v1.NamespaceAll {
+		// The metadata factory takes either a namespace or a transform;
+		// managed fields are tolerable in a single namespace.
+		metadataFactory = metadatainformer.NewFilteredSharedInformerFactory(metadataClient, 0, namespace, nil)
+	}
+	dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, namespace, nil)
+	var tlsFactory informers.SharedInformerFactory
+	if readSecrets {
+		tlsFactory = informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithNamespace(namespace),
+			informers.WithTweakListOptions(func(opts *v1.ListOptions) {
+				opts.FieldSelector = "type=" + string(corev1.SecretTypeTLS)
+			}),
+			informers.WithTransform(publicCertificate))
+	}
+	return &Cache{
+		clientset: clientset,
+		metaCl:    metadataClient,
+		dynamicCl: dynamicClient,
+		namespace: namespace,
+		factory:   factory,
+		metadata:  metadataFactory,
+		dynamic:   dynamicFactory,
+		tls:       tlsFactory,
+		discovery: clientset.Discovery(),
+		served:    map[string]servedGroupVersion{},
+		stop:      make(chan struct{}),
+		informers: map[string]cache.SharedIndexInformer{},
+		errors:    map[string]error{},
+		denied:    map[string]chan struct{}{},
+		scoped:    map[string]*Cache{},
+		events:    newEventLog(),
+	}
+}
+
+// Namespace returns a cache whose informers watch only namespace, for
+// credentials that may list a resource in a namespace but not across the
+// cluster. It is stopped with c and keeps its own event log, so its
+// Subscribe replays only the namespace.
+func (c *Cache) Namespace(namespace string) *Cache {
+	if namespace == v1.NamespaceAll || c.namespace != v1.NamespaceAll {
+		return c
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scoped, ok := c.scoped[namespace]
+	if !ok {
+		scoped = newCache(c.clientset, c.metaCl, c.dynamicCl, c.tls != nil, namespace)
+		if c.stopped {
+			scoped.stopped = true
+			close(scoped.stop)
+		}
+		c.scoped[namespace] = scoped
+	}
+	return scoped
+}
+
+func (c *Cache) Stop() {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return
+	}
+	c.stopped = true
+	close(c.stop)
+	scoped := c.scoped
+	c.mu.Unlock()
+
+	for _, child := range scoped {
+		child.Stop()
+	}
+
+	c.factory.Shutdown()
+	c.metadata.Shutdown()
+	c.dynamic.Shutdown()
+	if c.tls != nil {
+		c.tls.Shutdown()
+	}
+	c.events.close()
+}
+
+func (c *Cache) Status() []ResourceStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := make([]ResourceStatus, 0, len(c.informers))
+	for resource, informer := range c.informers {
+		status := ResourceStatus{Resource: resource, Synced: informer.HasSynced()}
+		if c.namespace != v1.NamespaceAll {
+			status.Resource += " (namespace " + c.namespace + ")"
+		}
+		if err := c.errors[resource]; err != nil && !status.Synced {
+			status.Error = err.Error()
+		}
+		statuses = append(statuses, status)
+	}
+	for _, scoped := range c.scoped {
+		statuses = append(statuses, scoped.Status()...)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Resource < statuses[j].Resource
+	})
+	return statuses
+}
+
+// ensure registers and starts informer and waits until it has synced. When
+// the credentials may not list the resource, it returns the Forbidden error
+// as soon as the first list fails instead of waiting for ctx.
+func (c *Cache) ensure(ctx context.Context, resource string, informer cache.SharedIndexInformer) error {
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return fmt.Errorf("%s cache: stopped", resource)
+	}
+	if _, ok := c.informers[resource]; !ok {
+		c.informers[resource] = informer
+		denied := make(chan struct{})
+		c.denied[resource] = denied
+		_ = informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.errors[resource] = err
+			if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+				select {
+				case <-denied:
+				default:
+					close(denied)
+				}
+			}
+		})
+		// Only resources Subscribe serves feed the event log; others, such
+		// as Events or Secret metadata, would only push watchable deltas
+		// out of it.
+		if c.informerFor(resource) != nil {
+			_, _ = informer.AddEventHandler(c.events.handler(resource))
+		}
+		c.factory.Start(c.stop)
+		c.metadata.Start(c.stop)
+		c.dynamic.Start(c.stop)
+		if c.tls != nil {
+			c.tls.Start(c.stop)
+		}
+	}
+	denied := c.denied[resource]
+	c.mu.Unlock()
+
+	if informer.HasSynced() {
+		return nil
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	abort := make(chan struct{})
+	go func() {
+		defer close(abort)
+		select {
+		case <-ctx.Done():
+		case <-denied:
+		case <-done:
+		}
+	}()
+	if !cache.WaitForCacheSync(abort, informer.HasSynced) {
+		c.mu.Lock()
+		err := c.errors[resource]
+		c.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s cache: %w", resource, err)
+		}
+		return fmt.Errorf("%s cache: not synced", resource)
+	}
+	return nil
+}
+
+// Subscribe starts the informers for the given resources and returns their
+// deltas. When lastID is set, buffered events after it are returned for
+// replay; ErrEventsExpired means the caller must reload a full snapshot.
+func (c *Cache) Subscribe(ctx context.Context, lastID string, resources ...string) (*Subscription, error) {
+	for _, resource := range resources {
+		informer := c.informerFor(resource)
+		if informer == nil {
+			return nil, fmt.Errorf("unsupported resource %q", resource)
+		}
+		if err := c.ensure(ctx, resource, informer); err != nil {
+			return nil, err
+		}
+	}
+	return c.events.subscribe(lastID, resources)
+}
+
+// ResourceVersions reports the latest resourceVersion observed per resource.
+func (c *Cache) ResourceVersions() map[string]string {
+	return c.events.resourceVersions()
+}
+
+func (c *Cache) informerFor(resource string) cache.SharedIndexInformer {
+	switch resource {
+	case "pods":
+		return c.factory.Core().V1().Pods().Informer()
+	case "services":
+		return c.factory.Core().V1().Services().Informer()
+	case "nodes":
+		return c.factory.Core().V1().Nodes().Informer()
+	case "endpointslices":
+		return c.factory.Discovery().V1().EndpointSlices().Informer()
+	case "ingresses":
+		return c.factory.Networking().V1().Ingresses().Informer()
+	case "deployments":
+		return c.factory.Apps().V1().Deployments().Informer()
+	case "statefulsets":
+		return c.factory.Apps().V1().StatefulSets().Informer()
+	case "daemonsets":
+		return c.factory.Apps().V1().DaemonSets().Informer()
+	}
+	return nil
+}
+
+func (c *Cache) Pods(ctx context.Context) (corelisters.PodLister, error) {
+	informer := c.factory.Core().V1().Pods()
+	if err := c.ensure(ctx, "pods", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Services(ctx context.Context) (corelisters.ServiceLister, error) {
+	informer := c.factory.Core().V1().Services()
+	if err := c.ensure(ctx, "services", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Nodes(ctx context.Context) (corelisters.NodeLister, error) {
+	informer := c.factory.Core().V1().Nodes()
+	if err := c.ensure(ctx, "nodes", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Namespaces(ctx context.Context) (corelisters.NamespaceLister, error) {
+	informer := c.factory.Core().V1().Namespaces()
+	if err := c.ensure(ctx, "namespaces", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) PersistentVolumeClaims(ctx context.Context) (corelisters.PersistentVolumeClaimLister, error) {
+	informer := c.factory.Core().V1().PersistentVolumeClaims()
+	if err := c.ensure(ctx, "persistentvolumeclaims", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) PersistentVolumes(ctx context.Context) (corelisters.PersistentVolumeLister, error) {
+	informer := c.factory.Core().V1().PersistentVolumes()
+	if err := c.ensure(ctx, "persistentvolumes", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) ServiceAccounts(ctx context.Context) (corelisters.ServiceAccountLister, error) {
+	informer := c.factory.Core().V1().ServiceAccounts()
+	if err := c.ensure(ctx, "serviceaccounts", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) EndpointSlices(ctx context.Context) (discoverylisters.EndpointSliceLister, error) {
+	informer := c.factory.Discovery().V1().EndpointSlices()
+	if err := c.ensure(ctx, "endpointslices", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Ingresses(ctx context.Context) (networkinglisters.IngressLister, error) {
+	informer := c.factory.Networking().V1().Ingresses()
+	if err := c.ensure(ctx, "ingresses", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) NetworkPolicies(ctx context.Context) (networkinglisters.NetworkPolicyLister, error) {
+	informer := c.factory.Networking().V1().NetworkPolicies()
+	if err := c.ensure(ctx, "networkpolicies", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Deployments(ctx context.Context) (appslisters.DeploymentLister, error) {
+	informer := c.factory.Apps().V1().Deployments()
+	if err := c.ensure(ctx, "deployments", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) StatefulSets(ctx context.Context) (appslisters.StatefulSetLister, error) {
+	informer := c.factory.Apps().V1().StatefulSets()
+	if err := c.ensure(ctx, "statefulsets", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) DaemonSets(ctx context.Context) (appslisters.DaemonSetLister, error) {
+	informer := c.factory.Apps().V1().DaemonSets()
+	if err := c.ensure(ctx, "daemonsets", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) ReplicaSets(ctx context.Context) (appslisters.ReplicaSetLister, error) {
+	informer := c.factory.Apps().V1().ReplicaSets()
+	if err := c.ensure(ctx, "replicasets", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Jobs(ctx context.Context) (batchlisters.JobLister, error) {
+	informer := c.factory.Batch().V1().Jobs()
+	if err := c.ensure(ctx, "jobs", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) CronJobs(ctx context.Context) (batchlisters.CronJobLister, error) {
+	informer := c.factory.Batch().V1().CronJobs()
+	if err := c.ensure(ctx, "cronjobs", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) PodDisruptionBudgets(ctx context.Context) (policylisters.PodDisruptionBudgetLister, error) {
+	informer := c.factory.Policy().V1().PodDisruptionBudgets()
+	if err := c.ensure(ctx, "poddisruptionbudgets", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) Roles(ctx context.Context) (rbaclisters.RoleLister, error) {
+	informer := c.factory.Rbac().V1().Roles()
+	if err := c.ensure(ctx, "roles", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) RoleBindings(ctx context.Context) (rbaclisters.RoleBindingLister, error) {
+	informer := c.factory.Rbac().V1().RoleBindings()
+	if err := c.ensure(ctx, "rolebindings", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) ClusterRoles(ctx context.Context) (rbaclisters.ClusterRoleLister, error) {
+	informer := c.factory.Rbac().V1().ClusterRoles()
+	if err := c.ensure(ctx, "clusterroles", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) ClusterRoleBindings(ctx context.Context) (rbaclisters.ClusterRoleBindingLister, error) {
+	informer := c.factory.Rbac().V1().ClusterRoleBindings()
+	if err := c.ensure(ctx, "clusterrolebindings", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) StorageClasses(ctx context.Context) (storagelisters.StorageClassLister, error) {
+	informer := c.factory.Storage().V1().StorageClasses()
+	if err := c.ensure(ctx, "storageclasses", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) CSIDrivers(ctx context.Context) (storagelisters.CSIDriverLister, error) {
+	informer := c.factory.Storage().V1().CSIDrivers()
+	if err := c.ensure(ctx, "csidrivers", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+// Events and EventsV1 watch the same objects through the core/v1 and
+// events.k8s.io/v1 APIs.
+func (c *Cache) Events(ctx context.Context) (corelisters.EventLister, error) {
+	informer := c.factory.Core().V1().Events()
+	if err := c.ensure(ctx, "events", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func (c *Cache) EventsV1(ctx context.Context) (eventslisters.EventLister, error) {
+	informer := c.factory.Events().V1().Events()
+	if err := c.ensure(ctx, "events.events.k8s.io", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+// ConfigMapMetadata and SecretMetadata watch object metadata only, so
+// payloads are never fetched or held in memory.
+func (c *Cache) ConfigMapMetadata(ctx context.Context) (metadatalister.Lister, error) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	informer := c.metadata.ForResource(gvr)
+	if err := c.ensure(ctx, "configmaps", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return metadatalister.New(informer.Informer().GetIndexer(), gvr), nil
+}
+
+func (c *Cache) SecretMetadata(ctx context.Context) (metadatalister.Lister, error) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	informer := c.metadata.ForResource(gvr)
+	if err := c.ensure(ctx, "secrets", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return metadatalister.New(informer.Informer().GetIndexer(), gvr), nil
+}
+
+// TLSSecrets watches kubernetes.io/tls Secrets holding only tls.crt; private
+// keys and other data are dropped as objects arrive.
+func (c *Cache) TLSSecrets(ctx context.Context) (corelisters.SecretLister, error) {
+	if c.tls == nil {
+		return nil, ErrSecretsMetadataOnly
+	}
+	informer := c.tls.Core().V1().Secrets()
+	if err := c.ensure(ctx, "secrets/tls", informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
+
+func publicCertificate(obj any) (any, error) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return stripManagedFields(obj)
+	}
+	secret.ManagedFields = nil
+	delete(secret.Annotations, corev1.LastAppliedConfigAnnotation)
+	data := map[string][]byte{}
+	if crt, ok := secret.Data[corev1.TLSCertKey]; ok && secret.Type == corev1.SecretTypeTLS {
+		data[corev1.TLSCertKey] = crt
+	}
+	secret.Data = data
+	secret.StringData = nil
+	return secret, nil
+}
+
+func stripManagedFields(obj any) (any, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
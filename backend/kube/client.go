@@ -4,7 +4,10 @@ import (
 	"fmt"
 
 	"github.com/YanaDevOps/kubi/backend/config"
+	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -15,8 +18,13 @@ type Info struct {
 	ClusterURL string
 }
 
+// Client bundles the clients for one cluster. Rest is nil when the client is
+// backed by a snapshot archive rather than a live API server.
 type Client struct {
 	Clientset kubernetes.Interface
+	Metadata  metadata.Interface
+	Dynamic   dynamic.Interface
+	Ext       apiextclient.Interface
 	Rest      *rest.Config
 	Info      Info
 }
@@ -74,12 +82,7 @@ func New(cfg config.Config) (*Client, error) {
 		info.Namespace = "all"
 	}
 
-	clientset, err := kubernetes.NewForConfig(restConfig)
-	if err != nil {
-		return nil, fmt.Errorf("clientset: %w", err)
-	}
-
-	return &Client{Clientset: clientset, Rest: restConfig, Info: info}, nil
+	return newClient(restConfig, info)
 }
 
 func NewFromRaw(rawBytes []byte, context string) (*Client, error) {
@@ -116,10 +119,32 @@ func NewFromRaw(rawBytes []byte, context string) (*Client, error) {
 		info.Namespace = "all"
 	}
 
+	return newClient(restConfig, info)
+}
+
+func newClient(restConfig *rest.Config, info Info) (*Client, error) {
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return nil, fmt.Errorf("clientset: %w", err)
 	}
-
-	return &Client{Clientset: clientset, Rest: restConfig, Info: info}, nil
+	metadataClient, err := metadata.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("metadata client: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("dynamic client: %w", err)
+	}
+	extClient, err := apiextclient.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("apiext client: %w", err)
+	}
+	return &Client{
+		Clientset: clientset,
+		Metadata:  metadataClient,
+		Dynamic:   dynamicClient,
+		Ext:       extClient,
+		Rest:      restConfig,
+		Info:      info,
+	}, nil
 }
@@ -0,0 +1,71 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Discovery answers are reused for a while so CRDs installed after startup
+// are still picked up without a discovery call on every request.
+const servedResourcesTTL = time.Minute
+
+type servedGroupVersion struct {
+	resources map[string]bool
+	checked   time.Time
+}
+
+// ServedVersion returns the first of versions in which group serves resource,
+// or "" when none does, e.g. because the CRD is not installed.
+func (c *Cache) ServedVersion(group, resource string, versions ...string) (string, error) {
+	for _, version := range versions {
+		served, err := c.servedResources(schema.GroupVersion{Group: group, Version: version})
+		if err != nil {
+			return "", err
+		}
+		if served[resource] {
+			return version, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *Cache) servedResources(gv schema.GroupVersion) (map[string]bool, error) {
+	key := gv.String()
+	c.mu.Lock()
+	entry, ok := c.served[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.checked) < servedResourcesTTL {
+		return entry.resources, nil
+	}
+
+	resources := map[string]bool{}
+	list, err := c.discovery.ServerResourcesForGroupVersion(key)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("discovery %s: %w", key, err)
+	}
+	if list != nil {
+		for _, resource := range list.APIResources {
+			resources[resource.Name] = true
+		}
+	}
+
+	c.mu.Lock()
+	c.served[key] = servedGroupVersion{resources: resources, checked: time.Now()}
+	c.mu.Unlock()
+	return resources, nil
+}
+
+// Dynamic returns a lister for a custom resource. Callers should check
+// ServedVersion first; an informer for an unserved resource never syncs.
+func (c *Cache) Dynamic(ctx context.Context, gvr schema.GroupVersionResource) (cache.GenericLister, error) {
+	informer := c.dynamic.ForResource(gvr)
+	if err := c.ensure(ctx, gvr.Resource+"."+gvr.Group, informer.Informer()); err != nil {
+		return nil, err
+	}
+	return informer.Lister(), nil
+}
@@ -0,0 +1,234 @@
+package kube
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	EventAdded    = "ADDED"
+	EventModified = "MODIFIED"
+	EventDeleted  = "DELETED"
+
+	eventBufferSize     = 2048
+	subscriberQueueSize = 256
+)
+
+var ErrEventsExpired = errors.New("event history expired")
+
+type Subscription struct {
+	Replay []Event
+	Events <-chan Event
+	Cursor string
+	Cancel func()
+	// Position returns the ID of the latest event in the log once every
+	// event delivered to Events has been received, else "". Events of
+	// other resources never reach the subscription, so resuming from
+	// there skips nothing it asked for.
+	Position func() string
+}
+
+type Event struct {
+	ID              string
+	Type            string
+	Resource        string
+	Namespace       string
+	Name            string
+	Labels          map[string]string
+	ResourceVersion string
+	Object          any
+
+	seq uint64
+}
+
+// eventLog keeps a bounded history of informer deltas so that reconnecting
+// watchers can resume from the last event ID they saw. IDs are prefixed with
+// an epoch unique to this cache, which makes IDs handed out by a previous
+// cluster connection (or process) detectably stale.
+type eventLog struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	buffer      []Event
+	versions    map[string]string
+	subscribers map[chan Event]map[string]bool
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		versions:    map[string]string{},
+		subscribers: map[chan Event]map[string]bool{},
+	}
+}
+
+func (l *eventLog) handler(resource string) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj any, isInInitialList bool) {
+			if isInInitialList {
+				l.observe(resource, obj)
+				return
+			}
+			l.publish(EventAdded, resource, obj)
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldMeta, oldErr := meta.Accessor(oldObj)
+			newMeta, newErr := meta.Accessor(newObj)
+			if oldErr == nil && newErr == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				return
+			}
+			l.publish(EventModified, resource, newObj)
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			l.publish(EventDeleted, resource, obj)
+		},
+	}
+}
+
+func (l *eventLog) observe(resource string, obj any) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	l.versions[resource] = accessor.GetResourceVersion()
+	l.mu.Unlock()
+}
+
+func (l *eventLog) publish(eventType, resource string, obj any) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event := Event{
+		ID:              l.epoch + "-" + strconv.FormatUint(l.seq, 10),
+		Type:            eventType,
+		Resource:        resource,
+		Namespace:       accessor.GetNamespace(),
+		Name:            accessor.GetName(),
+		Labels:          accessor.GetLabels(),
+		ResourceVersion: accessor.GetResourceVersion(),
+		Object:          obj,
+		seq:             l.seq,
+	}
+	l.versions[resource] = event.ResourceVersion
+
+	if len(l.buffer) == eventBufferSize {
+		copy(l.buffer, l.buffer[1:])
+		l.buffer = l.buffer[:eventBufferSize-1]
+	}
+	l.buffer = append(l.buffer, event)
+
+	for ch, resources := range l.subscribers {
+		if !resources[resource] {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			// A watcher that cannot keep up is disconnected; it resumes
+			// from its last event ID when it reconnects.
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe delivers the events of resources only.
+func (l *eventLog) subscribe(lastID string, resources []string) (*Subscription, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	wanted := map[string]bool{}
+	for _, resource := range resources {
+		wanted[resource] = true
+	}
+
+	var replay []Event
+	if lastID != "" {
+		seq, err := l.parseID(lastID)
+		if err != nil {
+			return nil, err
+		}
+		if len(l.buffer) > 0 && seq+1 < l.buffer[0].seq {
+			return nil, ErrEventsExpired
+		}
+		for _, event := range l.buffer {
+			if event.seq > seq && wanted[event.Resource] {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberQueueSize)
+	l.subscribers[ch] = wanted
+	cancel := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subscribers[ch]; ok {
+			delete(l.subscribers, ch)
+			close(ch)
+		}
+	}
+	position := func() string {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(ch) > 0 {
+			return ""
+		}
+		return l.epoch + "-" + strconv.FormatUint(l.seq, 10)
+	}
+	return &Subscription{
+		Replay:   replay,
+		Events:   ch,
+		Cursor:   l.epoch + "-" + strconv.FormatUint(l.seq, 10),
+		Cancel:   cancel,
+		Position: position,
+	}, nil
+}
+
+func (l *eventLog) parseID(id string) (uint64, error) {
+	epoch, seqText, ok := strings.Cut(id, "-")
+	if !ok || epoch != l.epoch {
+		return 0, ErrEventsExpired
+	}
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || seq > l.seq {
+		return 0, ErrEventsExpired
+	}
+	return seq, nil
+}
+
+func (l *eventLog) resourceVersions() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	versions := make(map[string]string, len(l.versions))
+	for resource, version := range l.versions {
+		versions[resource] = version
+	}
+	return versions
+}
+
+func (l *eventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		delete(l.subscribers, ch)
+		close(ch)
+	}
+}
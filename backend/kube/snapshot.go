@@ -0,0 +1,584 @@
+package kube
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// A snapshot archive is a gzipped tar with a manifest and one JSON array of
+// objects per resource, so a support engineer can inspect a cluster they
+// cannot reach.
+const (
+	snapshotFormat   = 1
+	snapshotManifest = "manifest.json"
+	snapshotPageSize = 500
+)
+
+// lastAppliedAnnotation holds the full object as applied by kubectl, which
+// for Secrets includes their data.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+type SnapshotManifest struct {
+	Format        int                `json:"format"`
+	Created       time.Time          `json:"created"`
+	Context       string             `json:"context"`
+	ClusterURL    string             `json:"clusterURL"`
+	Namespace     string             `json:"namespace,omitempty"`
+	ServerVersion *version.Info      `json:"serverVersion,omitempty"`
+	Resources     []SnapshotResource `json:"resources"`
+}
+
+// SnapshotResource describes one captured resource. Error is set when the
+// resource could not be listed, e.g. for lack of RBAC permissions.
+type SnapshotResource struct {
+	Group      string `json:"group,omitempty"`
+	Version    string `json:"version"`
+	Resource   string `json:"resource"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+	Items      int    `json:"items"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r SnapshotResource) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+}
+
+func (r SnapshotResource) path() string {
+	group := r.Group
+	if group == "" {
+		group = "core"
+	}
+	return path.Join("resources", group, r.Version, r.Resource+".json")
+}
+
+type snapshotSource struct {
+	gvr schema.GroupVersionResource
+	// optional resources are skipped when discovery fails, as aggregated
+	// APIs such as metrics often do.
+	optional bool
+}
+
+// snapshotSources are the built-in resources KUBI reads. Custom resources
+// are added from the cluster's CRDs.
+var snapshotSources = []snapshotSource{
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "nodes"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "services"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumes"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "events"}},
+	{gvr: schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"}},
+	{gvr: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}},
+	{gvr: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}},
+	{gvr: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}},
+	{gvr: schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}},
+	{gvr: schema.GroupVersionResource{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"}},
+	{gvr: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"}},
+	{gvr: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"}},
+	{gvr: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}},
+	{gvr: schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"}},
+	{gvr: schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}},
+	{gvr: schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "csidrivers"}},
+	{gvr: schema.GroupVersionResource{Group: apiextv1.GroupName, Version: "v1", Resource: "customresourcedefinitions"}},
+	{gvr: schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "nodes"}, optional: true},
+	{gvr: schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}, optional: true},
+}
+
+// metadataOnly resources are captured and served like the cache watches
+// them: without their payload.
+func metadataOnly(gvr schema.GroupVersionResource) bool {
+	return gvr.Group == "" && (gvr.Resource == "configmaps" || gvr.Resource == "secrets")
+}
+
+// WriteSnapshot captures the resources KUBI reads into w. A non-empty
+// namespace limits namespaced resources to it. Resources the client may not
+// list are recorded in the manifest with their error instead of failing the
+// snapshot.
+func WriteSnapshot(ctx context.Context, client *Client, namespace string, w io.Writer) (SnapshotManifest, error) {
+	manifest := SnapshotManifest{
+		Format:     snapshotFormat,
+		Created:    time.Now().UTC(),
+		Context:    client.Info.Context,
+		ClusterURL: client.Info.ClusterURL,
+		Namespace:  namespace,
+		Resources:  []SnapshotResource{},
+	}
+	if info, err := client.Clientset.Discovery().ServerVersion(); err == nil {
+		manifest.ServerVersion = info
+	}
+
+	lists, err := ListObjects(ctx, client, namespace, false)
+	if err != nil {
+		return manifest, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, list := range lists {
+		if err := writeSnapshotFile(tw, list.path(), list.Objects, manifest.Created); err != nil {
+			return manifest, err
+		}
+		manifest.Resources = append(manifest.Resources, list.SnapshotResource)
+	}
+	if err := writeSnapshotFile(tw, snapshotManifest, manifest, manifest.Created); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+// snapshotResources resolves kind and scope of the built-in sources through
+// discovery, skipping those the cluster does not serve, and adds every
+// served version of every CRD.
+func snapshotResources(ctx context.Context, client *Client) ([]SnapshotResource, error) {
+	discovered := map[string]*v1.APIResourceList{}
+	resources := []SnapshotResource{}
+	for _, source := range snapshotSources {
+		gv := source.gvr.GroupVersion().String()
+		list, ok := discovered[gv]
+		if !ok {
+			var err error
+			list, err = client.Clientset.Discovery().ServerResourcesForGroupVersion(gv)
+			if err != nil && !apierrors.IsNotFound(err) && !source.optional {
+				return nil, fmt.Errorf("discovery %s: %w", gv, err)
+			}
+			discovered[gv] = list
+		}
+		if list == nil {
+			continue
+		}
+		for _, api := range list.APIResources {
+			if api.Name == source.gvr.Resource {
+				resources = append(resources, SnapshotResource{
+					Group:      source.gvr.Group,
+					Version:    source.gvr.Version,
+					Resource:   source.gvr.Resource,
+					Kind:       api.Kind,
+					Namespaced: api.Namespaced,
+				})
+				break
+			}
+		}
+	}
+
+	crds, err := client.Ext.ApiextensionsV1().CustomResourceDefinitions().List(ctx, v1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return resources, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list customresourcedefinitions: %w", err)
+	}
+	for _, crd := range crds.Items {
+		for _, v := range crd.Spec.Versions {
+			if !v.Served {
+				continue
+			}
+			resources = append(resources, SnapshotResource{
+				Group:      crd.Spec.Group,
+				Version:    v.Name,
+				Resource:   crd.Spec.Names.Plural,
+				Kind:       crd.Spec.Names.Kind,
+				Namespaced: crd.Spec.Scope == apiextv1.NamespaceScoped,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// ResourceObjects are the objects of one resource, as returned by
+// ListObjects.
+type ResourceObjects struct {
+	SnapshotResource
+	Objects []map[string]any
+}
+
+// ListObjects lists every resource WriteSnapshot captures, with the same
+// namespace filter. Unless withData is set, ConfigMaps and Secrets are
+// listed as metadata only, as they are captured. With it, ConfigMaps keep
+// their data and every Secret value is replaced by a hash, so data can be
+// compared without holding any value. Resources the client may not list
+// are returned with Error set.
+func ListObjects(ctx context.Context, client *Client, namespace string, withData bool) ([]ResourceObjects, error) {
+	resources, err := snapshotResources(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	lists := make([]ResourceObjects, 0, len(resources))
+	for _, resource := range resources {
+		items, err := listSnapshotItems(ctx, client, resource, namespace, withData)
+		if apierrors.IsForbidden(err) {
+			resource.Error = err.Error()
+		} else if err != nil {
+			return nil, fmt.Errorf("list %s: %w", resource.gvr().GroupResource(), err)
+		}
+		resource.Items = len(items)
+		lists = append(lists, ResourceObjects{SnapshotResource: resource, Objects: items})
+	}
+	return lists, nil
+}
+
+func listSnapshotItems(ctx context.Context, client *Client, resource SnapshotResource, namespace string, withData bool) ([]map[string]any, error) {
+	if !resource.Namespaced {
+		namespace = ""
+	}
+	items := []map[string]any{}
+	opts := v1.ListOptions{Limit: snapshotPageSize}
+	for {
+		var next string
+		if metadataOnly(resource.gvr()) && !withData {
+			list, err := client.Metadata.Resource(resource.gvr()).Namespace(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				item := &list.Items[i]
+				item.APIVersion = resource.gvr().GroupVersion().String()
+				item.Kind = resource.Kind
+				item.ManagedFields = nil
+				delete(item.Annotations, lastAppliedAnnotation)
+				object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, object)
+			}
+			next = list.Continue
+		} else {
+			list, err := client.Dynamic.Resource(resource.gvr()).Namespace(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range list.Items {
+				unstructured.RemoveNestedField(item.Object, "metadata", "managedFields")
+				if metadataOnly(resource.gvr()) {
+					unstructured.RemoveNestedField(item.Object, "metadata", "annotations", lastAppliedAnnotation)
+				}
+				if resource.gvr() == secretsResource {
+					hashSecretData(item.Object)
+				}
+				items = append(items, item.Object)
+			}
+			next = list.GetContinue()
+		}
+		if next == "" {
+			return items, nil
+		}
+		opts.Continue = next
+	}
+}
+
+var secretsResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// secretDataKey keys the hashes of Secret values. It is drawn per process,
+// so hashes compare within one run but cannot be matched against guessed
+// values later.
+var secretDataKey = func() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+// hashSecretData replaces every value under data with a keyed hash of it.
+func hashSecretData(object map[string]any) {
+	data, _ := object["data"].(map[string]any)
+	for key, value := range data {
+		encoded, _ := value.(string)
+		mac := hmac.New(sha256.New, secretDataKey)
+		mac.Write([]byte(encoded))
+		data[key] = "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	}
+	delete(object, "stringData")
+}
+
+func writeSnapshotFile(tw *tar.Writer, name string, value any, modTime time.Time) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// LoadSnapshot returns a client whose clientset, metadata, dynamic and
+// apiextensions clients are fakes serving the archive at path. The objects
+// never change, so informers sync once and see no further events.
+func LoadSnapshot(path, namespace string) (*Client, error) {
+	files, err := readSnapshotFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest SnapshotManifest
+	data, ok := files[snapshotManifest]
+	if !ok {
+		return nil, fmt.Errorf("snapshot %s: no %s", path, snapshotManifest)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	if manifest.Format != snapshotFormat {
+		return nil, fmt.Errorf("snapshot %s: unsupported format %d", path, manifest.Format)
+	}
+
+	clientset := kubefake.NewSimpleClientset()
+	extClient := apiextfake.NewSimpleClientset()
+	metadataObjects := []runtime.Object{}
+	dynamicObjects := map[schema.GroupVersionResource][]*unstructured.Unstructured{}
+	listKinds := map[schema.GroupVersionResource]string{}
+	discovered := map[string]*v1.APIResourceList{}
+	failed := []SnapshotResource{}
+
+	for _, resource := range manifest.Resources {
+		gvr := resource.gvr()
+		gv := gvr.GroupVersion().String()
+		if discovered[gv] == nil {
+			discovered[gv] = &v1.APIResourceList{GroupVersion: gv}
+		}
+		discovered[gv].APIResources = append(discovered[gv].APIResources, v1.APIResource{
+			Name:       resource.Resource,
+			Kind:       resource.Kind,
+			Namespaced: resource.Namespaced,
+			Verbs:      v1.Verbs{"get", "list", "watch"},
+		})
+
+		typed, typedErr := scheme.Scheme.New(gvr.GroupVersion().WithKind(resource.Kind))
+		if resource.Error != "" {
+			if typedErr != nil && !metadataOnly(gvr) && gvr.Group != apiextv1.GroupName {
+				listKinds[gvr] = resource.Kind + "List"
+			}
+			failed = append(failed, resource)
+			continue
+		}
+
+		var items []json.RawMessage
+		if data, ok := files[resource.path()]; ok {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, fmt.Errorf("snapshot %s: %s: %w", path, resource.path(), err)
+			}
+		}
+
+		switch {
+		case metadataOnly(gvr):
+			for _, raw := range items {
+				object := &v1.PartialObjectMetadata{}
+				if err := json.Unmarshal(raw, object); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %s: %w", path, resource.path(), err)
+				}
+				metadataObjects = append(metadataObjects, object)
+			}
+		case gvr.Group == apiextv1.GroupName:
+			for _, raw := range items {
+				object := &apiextv1.CustomResourceDefinition{}
+				if err := json.Unmarshal(raw, object); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %s: %w", path, resource.path(), err)
+				}
+				if err := extClient.Tracker().Create(gvr, object, ""); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %w", path, err)
+				}
+			}
+		case typedErr == nil:
+			for _, raw := range items {
+				object := typed.DeepCopyObject()
+				if err := json.Unmarshal(raw, object); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %s: %w", path, resource.path(), err)
+				}
+				accessor, err := meta.Accessor(object)
+				if err != nil {
+					return nil, fmt.Errorf("snapshot %s: %w", path, err)
+				}
+				if err := clientset.Tracker().Create(gvr, object, accessor.GetNamespace()); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %w", path, err)
+				}
+			}
+		default:
+			listKinds[gvr] = resource.Kind + "List"
+			for _, raw := range items {
+				object := &unstructured.Unstructured{}
+				if err := object.UnmarshalJSON(raw); err != nil {
+					return nil, fmt.Errorf("snapshot %s: %s: %w", path, resource.path(), err)
+				}
+				dynamicObjects[gvr] = append(dynamicObjects[gvr], object)
+			}
+		}
+	}
+
+	for _, list := range discovered {
+		clientset.Resources = append(clientset.Resources, list)
+	}
+	if manifest.ServerVersion != nil {
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = manifest.ServerVersion
+	}
+
+	metadataScheme := metadatafake.NewTestScheme()
+	if err := v1.AddMetaToScheme(metadataScheme); err != nil {
+		return nil, err
+	}
+	metadataClient := metadatafake.NewSimpleMetadataClient(metadataScheme, metadataObjects...)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	for gvr, objects := range dynamicObjects {
+		for _, object := range objects {
+			if err := dynamicClient.Tracker().Create(gvr, object, object.GetNamespace()); err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", path, err)
+			}
+		}
+	}
+
+	// Resources the capture could not list fail the same way on replay, so
+	// they read as not captured rather than as empty.
+	for _, resource := range failed {
+		gvr := resource.gvr()
+		reactor := capturedErrorReactor(resource)
+		switch {
+		case metadataOnly(gvr):
+			metadataClient.PrependReactor("*", resource.Resource, reactor)
+		case gvr.Group == apiextv1.GroupName:
+			extClient.PrependReactor("*", resource.Resource, reactor)
+		case scheme.Scheme.Recognizes(gvr.GroupVersion().WithKind(resource.Kind)):
+			clientset.PrependReactor("*", resource.Resource, reactor)
+		default:
+			dynamicClient.PrependReactor("*", resource.Resource, reactor)
+		}
+	}
+
+	if namespace == "" {
+		namespace = manifest.Namespace
+	}
+	if namespace == "" {
+		namespace = "all"
+	}
+	return &Client{
+		Clientset: clientset,
+		Metadata:  metadataClient,
+		Dynamic:   snapshotDynamic{FakeDynamicClient: dynamicClient, listKinds: listKinds},
+		Ext:       extClient,
+		Info:      Info{Context: manifest.Context, Namespace: namespace, ClusterURL: manifest.ClusterURL},
+	}, nil
+}
+
+// capturedErrorReactor fails every request for resource with the error its
+// capture recorded. Captures only record Forbidden errors.
+func capturedErrorReactor(resource SnapshotResource) clienttesting.ReactionFunc {
+	gvr := resource.gvr()
+	err := &apierrors.StatusError{ErrStatus: v1.Status{
+		Status:  v1.StatusFailure,
+		Code:    http.StatusForbidden,
+		Reason:  v1.StatusReasonForbidden,
+		Message: "not captured in the snapshot: " + resource.Error,
+	}}
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return action.GetResource() == gvr, nil, err
+	}
+}
+
+func readSnapshotFiles(path string) (map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", path, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", path, err)
+		}
+		files[header.Name] = data
+	}
+}
+
+// snapshotDynamic answers for resources missing from the archive with
+// NotFound, as a cluster without them would; the fake client panics instead.
+type snapshotDynamic struct {
+	*dynamicfake.FakeDynamicClient
+	listKinds map[schema.GroupVersionResource]string
+}
+
+func (d snapshotDynamic) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	if _, ok := d.listKinds[gvr]; ok {
+		return d.FakeDynamicClient.Resource(gvr)
+	}
+	return missingResource{gvr: gvr}
+}
+
+// missingResource implements only the read methods; KUBI never writes.
+type missingResource struct {
+	dynamic.NamespaceableResourceInterface
+	gvr schema.GroupVersionResource
+}
+
+func (m missingResource) Namespace(string) dynamic.ResourceInterface {
+	return m
+}
+
+func (m missingResource) Get(_ context.Context, name string, _ v1.GetOptions, _ ...string) (*unstructured.Unstructured, error) {
+	return nil, apierrors.NewNotFound(m.gvr.GroupResource(), name)
+}
+
+func (m missingResource) List(context.Context, v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return nil, apierrors.NewNotFound(m.gvr.GroupResource(), "")
+}
+
+func (m missingResource) Watch(context.Context, v1.ListOptions) (watch.Interface, error) {
+	return nil, apierrors.NewNotFound(m.gvr.GroupResource(), "")
+}
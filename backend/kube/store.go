@@ -3,12 +3,12 @@ package kube
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
 
 	"github.com/YanaDevOps/kubi/backend/config"
-	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 )
@@ -20,14 +20,26 @@ type Store struct {
 	context  string
 	hash     string
 	client   *Client
-	ext      apiextclient.Interface
+	cache    *Cache
+	clusters map[string]*cluster
 }
 
+// cluster is a connection to a context other than the current one.
+type cluster struct {
+	client *Client
+	cache  *Cache
+}
+
+var errSnapshotMode = errors.New("serving a snapshot: kubeconfig contexts are not available")
+
 func NewStore(cfg config.Config) *Store {
 	return &Store{cfg: cfg}
 }
 
 func (s *Store) SetKubeconfig(raw []byte, context string) ([]string, string, error) {
+	if s.cfg.Snapshot != "" {
+		return nil, "", errSnapshotMode
+	}
 	parsed, err := clientcmd.Load(raw)
 	if err != nil {
 		return nil, "", fmt.Errorf("parse kubeconfig: %w", err)
@@ -48,29 +60,62 @@ func (s *Store) SetKubeconfig(raw []byte, context string) ([]string, string, err
 	s.context = context
 	s.hash = ""
 	s.client = nil
-	s.ext = nil
+	oldCache := s.cache
+	s.cache = nil
+	oldClusters := s.clusters
+	s.clusters = nil
 	s.mu.Unlock()
 
+	if oldCache != nil {
+		oldCache.Stop()
+	}
+	for _, c := range oldClusters {
+		if c.cache != nil {
+			c.cache.Stop()
+		}
+	}
+
 	return contexts, context, nil
 }
 
 func (s *Store) SetContext(context string) error {
+	if s.cfg.Snapshot != "" {
+		return errSnapshotMode
+	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	if len(s.rawBytes) == 0 {
+		s.mu.Unlock()
 		return fmt.Errorf("no kubeconfig loaded")
 	}
 	parsed, err := clientcmd.Load(s.rawBytes)
 	if err != nil {
+		s.mu.Unlock()
 		return fmt.Errorf("parse kubeconfig: %w", err)
 	}
 	if _, ok := parsed.Contexts[context]; !ok {
+		s.mu.Unlock()
 		return fmt.Errorf("context %q not found in kubeconfig", context)
 	}
 	s.context = context
 	s.hash = ""
 	s.client = nil
-	s.ext = nil
+	oldCache := s.cache
+	s.cache = nil
+	// The new current context is served by Client and Cache from now on,
+	// so a connection of its own would never be used again.
+	var oldCluster *cluster
+	if c, ok := s.clusters[context]; ok {
+		oldCluster = c
+		delete(s.clusters, context)
+	}
+	s.mu.Unlock()
+
+	if oldCache != nil {
+		oldCache.Stop()
+	}
+	if oldCluster != nil && oldCluster.cache != nil {
+		oldCluster.cache.Stop()
+	}
 	return nil
 }
 
@@ -81,6 +126,14 @@ func (s *Store) Contexts() ([]string, string, error) {
 	cfg := s.cfg
 	s.mu.RUnlock()
 
+	if cfg.Snapshot != "" {
+		client, err := s.Client()
+		if err != nil {
+			return nil, "", err
+		}
+		return []string{client.Info.Context}, client.Info.Context, nil
+	}
+
 	if len(raw) > 0 {
 		parsed, err := clientcmd.Load(raw)
 		if err != nil {
@@ -131,6 +184,8 @@ func (s *Store) Client() (*Client, error) {
 	var err error
 	if len(s.rawBytes) > 0 {
 		newClient, err = NewFromRaw(s.rawBytes, s.context)
+	} else if s.cfg.Snapshot != "" {
+		newClient, err = LoadSnapshot(s.cfg.Snapshot, s.cfg.Namespace)
 	} else {
 		newClient, err = New(s.cfg)
 	}
@@ -138,39 +193,149 @@ func (s *Store) Client() (*Client, error) {
 		return nil, err
 	}
 
+	if s.cache != nil {
+		go s.cache.Stop()
+		s.cache = nil
+	}
 	s.client = newClient
-	s.ext = nil
 	s.hash = currentHash
 
 	return newClient, nil
 }
 
-func (s *Store) ExtClient() (apiextclient.Interface, error) {
-	client, err := s.Client()
+// ClientFor returns the client for a context of the kubeconfig in use. An
+// empty or the current context is served by Client; other contexts get a
+// client of their own, kept until the kubeconfig changes.
+func (s *Store) ClientFor(context string) (*Client, error) {
+	if context == "" {
+		return s.Client()
+	}
+	if current, err := s.Client(); err == nil && current.Info.Context == context {
+		return current, nil
+	}
+	if s.cfg.Snapshot != "" {
+		return nil, errSnapshotMode
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clusters[context]; ok {
+		return c.client, nil
+	}
+	client, err := s.newContextClient(context)
 	if err != nil {
 		return nil, err
 	}
+	if s.clusters == nil {
+		s.clusters = map[string]*cluster{}
+	}
+	s.clusters[context] = &cluster{client: client}
+	return client, nil
+}
+
+// CacheFor is Cache for the context's client.
+func (s *Store) CacheFor(context string) (*Cache, error) {
+	client, err := s.ClientFor(context)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	c, ok := s.clusters[context]
+	if !ok || c.client != client {
+		s.mu.Unlock()
+		return s.Cache()
+	}
+	if c.cache == nil {
+		c.cache = NewCache(client.Clientset, client.Metadata, client.Dynamic, !s.cfg.SecretsMetadataOnly)
+	}
+	cache := c.cache
+	s.mu.Unlock()
+	return cache, nil
+}
 
+// CacheStatusFor is CacheStatus for the context's cache.
+func (s *Store) CacheStatusFor(context string) []ResourceStatus {
 	s.mu.RLock()
-	if s.ext != nil {
-		ext := s.ext
-		s.mu.RUnlock()
-		return ext, nil
+	var cache *Cache
+	c, ok := s.clusters[context]
+	if ok {
+		cache = c.cache
 	}
+	current := s.client
 	s.mu.RUnlock()
 
+	if !ok || (current != nil && current.Info.Context == context) {
+		return s.CacheStatus()
+	}
+	if cache == nil {
+		return nil
+	}
+	return cache.Status()
+}
+
+// Clusters returns the contexts aggregate endpoints fan out to: the
+// configured clusters, or else the current context.
+func (s *Store) Clusters() ([]string, error) {
+	if len(s.cfg.Clusters) > 0 {
+		return append([]string{}, s.cfg.Clusters...), nil
+	}
+	client, err := s.Client()
+	if err != nil {
+		return nil, err
+	}
+	return []string{client.Info.Context}, nil
+}
+
+// newContextClient must be called with s.mu held.
+func (s *Store) newContextClient(context string) (*Client, error) {
+	if len(s.rawBytes) > 0 {
+		return NewFromRaw(s.rawBytes, context)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if s.cfg.Kubeconfig != "" {
+		loadingRules.ExplicitPath = s.cfg.Kubeconfig
+	}
+	parsed, err := loadingRules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load kubeconfig: %w", err)
+	}
+	if _, ok := parsed.Contexts[context]; !ok {
+		return nil, fmt.Errorf("context %q not found in kubeconfig", context)
+	}
+	cfg := s.cfg
+	cfg.Context = context
+	cfg.Namespace = ""
+	return New(cfg)
+}
+
+func (s *Store) Cache() (*Cache, error) {
+	client, err := s.Client()
+	if err != nil {
+		return nil, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.ext != nil {
-		return s.ext, nil
+	if s.client != client {
+		return nil, fmt.Errorf("cluster connection changed, retry the request")
+	}
+	if s.cache == nil {
+		s.cache = NewCache(client.Clientset, client.Metadata, client.Dynamic, !s.cfg.SecretsMetadataOnly)
 	}
+	return s.cache, nil
+}
 
-	extClient, err := apiextclient.NewForConfig(client.Rest)
-	if err != nil {
-		return nil, fmt.Errorf("apiext client: %w", err)
+func (s *Store) CacheStatus() []ResourceStatus {
+	s.mu.RLock()
+	cache := s.cache
+	s.mu.RUnlock()
+
+	if cache == nil {
+		return nil
 	}
-	s.ext = extClient
-	return extClient, nil
+	return cache.Status()
 }
 
 func configHash(raw []byte, context string) string {
@@ -11,11 +11,13 @@ import (
 	apiextclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 )
 
-func NewRouter(cfg config.Config, version string, started time.Time, store *kube.Store) http.Handler {
+func NewRouter(cfg config.Config, version string, started time.Time, store *kube.Store, engine *api.ValidationEngine) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", Healthz)
 
+	topologyHistory := api.NewTopologyHistory(32)
+
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("/kubeconfig", api.KubeconfigSaveHandler(store))
 	apiMux.HandleFunc("/kubeconfig/contexts", api.KubeconfigContextsHandler(store))
@@ -23,71 +25,125 @@ func NewRouter(cfg config.Config, version string, started time.Time, store *kube
 
 	readonlyMux := http.NewServeMux()
 	readonlyMux.HandleFunc("/health", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.HealthHandler(version, started, client.Info.Namespace, client.Info.Context)
+		return api.HealthHandler(version, started, client.Info.Namespace, client.Info.Context, store.CacheStatusFor(client.Info.Context))
 	}))
-	readonlyMux.HandleFunc("/overview", withClient(store, func(client *kube.Client) http.HandlerFunc {
+	overview := withClient(store, func(client *kube.Client) http.HandlerFunc {
 		return api.OverviewHandler(version, client.Info.Namespace, client.Info.Context, client.Info.ClusterURL, true)
-	}))
+	})
+	readonlyMux.HandleFunc("/overview", overview)
 	readonlyMux.HandleFunc("/version", api.VersionHandler(version))
-	readonlyMux.HandleFunc("/namespaces", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.NamespacesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/namespaces", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.NamespacesHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/nodes", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.NodesHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/topology", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.TopologyHandler(cache, topologyHistory)
 	}))
-	readonlyMux.HandleFunc("/nodes", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.NodesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/topology/delta", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.TopologyDeltaHandler(cache, topologyHistory)
 	}))
-	readonlyMux.HandleFunc("/topology", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.TopologyHandler(client.Clientset)
+	readonlyMux.HandleFunc("/workloads", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.WorkloadsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/workloads", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.WorkloadsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/pods", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.PodsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/pods", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.PodsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/pods/detail", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.PodDetailHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/ports", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.PortsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/pods/logs", withCacheClient(store, func(cache *kube.Cache, client *kube.Client) http.HandlerFunc {
+		if client.Rest == nil {
+			return api.SnapshotLogsHandler()
+		}
+		return api.PodLogsHandler(cache, client.Clientset)
+	}))
+	readonlyMux.HandleFunc("/workloads/logs", withCacheClient(store, func(cache *kube.Cache, client *kube.Client) http.HandlerFunc {
+		if client.Rest == nil {
+			return api.SnapshotLogsHandler()
+		}
+		return api.WorkloadLogsHandler(cache, client.Clientset)
 	}))
-	readonlyMux.HandleFunc("/services", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.ServicesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/events", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.EventsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/endpointslices", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.EndpointSlicesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/events/timeline", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.EventTimelineHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/roles", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.RolesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/ports", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.PortsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/rolebindings", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.RoleBindingsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/services", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ServicesHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/clusterroles", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.ClusterRolesHandler(client.Clientset)
+	readonlyMux.HandleFunc("/endpointslices", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.EndpointSlicesHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/clusterrolebindings", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.ClusterRoleBindingsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/rbac/roles", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.RolesHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/serviceaccounts", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.ServiceAccountsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/rbac/rolebindings", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.RoleBindingsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/rbac/effective", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.EffectivePermissionsHandler(client.Clientset)
+	readonlyMux.HandleFunc("/rbac/clusterroles", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ClusterRolesHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/storage", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.StorageHandler(client.Clientset)
+	readonlyMux.HandleFunc("/rbac/clusterrolebindings", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ClusterRoleBindingsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/validation", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.ValidationHandler(client.Clientset)
+	readonlyMux.HandleFunc("/rbac/serviceaccounts", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ServiceAccountsHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/inventory", withClientExt(store, func(client *kube.Client, extClient apiextclient.Interface) http.HandlerFunc {
-		return api.InventoryHandler(client.Clientset, extClient)
+	readonlyMux.HandleFunc("/rbac/effective", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.EffectivePermissionsHandler(cache)
 	}))
+	readonlyMux.HandleFunc("/security/pod-security", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.PodSecurityHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/secrets", withCacheClient(store, func(cache *kube.Cache, client *kube.Client) http.HandlerFunc {
+		return api.SecretsHandler(cache, client.Clientset, cfg.SecretsMetadataOnly)
+	}))
+	readonlyMux.HandleFunc("/secrets/detail", withCacheClient(store, func(cache *kube.Cache, client *kube.Client) http.HandlerFunc {
+		return api.SecretDetailHandler(cache, client.Clientset, cfg.SecretsMetadataOnly, cfg.AllowSecretValues)
+	}))
+	readonlyMux.HandleFunc("/certificates", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.CertificatesHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/storage", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.StorageHandler(cache)
+	}))
+	validation := withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ValidationHandler(cache, engine, version)
+	})
+	readonlyMux.HandleFunc("/validation", validation)
+	readonlyMux.HandleFunc("/validation/rules", api.ValidationRulesHandler(engine))
+	inventory := withCacheExt(store, func(cache *kube.Cache, extClient apiextclient.Interface) http.HandlerFunc {
+		return api.InventoryHandler(cache, extClient)
+	})
+	readonlyMux.HandleFunc("/inventory", inventory)
 	readonlyMux.HandleFunc("/crds/objects", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.CRDObjectsHandler(client.Rest)
+		return api.CRDObjectsHandler(client.Dynamic)
+	}))
+	readonlyMux.HandleFunc("/traffic", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.TrafficHandler(cache)
 	}))
-	readonlyMux.HandleFunc("/traffic", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.TrafficHandler(client.Clientset)
+	readonlyMux.HandleFunc("/traffic/reachability", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.ReachabilityHandler(cache)
 	}))
+	readonlyMux.HandleFunc("/traffic/matrix", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.TrafficMatrixHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/watch", withCache(store, func(cache *kube.Cache) http.HandlerFunc {
+		return api.WatchHandler(cache)
+	}))
+	readonlyMux.HandleFunc("/diff", api.DiffHandler(store))
+	readonlyMux.HandleFunc("/clusters", api.ClustersHandler(store))
+	readonlyMux.HandleFunc("/clusters/overview", api.FanOutHandler(store, overview))
+	readonlyMux.HandleFunc("/clusters/validation", api.FanOutHandler(store, validation))
+	readonlyMux.HandleFunc("/clusters/inventory", api.FanOutHandler(store, inventory))
 	readonlyMux.HandleFunc("/metrics", withClient(store, func(client *kube.Client) http.HandlerFunc {
-		return api.MetricsHandler(client.Rest)
+		return api.MetricsHandler(client.Dynamic)
 	}))
 
 	apiMux.Handle("/", middleware.Readonly(readonlyMux))
@@ -99,9 +155,11 @@ func NewRouter(cfg config.Config, version string, started time.Time, store *kube
 	return mux
 }
 
+// withClient, withCache, withCacheClient and withCacheExt resolve the cluster parameter, a
+// kubeconfig context, defaulting to the current one.
 func withClient(store *kube.Store, handler func(*kube.Client) http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		client, err := store.Client()
+		client, err := store.ClientFor(r.URL.Query().Get("cluster"))
 		if err != nil {
 			api.WriteError(w, http.StatusServiceUnavailable, err)
 			return
@@ -110,18 +168,35 @@ func withClient(store *kube.Store, handler func(*kube.Client) http.HandlerFunc)
 	}
 }
 
-func withClientExt(store *kube.Store, handler func(*kube.Client, apiextclient.Interface) http.HandlerFunc) http.HandlerFunc {
+func withCache(store *kube.Store, handler func(*kube.Cache) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cache, err := store.CacheFor(r.URL.Query().Get("cluster"))
+		if err != nil {
+			api.WriteError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		handler(cache)(w, r)
+	}
+}
+
+func withCacheClient(store *kube.Store, handler func(*kube.Cache, *kube.Client) http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		client, err := store.Client()
+		cache, err := store.CacheFor(r.URL.Query().Get("cluster"))
 		if err != nil {
 			api.WriteError(w, http.StatusServiceUnavailable, err)
 			return
 		}
-		ext, err := store.ExtClient()
+		client, err := store.ClientFor(r.URL.Query().Get("cluster"))
 		if err != nil {
 			api.WriteError(w, http.StatusServiceUnavailable, err)
 			return
 		}
-		handler(client, ext)(w, r)
+		handler(cache, client)(w, r)
 	}
 }
+
+func withCacheExt(store *kube.Store, handler func(*kube.Cache, apiextclient.Interface) http.HandlerFunc) http.HandlerFunc {
+	return withCacheClient(store, func(cache *kube.Cache, client *kube.Client) http.HandlerFunc {
+		return handler(cache, client.Ext)
+	})
+}
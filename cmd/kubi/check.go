@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/api"
+	"github.com/YanaDevOps/kubi/backend/config"
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+// Exit codes of a headless validation run.
+const (
+	exitPass     = 0
+	exitFindings = 1
+	exitError    = 2
+)
+
+const (
+	validateTimeout = 2 * time.Minute
+
+	// formatTable is the human-readable output of kubi check; the API only
+	// serves the machine-readable formats.
+	formatTable = "table"
+)
+
+// runCheckCommand handles `kubi check [flags]`. It takes the same flags as
+// the server, so kubeconfig, context, namespace and validation config
+// resolve exactly as they do for the UI.
+func runCheckCommand(args []string) int {
+	var output, failOn string
+	cfg, err := config.ParseArgs(os.Args[0]+" check", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&output, "output", formatTable, "output format: table, json, sarif, junit or jsonl")
+		fs.StringVar(&output, "o", formatTable, "shorthand for --output")
+		fs.StringVar(&failOn, "fail-on", api.SeverityWarning, "exit 1 on findings at or above this severity: critical, warning, info or none")
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	engine, err := newValidationEngine(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	return runCheck(cfg, engine, output, failOn)
+}
+
+// runCheck runs the validation once against the configured cluster and
+// writes the report to stdout. Errors go to stderr and exit with exitError,
+// so CI can tell a broken run from a failing one.
+func runCheck(cfg config.Config, engine *api.ValidationEngine, format, failOn string) int {
+	if format != formatTable && !api.ValidFormat(format) {
+		fmt.Fprintf(os.Stderr, "kubi: invalid format %q: must be table, json, sarif, junit or jsonl\n", format)
+		return exitError
+	}
+	if failOn == "none" {
+		failOn = ""
+	}
+	if failOn != "" && failOn != api.SeverityCritical && failOn != api.SeverityWarning && failOn != api.SeverityInfo {
+		fmt.Fprintf(os.Stderr, "kubi: invalid --fail-on %q: must be critical, warning, info or none\n", failOn)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+
+	store := kube.NewStore(cfg)
+	cache, err := store.Cache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	defer cache.Stop()
+
+	snapshot, err := api.CollectValidationSnapshot(ctx, cache, cfg.Namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+
+	report := api.ValidationReport{Version: version, Rules: engine.Rules(), Items: engine.Run(snapshot), Skipped: snapshot.Skipped}
+	if format == formatTable {
+		err = writeTable(os.Stdout, report)
+	} else {
+		err = report.Write(os.Stdout, format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	// A source that timed out or failed may hide findings, so passing
+	// would be a false green; forbidden sources fail the same way on
+	// every run and only show up in the report.
+	if report.Incomplete() {
+		fmt.Fprintln(os.Stderr, "kubi: some sources could not be read; findings are incomplete")
+		return exitError
+	}
+	if report.Failed(failOn) {
+		return exitFindings
+	}
+	return exitPass
+}
+
+// writeTable prints one row per unsuppressed finding and a summary line,
+// followed by the skipped sources. Suppressed findings are only counted;
+// the other formats list them.
+func writeTable(w io.Writer, report api.ValidationReport) error {
+	counts := map[string]int{}
+	suppressed := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tRULE\tOBJECT\tTITLE")
+	for _, result := range report.Results() {
+		if result.Suppressed {
+			suppressed++
+			continue
+		}
+		counts[result.Severity]++
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Severity, result.Rule, result.Object, result.Title)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	total := counts[api.SeverityCritical] + counts[api.SeverityWarning] + counts[api.SeverityInfo]
+	parts := []string{}
+	for _, severity := range []string{api.SeverityCritical, api.SeverityWarning, api.SeverityInfo} {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+	}
+	if _, err := fmt.Fprintf(w, "\n%d findings (%s), %d suppressed\n", total, strings.Join(parts, ", "), suppressed); err != nil {
+		return err
+	}
+	for _, source := range report.Skipped {
+		if _, err := fmt.Fprintf(w, "skipped %s: %s\n", source.Source, source.Reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
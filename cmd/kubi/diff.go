@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/YanaDevOps/kubi/backend/api"
+	"github.com/YanaDevOps/kubi/backend/config"
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+// runDiffCommand handles `kubi diff --from <context> --to <context>`. Like
+// diff(1) it exits 0 when the clusters match and 1 when they differ.
+func runDiffCommand(args []string) int {
+	var from, to, namespaceMap, output string
+	cfg, err := config.ParseArgs(os.Args[0]+" diff", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&from, "from", "", "kubeconfig context to compare from")
+		fs.StringVar(&to, "to", "", "kubeconfig context to compare to")
+		fs.StringVar(&namespaceMap, "namespace-map", "", "comma-separated from=to namespace pairs, e.g. staging=prod")
+		fs.StringVar(&output, "output", "text", "output format: text or json")
+		fs.StringVar(&output, "o", "text", "shorthand for --output")
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	if from == "" || to == "" {
+		fmt.Fprintln(os.Stderr, "kubi: --from and --to are required")
+		return exitError
+	}
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "kubi: invalid format %q: must be text or json\n", output)
+		return exitError
+	}
+	mapping, err := api.ParseNamespaceMap(namespaceMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+	defer cancel()
+
+	store := kube.NewStore(cfg)
+	fromClient, err := store.ClientFor(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	toClient, err := store.ClientFor(to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	diff, err := api.CompareClusters(ctx, fromClient, toClient, api.DiffOptions{Namespace: cfg.Namespace, NamespaceMap: mapping})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	diff.From = from
+	diff.To = to
+
+	if output == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(diff)
+	} else {
+		err = writeDiffText(os.Stdout, diff)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	for _, skipped := range diff.Skipped {
+		fmt.Fprintf(os.Stderr, "kubi: skipped %s\n", skipped)
+	}
+	if diff.Changed() {
+		return exitFindings
+	}
+	return exitPass
+}
+
+var diffMarkers = map[string]string{"added": "+", "removed": "-", "modified": "~"}
+
+func writeDiffText(w io.Writer, diff api.DiffResponse) error {
+	for _, object := range diff.Objects {
+		name := object.Name
+		if object.Namespace != "" {
+			name = object.Namespace + "/" + name
+		}
+		if object.ToNamespace != "" || object.ToName != "" {
+			toNamespace, toName := object.ToNamespace, object.ToName
+			if toNamespace == "" {
+				toNamespace = object.Namespace
+			}
+			if toName == "" {
+				toName = object.Name
+			}
+			if toNamespace != "" {
+				toName = toNamespace + "/" + toName
+			}
+			name += " -> " + toName
+		}
+		fmt.Fprintf(w, "%s %s %s\n", diffMarkers[object.Change], object.Kind, name)
+		for _, field := range object.Fields {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", field.Path, diffValue(field.From), diffValue(field.To))
+		}
+	}
+	for _, section := range []struct {
+		title string
+		diffs []api.DerivedDiff
+	}{
+		{"Service ports", diff.ServicePorts},
+		{"Ingress routes", diff.IngressRoutes},
+		{"RBAC bindings", diff.RBACBindings},
+	} {
+		if len(section.diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, derived := range section.diffs {
+			fmt.Fprintf(w, "  %s %s: %s\n", diffMarkers[derived.Change], derived.Object, derived.Value)
+		}
+	}
+	summary := diff.Summary
+	_, err := fmt.Fprintf(w, "\n%s -> %s: %d added, %d removed, %d modified, %d unchanged\n", diff.From, diff.To, summary.Added, summary.Removed, summary.Modified, summary.Unchanged)
+	return err
+}
+
+func diffValue(value any) string {
+	if value == nil {
+		return "<none>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
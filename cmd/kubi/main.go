@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/YanaDevOps/kubi/backend/api"
 	"github.com/YanaDevOps/kubi/backend/config"
 	"github.com/YanaDevOps/kubi/backend/kube"
 	"github.com/YanaDevOps/kubi/backend/server"
@@ -16,6 +17,17 @@ import (
 const version = "dev"
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheckCommand(os.Args[2:]))
+		case "snapshot":
+			os.Exit(runSnapshotCommand(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiffCommand(os.Args[2:]))
+		}
+	}
+
 	cfg, err := config.Parse()
 	if err != nil {
 		config.ExitWithError(err)
@@ -24,8 +36,24 @@ func main() {
 	logger := newLogger(cfg.LogLevel)
 	started := time.Now().UTC()
 
+	engine, err := newValidationEngine(cfg)
+	if err != nil {
+		config.ExitWithError(err)
+	}
+
 	store := kube.NewStore(cfg)
-	handler := server.NewRouter(cfg, version, started, store)
+	if cfg.Snapshot != "" {
+		if _, err := store.Client(); err != nil {
+			config.ExitWithError(err)
+		}
+		logger.Info("serving snapshot", "path", cfg.Snapshot)
+	}
+	for _, cluster := range cfg.Clusters {
+		if _, err := store.ClientFor(cluster); err != nil {
+			config.ExitWithError(err)
+		}
+	}
+	handler := server.NewRouter(cfg, version, started, store, engine)
 	srv := server.New(cfg, logger, handler)
 
 	go func() {
@@ -45,6 +73,14 @@ func main() {
 	}
 }
 
+func newValidationEngine(cfg config.Config) (*api.ValidationEngine, error) {
+	rules := api.BuiltinRules()
+	if err := api.RegisterCELRules(rules, cfg.Validation.Custom); err != nil {
+		return nil, err
+	}
+	return api.NewValidationEngine(rules, cfg.Validation)
+}
+
 func newLogger(level string) *slog.Logger {
 	var slogLevel slog.Level
 	switch level {
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/YanaDevOps/kubi/backend/config"
+	"github.com/YanaDevOps/kubi/backend/kube"
+)
+
+const snapshotTimeout = 10 * time.Minute
+
+// runSnapshotCommand handles `kubi snapshot -o <file>`. It resolves the
+// cluster like the server does; --namespace limits namespaced resources.
+func runSnapshotCommand(args []string) int {
+	var output string
+	cfg, err := config.ParseArgs(os.Args[0]+" snapshot", args, func(fs *flag.FlagSet) {
+		fs.StringVar(&output, "output", "kubi-snapshot.tar.gz", "archive to write")
+		fs.StringVar(&output, "o", "kubi-snapshot.tar.gz", "shorthand for --output")
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	if cfg.Snapshot != "" {
+		fmt.Fprintln(os.Stderr, "kubi: --snapshot cannot be used with kubi snapshot")
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	client, err := kube.NewStore(cfg).Client()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+	manifest, err := kube.WriteSnapshot(ctx, client, cfg.Namespace, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(output)
+		fmt.Fprintf(os.Stderr, "kubi: %v\n", err)
+		return exitError
+	}
+
+	objects := 0
+	for _, resource := range manifest.Resources {
+		objects += resource.Items
+		if resource.Error != "" {
+			fmt.Fprintf(os.Stderr, "kubi: skipped %s: %s\n", resource.Resource, resource.Error)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "kubi: wrote %d objects of %d resources from %s to %s\n", objects, len(manifest.Resources), manifest.Context, output)
+	return exitPass
+}
@@ -46,6 +46,7 @@ export type NodeList = {
 export type WorkloadItem = {
   name: string;
   namespace: string;
+  selector: string;
   desiredReplicas: number;
   readyReplicas: number;
   updatedReplicas: number;
@@ -265,18 +266,69 @@ export type StorageOverview = {
   csiDrivers: CSIDriverItem[];
 };
 
+export type ObjectRef = {
+  kind: string;
+  namespace?: string;
+  name: string;
+};
+
+export type SuppressedObject = {
+  object: string;
+  ref: ObjectRef;
+  source: "annotation" | "config";
+  reason: string;
+  expires?: string;
+};
+
 export type ValidationItem = {
   id: string;
+  rule: string;
   severity: string;
   title: string;
   details: string;
   objects: string[];
+  refs: ObjectRef[];
+  suppressed?: SuppressedObject[];
 };
 
 export type ValidationResponse = {
   items: ValidationItem[];
 };
 
+export type PodSecurityViolation = {
+  level: "baseline" | "restricted";
+  check: string;
+  details: string;
+  container?: string;
+  values?: string[];
+};
+
+export type WorkloadPodSecurity = {
+  kind: string;
+  namespace: string;
+  name: string;
+  level: string;
+  enforce: string;
+  compliant: boolean;
+  rejectedIfRaised: string[];
+  violations: PodSecurityViolation[];
+};
+
+export type NamespacePodSecurity = {
+  name: string;
+  enforce: string;
+  audit: string;
+  warn: string;
+  labeled: boolean;
+  workloads: number;
+  rejectedIfRaised: Record<string, number>;
+};
+
+export type PodSecurityResponse = {
+  namespaces: NamespacePodSecurity[];
+  workloads: WorkloadPodSecurity[];
+};
+
 export type ServiceIntent = {
   namespace: string;
   service: string;
@@ -519,6 +571,10 @@ export function fetchValidation(query?: ListQuery) {
   return request<ValidationResponse>(`/api/validation${listQueryString(query)}`);
 }
 
+export function fetchPodSecurity(query?: ListQuery) {
+  return request<PodSecurityResponse>(`/api/security/pod-security${listQueryString(query)}`);
+}
+
 export function fetchTraffic(query?: ListQuery) {
   return request<TrafficResponse>(`/api/traffic${listQueryString(query)}`);
 }
@@ -85,6 +85,18 @@ export default function Validation() {
                   ) : null}
                 </div>
               ) : null}
+              {item.suppressed && item.suppressed.length > 0 ? (
+                <div className="mt-3 text-xs text-slatey-500">
+                  <div className="uppercase tracking-widest">Suppressed ({item.suppressed.length})</div>
+                  {item.suppressed.slice(0, 8).map((entry) => (
+                    <div key={entry.object}>
+                      {entry.object} · {entry.source}
+                      {entry.reason ? `: ${entry.reason}` : ""}
+                      {entry.expires ? ` (until ${entry.expires})` : ""}
+                    </div>
+                  ))}
+                </div>
+              ) : null}
             </div>
           ))
         )}